@@ -0,0 +1,107 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync/atomic"
+
+	"github.com/ConradIrwin/golo/golo"
+)
+
+// debugServer serves the debugging endpoints that -debug-addr asks for while golo watches a
+// program: net/http/pprof under /debug/pprof/, golo's counters as JSON (as expvar does) at
+// /debug/vars, and /healthz, which succeeds once a run has fixed and built the program.
+// It is a Sink, so the counters are the ones in the report of each run.
+type debugServer struct {
+	addr string
+
+	loaded   atomic.Bool
+	counters *expvar.Map
+	runs     *expvar.Int
+	fixes    *expvar.Int
+	deferred *expvar.Int
+	unfixed  *expvar.Int
+	status   *expvar.Int
+	lastErr  *expvar.String
+}
+
+// startDebugServer listens on addr, which must be on localhost (as pprof shows what golo is
+// doing to anyone who can reach it), and serves the debugging endpoints until golo exits.
+func startDebugServer(addr string) (*debugServer, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -debug-addr %q: %w", addr, err)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("invalid -debug-addr %q (expected an address on localhost)", addr)
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &debugServer{addr: l.Addr().String(), counters: new(expvar.Map).Init()}
+	d.runs, d.fixes, d.deferred = d.newInt("runs"), d.newInt("fixes"), d.newInt("deferrals")
+	d.unfixed, d.status = d.newInt("unfixed"), d.newInt("last_status")
+	d.lastErr = new(expvar.String)
+	d.counters.Set("last_error", d.lastErr)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/vars", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintln(w, d.counters.String())
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !d.loaded.Load() {
+			http.Error(w, "golo has not built the program yet", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	go http.Serve(l, mux)
+	return d, nil
+}
+
+func (d *debugServer) newInt(name string) *expvar.Int {
+	v := new(expvar.Int)
+	d.counters.Set(name, v)
+	return v
+}
+
+// started counts a run.
+func (d *debugServer) started() {
+	d.runs.Add(1)
+}
+
+// prepared records whether a run fixed and built the program.
+func (d *debugServer) prepared(err error) {
+	if err != nil {
+		d.lastErr.Set(err.Error())
+		return
+	}
+	d.loaded.Store(true)
+}
+
+// exited records the exit status of the program.
+func (d *debugServer) exited(status int) {
+	d.status.Set(int64(status))
+}
+
+// Report counts what the last run fixed.
+func (d *debugServer) Report(o *golo.Outcome) error {
+	d.fixes.Set(int64(len(o.Report.Fixes)))
+	d.deferred.Set(int64(len(o.Report.Deferrals())))
+	d.unfixed.Set(int64(len(o.Unfixed)))
+	return nil
+}
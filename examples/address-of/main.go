@@ -0,0 +1,24 @@
+package main
+
+import "fmt"
+
+type Config struct {
+	Name string
+}
+
+func defaults() Config {
+	return Config{Name: "golo"}
+}
+
+func main() {
+	counts := map[string]int{"a": 1}
+	p := &counts["a"]
+	*p = 2
+	fmt.Println(counts["a"], *p)
+
+	c := &defaults()
+	fmt.Println(c.Name)
+
+	tmp := "already used"
+	fmt.Println(tmp, &Config{Name: tmp}.Name)
+}
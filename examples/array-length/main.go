@@ -0,0 +1,20 @@
+package main
+
+import "fmt"
+
+func checksum(n int) int {
+	var buf [n]byte
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+
+	sum := 0
+	for _, b := range buf {
+		sum += int(b)
+	}
+	return sum + int(buf[n-1])
+}
+
+func main() {
+	fmt.Println(checksum(4))
+}
@@ -0,0 +1,13 @@
+package main
+
+func check(name string) error {
+	return nil
+}
+
+func main() {
+	var err error
+	if err = check("golo") {
+		println("invalid")
+	}
+	println("done")
+}
@@ -0,0 +1,16 @@
+package main
+
+type user struct {
+	name string
+}
+
+func main() {
+	u := user{name: "admin"}
+	if u.name = "admin" {
+		println("welcome back")
+	}
+	switch mode := "debug"; mode = "debug" {
+	case true:
+		println("debugging")
+	}
+}
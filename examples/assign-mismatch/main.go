@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	config := load("golo.json")
+	fmt.Println(config)
+
+	name, ok := lookup("HOME")
+	fmt.Println(name, ok)
+
+	var a, b = 1, 2, 3
+	fmt.Println(a, b)
+}
+
+// load used to return only the config, but now also returns an error
+func load(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	return string(b), err
+}
+
+// lookup used to return whether the variable was set, but doesn't any more
+func lookup(key string) string {
+	return os.Getenv(key)
+}
+
+func init() {
+	var x, y, z = 1, 2
+	fmt.Println(x, y, z)
+}
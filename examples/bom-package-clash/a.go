@@ -0,0 +1,3 @@
+package lib
+
+func A() int { return 1 }
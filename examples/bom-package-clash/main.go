@@ -0,0 +1,4 @@
+﻿// main.go was saved by an editor that adds a byte order mark
+package main
+
+func main() {}
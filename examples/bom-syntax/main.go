@@ -0,0 +1 @@
+﻿package main; func main() { println("hi" 1) }
@@ -0,0 +1,22 @@
+package main
+
+import "fmt"
+
+type Server struct {
+	name string
+}
+
+func (s *Server) Greet(who string {
+	fmt.Println("hello", who, "from", s.name)
+}
+
+type Config struct {
+	port int int
+}
+
+func main() {
+	s := &Server{name: "golo"}
+	fmt.Println("starting")
+	s.Greet("world")
+	fmt.Println(Config{port: 8080})
+}
@@ -0,0 +1,16 @@
+package main
+
+import "fmt"
+
+func main() {
+	done := make(chan bool, "1")
+	results := make(chan)
+
+	go func() {
+		results <- 42
+		done <- true
+	}()
+
+	fmt.Println(<-results)
+	<-done
+}
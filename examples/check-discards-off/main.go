@@ -0,0 +1,27 @@
+package main
+
+type invalid string
+
+func (e invalid) Error() string { return string(e) }
+
+func validateName(name string) error {
+	if name == "" {
+		return invalid("name is empty")
+	}
+	return nil
+}
+
+func checkAge(age string) bool {
+	return age != ""
+}
+
+func checkout(cart string) error {
+	return nil
+}
+
+func main() {
+	err := validateName("golo")
+	ok := checkAge("12")
+	done := checkout("cart")
+	println("hello")
+}
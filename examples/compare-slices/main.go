@@ -0,0 +1,11 @@
+package main
+
+import "fmt"
+
+func main() {
+	want := []string{"a", "b"}
+	got := []string{"a", "b"}
+	if got == want {
+		fmt.Println("same")
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "fmt"
+
+type Config struct {
+	Name   string
+	Labels map[string]string
+}
+
+func main() {
+	a := Config{Name: "a"}
+	b := Config{Name: "b"}
+	fmt.Println("before")
+	fmt.Println(a == b)
+}
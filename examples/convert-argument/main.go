@@ -0,0 +1,13 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type UserID string
+
+func main() {
+	id := UserID("alice")
+	fmt.Println(strings.ToUpper(id))
+}
@@ -0,0 +1,12 @@
+package main
+
+import "fmt"
+
+type UserID string
+
+func main() {
+	var id UserID
+	name := "bob"
+	id = name
+	fmt.Println(id)
+}
@@ -0,0 +1,12 @@
+package main
+
+import "fmt"
+
+type Count int
+
+func main() {
+	var c Count
+	name := "carol"
+	c = name
+	fmt.Println(c, name)
+}
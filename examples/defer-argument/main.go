@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	dir, _ := os.MkdirTemp("", "example")
+	defer cleanup(dir, !os.Getenv("VERBOSE"))
+	fmt.Println("working in", dir)
+}
+
+func cleanup(dir string, verbose bool) {
+	if verbose {
+		fmt.Println("removing", dir)
+	}
+	os.RemoveAll(dir)
+}
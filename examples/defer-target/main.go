@@ -0,0 +1,12 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	dir, _ := os.MkdirTemp("", "example")
+	defer os.RemoveAl(dir)
+	fmt.Println("working in", dir)
+}
@@ -0,0 +1,13 @@
+package main
+
+import "time"
+
+type Client struct {
+	Timeout time.Duration
+}
+
+func main() {
+	timeout := 5
+	client := Client{Timeout: timeout}
+	println(client.Timeout.String())
+}
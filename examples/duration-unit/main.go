@@ -0,0 +1,16 @@
+package main
+
+import "time"
+
+type Client struct {
+	Timeout  time.Duration
+	Deadline time.Duration
+}
+
+func main() {
+	timeout := 5
+	client := &Client{}
+	client.Deadline = 30 * time.Second
+	client.Timeout = timeout
+	println(client.Timeout.String())
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ConradIrwin/golo/examples/export-shims/other"
+)
+
+func main() {
+	fmt.Println(other.Greet("world"))
+	fmt.Println(other.shout(other.Greet("you"), "!", "?"))
+}
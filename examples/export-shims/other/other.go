@@ -0,0 +1,12 @@
+package other
+
+import "strings"
+
+func Greet(name string) string {
+	return "hello " + name
+}
+
+// shout is not exported yet, as I'm not sure about its API
+func shout(s string, suffixes ...string) string {
+	return strings.ToUpper(s) + strings.Join(suffixes, "")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	greet("golo", 3)
+	fmt.Println(label("golo", next(),
+		true))
+	for i := 0; i < 3; i++ {
+		if ok := check(i, next()); ok {
+			fmt.Println(i)
+		}
+	}
+}
+
+// greet used to take the number of times to greet, but now always greets once
+func greet(name string) {
+	fmt.Println("hello", name)
+}
+
+// label no longer takes an id or a flag
+func label(name string) string {
+	return "<" + name + ">"
+}
+
+// check no longer needs the next id, but next() must still be called every time
+func check(i int) bool {
+	return i%2 == 0
+}
+
+var id = 0
+
+func next() int {
+	id++
+	fmt.Fprintln(os.Stderr, "next", id)
+	return id
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func main() {
+	name := "golo"
+	took := 3 * time.Second
+
+	var greeting string = fmt.Sprintf("hello %d, that took %s", name, took)
+	var label string = fmt.Sprintf("%s (%d)", name, len(name), took)
+	fmt.Println(greeting, label)
+	fmt.Println(undefined)
+}
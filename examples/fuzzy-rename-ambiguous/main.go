@@ -0,0 +1,15 @@
+package main
+
+import "fmt"
+
+func loadUser(id string) string {
+	return "user " + id
+}
+
+func loadUsers(id string) string {
+	return "users " + id
+}
+
+func main() {
+	fmt.Println(loadUserz("1"))
+}
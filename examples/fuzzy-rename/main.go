@@ -0,0 +1,11 @@
+package main
+
+import "fmt"
+
+func ParseJSON(s string) map[string]any {
+	return map[string]any{"input": s}
+}
+
+func main() {
+	fmt.Println(parseJson(`{}`))
+}
@@ -0,0 +1,24 @@
+// Code generated by gen.sh; DO NOT EDIT.
+
+package main
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the generator to generate them again.
+	var x [1]struct{}
+	_ = x[Red-0]
+	_ = x[Blue-1]
+}
+
+const _Color_name = "RedBlue"
+
+var _Color_index = [...]uint8{0, 3, 7}
+
+func (i Color) String() string {
+	if i < 0 || i >= Color(len(_Color_index)-1) {
+		return "Color(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _Color_name[_Color_index[i]:_Color_index[i+1]]
+}
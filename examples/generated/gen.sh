@@ -0,0 +1,28 @@
+# gen.sh stands in for stringer: it writes the String method for Red, Green and Blue.
+cat > color_string.go <<'EOF'
+// Code generated by gen.sh; DO NOT EDIT.
+
+package main
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the generator to generate them again.
+	var x [1]struct{}
+	_ = x[Red-0]
+	_ = x[Green-1]
+	_ = x[Blue-2]
+}
+
+const _Color_name = "RedGreenBlue"
+
+var _Color_index = [...]uint8{0, 3, 8, 12}
+
+func (i Color) String() string {
+	if i < 0 || i >= Color(len(_Color_index)-1) {
+		return "Color(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _Color_name[_Color_index[i]:_Color_index[i+1]]
+}
+EOF
@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+//go:generate sh gen.sh
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+func main() {
+	fmt.Println(Red, Green, Blue)
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+)
+
+var url = "https://example.com"
+
+func main() {
+	u, err := url.Parse(url)
+	fmt.Println(u, err)
+}
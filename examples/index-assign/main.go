@@ -0,0 +1,9 @@
+package main
+
+import "fmt"
+
+func main() {
+	names := []string{"alice", "bob"}
+	names["key"] = "carol"
+	fmt.Println(names)
+}
@@ -0,0 +1,9 @@
+package main
+
+import "fmt"
+
+func main() {
+	ages := map[string]int{"alice": 42}
+	fmt.Println(ages[42])
+	fmt.Println(ages["alice"])
+}
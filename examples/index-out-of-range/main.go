@@ -0,0 +1,9 @@
+package main
+
+import "fmt"
+
+func main() {
+	var scores [5]int
+	fmt.Println(scores[10])
+	fmt.Println(len(scores))
+}
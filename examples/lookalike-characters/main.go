@@ -0,0 +1,13 @@
+package main
+
+func greet(name string) string {
+    greeting := “Hello, ” + name
+    return greeting + “!”
+}
+
+func main() {
+	suffix := “ again”​
+	println(greet(“world”) + suffix)
+	// comments keep their “curly” quotes
+	println("and so do “strings”")
+}
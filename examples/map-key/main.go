@@ -0,0 +1,10 @@
+package main
+
+import "fmt"
+
+func main() {
+	counts := make(map[[]byte]int)
+	key := []byte("golo")
+	counts[key]++
+	fmt.Println(counts[key], len(counts))
+}
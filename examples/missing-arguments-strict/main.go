@@ -0,0 +1,21 @@
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("starting")
+	greet("golo")
+	fmt.Println(wait("done"))
+}
+
+// greet used to take only the name, but now also takes the number of times to greet
+func greet(name string, count int) {
+	for i := 0; i < count; i++ {
+		fmt.Println("hello", name)
+	}
+}
+
+// wait used to take only the message, but now also takes a timeout
+func wait(msg string, timeout int) string {
+	return msg
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func main() {
+	greet("golo")
+	fmt.Println(wait("done"))
+	log("starting")
+}
+
+// greet used to take only the name, but now also takes the number of times to greet
+func greet(name string, count int) {
+	for i := 0; i < count; i++ {
+		fmt.Println("hello", name)
+	}
+}
+
+// wait now takes a timeout (but a zero timeout is fine)
+func wait(msg string, timeout time.Duration, at time.Time) string {
+	return msg
+}
+
+// log's fields are optional, so are not added
+func log(msg string, level int, fields ...string) {
+	fmt.Println(level, msg, fields)
+}
@@ -0,0 +1,10 @@
+package main
+
+import "os"
+
+func main() {
+	fmt.Println(strings.ToUpper(os.Args[0]))
+	fmt.Println(rand.Intn(10) < max)
+	// there is more than one template package, so this is not fixed
+	template.New("x")
+}
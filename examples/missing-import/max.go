@@ -0,0 +1,5 @@
+package main
+
+import "math/rand"
+
+var max = rand.Intn(100)
@@ -0,0 +1,10 @@
+package main
+
+// Greet was main, until it was renamed to be called from somewhere else.
+func Greet() {
+	println("Hello, world!")
+}
+
+func greeting(name string) string {
+	return "Hello, " + name + "!"
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Shape has just gained the Perimeter and WriteTo methods
+type Shape interface {
+	Area() float64
+	Perimeter() float64
+	WriteTo(w io.Writer, prefix ...string) (int64, error)
+}
+
+func main() {
+	shapes := []Shape{&Circle{1}, Square{2}}
+	for _, s := range shapes {
+		fmt.Println(s.Area())
+	}
+	shapes[0].WriteTo(os.Stdout)
+}
@@ -0,0 +1,23 @@
+package main
+
+import "io"
+
+type Circle struct {
+	r float64
+}
+
+func (c *Circle) Area() float64 {
+	return 3 * c.r * c.r
+}
+
+func (c *Circle) WriteTo(w io.Writer, prefix ...string) (int64, error) {
+	return 0, nil
+}
+
+type Square struct {
+	side float64
+}
+
+func (sq Square) Area() float64 {
+	return sq.side * sq.side
+}
@@ -0,0 +1,22 @@
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(sign(1), sign(-1))
+	fmt.Println(double(2))
+}
+
+func sign(x int) string {
+	if x > 0 {
+		return "positive"
+	} else if x < 0 {
+		fmt.Println("negative")
+	} else {
+		return "zero"
+	}
+}
+
+func double(x int) int {
+	fmt.Println("doubling", x)
+}
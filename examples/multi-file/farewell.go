@@ -0,0 +1,5 @@
+package main
+
+func farewell() string {
+	return undefinedFarewell
+}
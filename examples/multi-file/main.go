@@ -0,0 +1,8 @@
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(greeting())
+	fmt.Println(farewell())
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func main() {
+	name := "golo"
+	fmt.Println("before")
+	fmt.Println(strings.ToUpper(name) + 1)
+	fmt.Println("after")
+
+	// later statements use n, so they can't run without it
+	n := len(name) + "1"
+	fmt.Println(n)
+}
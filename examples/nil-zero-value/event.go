@@ -0,0 +1,9 @@
+package main
+
+import "time"
+
+type Event struct {
+	Name     string
+	At       time.Time
+	Attempts int
+}
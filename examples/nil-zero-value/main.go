@@ -0,0 +1,21 @@
+package main
+
+import "fmt"
+
+func count(items []string) int {
+	if len(items) == 0 {
+		return nil
+	}
+	return len(items)
+}
+
+func main() {
+	e := Event{Name: "launch", At: nil}
+	e.Attempts = nil
+	fmt.Println(count(nil), e.Name, e.Attempts, e.At.IsZero())
+
+	if e == nil {
+		fmt.Println("no event")
+	}
+	fmt.Println("done")
+}
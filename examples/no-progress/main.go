@@ -0,0 +1,14 @@
+package main
+
+// golo changes the := that encloses the one with the error (f := func...), so the error
+// comes back, and golo stops trying to fix it.
+func main() {
+	x := "outer"
+	f := func() {
+		x := "first"
+		x := "second"
+		println(x)
+	}
+	f()
+	println(x)
+}
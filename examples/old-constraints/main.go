@@ -0,0 +1,27 @@
+package main
+
+type Stringish interface {
+	type string, []byte
+}
+
+type Name string
+
+type Named interface{ type Name, []byte }
+
+func Concat[T Stringish](parts ...T) string {
+	s := ""
+	for _, p := range parts {
+		s += string(p)
+	}
+	return s
+}
+
+func Greet[T Named](name T) {
+	println("hello", string(name))
+}
+
+func main() {
+	println(Concat("a", "b"))
+	println(Concat([]byte("c"), []byte("d")))
+	Greet(Name("world"))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func parse(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	return n, undefinedErr
+}
+
+func divide(a, b int) (q, r int) {
+	if b == 0 {
+		q = a / missing
+		return
+	}
+	q, r = a/b, a%b
+	return
+}
+
+func greet(name string) {
+	fmt.Println("hello", name)
+	fmt.Println(name + 1)
+}
+
+func sign(n int) int {
+	if n > 0 {
+		return 1
+	} else if n < 0 {
+		return -1
+	}
+}
+
+func main() {
+	greet("golo")
+	fmt.Println(parse("1"))
+	fmt.Println(divide(7, 2))
+	fmt.Println(sign(0))
+}
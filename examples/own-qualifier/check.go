@@ -0,0 +1,11 @@
+package greet
+
+// moved from the greet_test package, without removing the qualifiers
+func checkGreet() bool {
+	return greet.Greet("") == "hello, "+greet.Name("")
+}
+
+// Name is shadowed by the parameter, so the qualifier can't be removed
+func checkName(Name string) bool {
+	return greet.Name(Name) == Name
+}
@@ -0,0 +1,12 @@
+package greet
+
+func Name(name string) string {
+	if name == "" {
+		return "world"
+	}
+	return name
+}
+
+func Greet(name string) string {
+	return "hello, " + Name(name)
+}
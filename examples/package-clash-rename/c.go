@@ -0,0 +1,4 @@
+// c.go was copied from another package
+package util
+
+func C() int { return 3 }
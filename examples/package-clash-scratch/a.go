@@ -0,0 +1,4 @@
+package lib
+
+// A is used by main.go
+func A() int { return 1 }
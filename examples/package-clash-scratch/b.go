@@ -0,0 +1,3 @@
+package lib
+
+func B() int { return 2 }
@@ -0,0 +1,8 @@
+// main.go is a scratch file that was left here
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("trying it out")
+}
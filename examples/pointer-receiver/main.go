@@ -0,0 +1,28 @@
+package main
+
+type Greeter interface {
+	Greet() string
+}
+
+type English struct {
+	name string
+}
+
+// Greet used to have a value receiver, until it started remembering who it greeted
+func (e *English) Greet() string {
+	e.name = "hello " + e.name
+	return e.name
+}
+
+func greet(g Greeter) string {
+	return g.Greet()
+}
+
+func main() {
+	e := English{name: "world"}
+	var g Greeter = e
+	println(g.Greet(), e.name)
+
+	people := map[string]English{"alice": {name: "alice"}}
+	println(greet(people["alice"]))
+}
@@ -0,0 +1,19 @@
+package main
+
+type Primary struct {
+	Host string
+}
+
+type Replica struct {
+	Host string
+}
+
+type Database struct {
+	Primary
+	Replica
+}
+
+func main() {
+	d := Database{Host: "localhost"}
+	println(d.Primary.Host)
+}
@@ -0,0 +1,34 @@
+package main
+
+type HTTPConfig struct {
+	Host    string
+	Timeout string
+}
+
+type ServerConfig struct {
+	HTTPConfig
+	Name string
+}
+
+type Config struct {
+	ServerConfig
+	Debug bool
+}
+
+type Client struct {
+	*HTTPConfig
+	Retries int
+}
+
+func main() {
+	// the value is added to the literal for the embedded struct if there is one
+	c := Config{
+		ServerConfig: ServerConfig{Name: "c"},
+		Timeout:      "30s",
+	}
+	println(c.Timeout)
+
+	// or to a new one if there isn't
+	d := Client{Host: "localhost"}
+	println(d.Host)
+}
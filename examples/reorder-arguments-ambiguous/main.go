@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+func main() {
+	greeting := "hello"
+	name := "golo"
+	count := 3
+	greet(greeting, count, name)
+}
+
+// the strings could go either way round, so the call is not reordered.
+func greet(count int, greeting, name string) {
+	for i := 0; i < count; i++ {
+		fmt.Println(greeting, name)
+	}
+}
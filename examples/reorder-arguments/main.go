@@ -0,0 +1,15 @@
+package main
+
+import "fmt"
+
+func main() {
+	name := "golo"
+	count := 3
+	greet(name, count)
+}
+
+func greet(count int, name string) {
+	for i := 0; i < count; i++ {
+		fmt.Println("hello", name)
+	}
+}
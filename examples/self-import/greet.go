@@ -0,0 +1,10 @@
+package greet
+
+import (
+	"github.com/ConradIrwin/golo/examples/self-import"
+)
+
+// copied from the greet package in another module
+func Greet(name string) string {
+	return greet.Prefix + greet.Name(name) + greet.Suffix
+}
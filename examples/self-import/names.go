@@ -0,0 +1,12 @@
+package greet
+
+const Prefix = "hello, "
+
+const Suffix = "!"
+
+func Name(name string) string {
+	if name == "" {
+		return "world"
+	}
+	return name
+}
@@ -0,0 +1,14 @@
+package main
+
+type User struct {
+	ID    string `json:"id" xml:"id" json:"user_id"`
+	Id    string
+	Name  string `json:"name"`
+	NAME  string "json:\"NAME,omitempty\" xml:\"name\""
+	Email string `json:"email" yaml:"email" yaml:"mail"`
+}
+
+func main() {
+	u := User{ID: "1", Id: "2", Name: "golo", NAME: "GOLO", Email: "golo@example.com"}
+	println(u.ID, u.Id, u.Name, u.NAME, u.Email)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// WriteTo no longer takes a prefix
+type Shape interface {
+	Area() float64
+	WriteTo(w io.Writer) (int64, error)
+}
+
+func describe(s Shape) {
+	fmt.Println(s.Area())
+	s.WriteTo(os.Stdout)
+}
+
+func main() {
+	describe(&Circle{1})
+	describe(Square{2})
+}
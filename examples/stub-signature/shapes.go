@@ -0,0 +1,26 @@
+package main
+
+import "io"
+
+type Circle struct {
+	r float64
+}
+
+func (c *Circle) Area() float32 {
+	return 3 * float32(c.r*c.r)
+}
+
+func (c *Circle) WriteTo(w io.Writer) (int64, error) {
+	return 0, nil
+}
+
+type Square struct {
+	side float64
+}
+
+func (sq Square) Area() float64 {
+	return sq.side * sq.side
+}
+
+//golo:stub
+func (sq Square) WriteTo(io.Writer, ...string) (int64, error) { panic("missing method WriteTo") }
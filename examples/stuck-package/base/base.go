@@ -0,0 +1,5 @@
+package base
+
+func Greeting() string {
+	return greeting
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/ConradIrwin/golo/examples/stuck-package/stuck"
+	"github.com/ConradIrwin/golo/examples/stuck-package/user"
+)
+
+func main() {
+	println(stuck.Name, user.Hello())
+}
@@ -0,0 +1,6 @@
+package stuck
+
+// golo can't fix an initialization cycle, but it still fixes the other packages
+var Name = other
+
+var other = Name
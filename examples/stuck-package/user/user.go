@@ -0,0 +1,8 @@
+package user
+
+import "github.com/ConradIrwin/golo/examples/stuck-package/base"
+
+// user is only built (and so only broken) once base has been fixed
+func Hello() string {
+	return base.Greeting() + who
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func shout(s string) string {
+	return strings.ToUpper(s) + "!"
+}
+
+func TestShout(t *testing.T) {
+	if shout("hi") != "HI!" {
+		t.Error("expected HI!")
+	}
+}
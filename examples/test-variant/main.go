@@ -0,0 +1,11 @@
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(total(1, 2))
+}
+
+func total(a, b int) int {
+	return a + b + "1"
+}
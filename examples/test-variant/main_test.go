@@ -0,0 +1,9 @@
+package main
+
+import "testing"
+
+func TestTotal(t *testing.T) {
+	if total(1, 2) != "3" {
+		t.Error("expected 3")
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("starting")
+	if len(fmt.Sprint()) > 0 {
+		logEvent("start")
+		logEvent("start", 1, true)
+	}
+
+	var total int = sum(1, 2)
+	fmt.Println("total", total)
+	if isReady() {
+		fmt.Println("ready")
+	}
+}
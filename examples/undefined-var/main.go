@@ -0,0 +1,13 @@
+package main
+
+import "fmt"
+
+func main() {
+	for i := 0; i < 3; i++ {
+		total = total + i
+	}
+	fmt.Println("total", total)
+
+	fmt.Println(names[0], len(names))
+	fmt.Println("label:", label)
+}
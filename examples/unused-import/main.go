@@ -3,5 +3,5 @@ package main
 import "fmt"
 
 func main() {
-	fmt.Println(oops)
+	fmt.Println(1 + "oops")
 }
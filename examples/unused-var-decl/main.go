@@ -0,0 +1,15 @@
+package main
+
+import "strconv"
+
+func main() {
+	x := 5
+	y, err := strconv.Atoi("1")
+	var z int
+	a, b := strconv.Atoi("2")
+	var s = strconv.Itoa(3)
+
+	if n := 6; err != nil {
+		panic(err)
+	}
+}
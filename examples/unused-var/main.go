@@ -3,7 +3,7 @@ package main
 func main() {
 	a, b := c()
 
-	d(a, b)
+	a.d(b)
 }
 
 func c() (int, int) {
@@ -0,0 +1,142 @@
+package golo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// writeFixes writes the fixed files over the originals (formatted with gofmt), as used by fix mode.
+// With Options.Diff the changes are printed as a unified diff instead.
+//
+// Files that golo fixed by deferring an error are skipped unless Options.AllowPanics is set,
+// as are files that have changed since golo read them. It returns 1 if any file was skipped
+// (or not every error could be fixed), and 0 otherwise.
+func (r *Runner) writeFixes(w io.Writer) (int, error) {
+	deferrals := map[string]int{}
+	kinds := map[string][]string{}
+	for _, fix := range r.applied {
+		if fix.Kind == Deferred {
+			deferrals[fix.Pos.Filename]++
+		}
+		if !slices.Contains(kinds[fix.Pos.Filename], string(fix.Kind)) {
+			kinds[fix.Pos.Filename] = append(kinds[fix.Pos.Filename], string(fix.Kind))
+		}
+	}
+
+	status := 0
+	if !r.built {
+		fmt.Fprintln(w, "golo: not every error could be fixed (run go build to see them)")
+		status = 1
+	}
+	for _, file := range r.fixedFiles() {
+		name := relativePath(file)
+		if n := deferrals[file]; n > 0 && !r.Options.AllowPanics {
+			fmt.Fprintf(w, "golo: not fixing %s, as %d %s would panic (use -allow-panics to fix it anyway)\n", name, n, plural(n, "error", "errors"))
+			status = 1
+			continue
+		}
+
+		content, err := r.fixedContent(file)
+		if err != nil {
+			return 1, err
+		}
+		if formatted, err := format.Source(content); err == nil {
+			content = formatted
+		}
+		original, err := os.ReadFile(file)
+		if err != nil {
+			return 1, err
+		}
+		if hash := sha256.Sum256(original); hex.EncodeToString(hash[:]) != r.originals[file] {
+			fmt.Fprintf(w, "golo: not fixing %s, as it has changed since golo read it\n", name)
+			status = 1
+			continue
+		}
+		content = restoreBOM(original, content)
+
+		if r.Options.Diff {
+			fmt.Fprint(w, unifiedDiff(filepath.ToSlash(name), original, content))
+			continue
+		}
+		// this is the only time golo writes to a source file
+		r.sandbox.allow(file)
+		if err := r.sandbox.writeFile(file, content, 0o666); err != nil {
+			return 1, err
+		}
+		fmt.Fprintf(w, "golo: fixed %s (%s)\n", name, strings.Join(kinds[file], ", "))
+	}
+	return status, nil
+}
+
+// printDiff prints a unified diff from each file on disk to the version golo fixed, as used by
+// -diff (and golo diff) in the modes that would otherwise run something. It returns 1 if there
+// were any fixes, and 0 if not.
+func (r *Runner) printDiff(w io.Writer) (int, error) {
+	status := 0
+	if !r.built {
+		fmt.Fprintln(r.log(r.stderr()), "golo: not every error could be fixed (run go build to see them)")
+		status = 1
+	}
+	for _, file := range r.fixedFiles() {
+		content, err := r.fixedContent(file)
+		if err != nil {
+			return 1, err
+		}
+		// files that golo adds (like export shims) are diffed against an empty file
+		original, err := os.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return 1, err
+		}
+		content = restoreBOM(original, content)
+		fmt.Fprint(w, unifiedDiff(filepath.ToSlash(relativePath(file)), original, content))
+		status = 1
+	}
+	return status, nil
+}
+
+// fixedFiles returns the names of the files that golo fixed, in order.
+func (r *Runner) fixedFiles() []string {
+	files := append(maps.Keys(r.fixed), maps.Keys(r.spilled)...)
+	slices.Sort(files)
+	return files
+}
+
+// fixedContent returns the fixed version of file, which may have been spilled to disk.
+func (r *Runner) fixedContent(file string) ([]byte, error) {
+	if content, ok := r.fixed[file]; ok {
+		return content, nil
+	}
+	return os.ReadFile(r.spilled[file].Path)
+}
+
+// readFixed returns the content of file that golo last compiled (fixed or not).
+func (r *Runner) readFixed(file string) ([]byte, error) {
+	if content, ok := r.fixed[file]; ok {
+		return content, nil
+	}
+	if s, ok := r.spilled[file]; ok {
+		return os.ReadFile(s.Path)
+	}
+	return os.ReadFile(file)
+}
+
+// relativePath returns file relative to the current directory (if it is inside it).
+func relativePath(file string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+	if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return file
+}
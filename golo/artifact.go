@@ -0,0 +1,93 @@
+package golo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Artifact is a binary built by golo, copied to Options.ArtifactDir.
+type Artifact struct {
+	Path   string
+	SHA256 string
+}
+
+// saveArtifact copies the binary that was built to r.Options.ArtifactDir so that it can be
+// used after golo exits (the temporary copy is deleted). The binary built while fixing
+// is the same as the one the go command builds when running, as they use the same overlay.
+func (r *Runner) saveArtifact() (*Artifact, error) {
+	if r.Options.ArtifactDir == "" || !r.built || r.mode == "ci" {
+		return nil, nil
+	}
+
+	src := r.exeFile
+	name, err := r.artifactName()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.sandbox.mkdirAll(r.Options.ArtifactDir, 0o777); err != nil {
+		return nil, err
+	}
+	dst := filepath.Join(r.Options.ArtifactDir, name)
+
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("could not find the built binary: %w", err)
+	}
+	defer in.Close()
+	out, err := r.sandbox.openFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o777)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), in); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	return &Artifact{Path: dst, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// artifactName returns a name for the built binary that includes the package and the
+// platform it was built for, e.g. example.com_cmd_x-linux-amd64 or example.com_pkg-linux-amd64.test
+func (r *Runner) artifactName() (string, error) {
+	flags, pkgs := splitFlags(r.buildArgs)
+	// go test accepts test flags after the packages
+	for i, arg := range pkgs {
+		if strings.HasPrefix(arg, "-") {
+			pkgs = pkgs[:i]
+			break
+		}
+	}
+	list := r.goCommand(append(append([]string{"list", "-f", "{{.ImportPath}}"}, withoutOutputFlag(flags)...), pkgs...)...)
+	out, err := list.Output()
+	if err != nil {
+		return "", fmt.Errorf("go list failed: %w", err)
+	}
+	pkg := strings.Fields(string(out))
+	if len(pkg) != 1 {
+		return "", fmt.Errorf("can only save the binary for a single package, not %v", pkg)
+	}
+
+	env, err := r.goCommand("env", "GOOS", "GOARCH", "GOEXE").Output()
+	if err != nil {
+		return "", fmt.Errorf("go env failed: %w", err)
+	}
+	// GOEXE is usually empty, so the lines must be split rather than trimmed
+	platform := strings.Split(string(env), "\n")
+	if len(platform) < 3 {
+		return "", fmt.Errorf("unexpected output from go env: %q", env)
+	}
+
+	name := strings.ReplaceAll(pkg[0], "/", "_") + "-" + platform[0] + "-" + platform[1]
+	if r.mode == "test" {
+		name += ".test"
+	}
+	return name + platform[2], nil
+}
@@ -0,0 +1,34 @@
+package golo
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// utf8BOM is the byte order mark that some editors put at the start of UTF-8 files. The go
+// command skips it, but it shifts every offset on the first line, so golo removes it when it
+// reads a file (all of golo's offsets are into the content without it), and puts it back when
+// it writes the file.
+var utf8BOM = []byte("\ufeff")
+
+// stripBOM returns content without its byte order mark.
+func stripBOM(content []byte) []byte {
+	return bytes.TrimPrefix(content, utf8BOM)
+}
+
+// restoreBOM returns fixed with a byte order mark if original started with one.
+func restoreBOM(original, fixed []byte) []byte {
+	if bytes.HasPrefix(original, utf8BOM) && !bytes.HasPrefix(fixed, utf8BOM) {
+		return append(append([]byte{}, utf8BOM...), fixed...)
+	}
+	return fixed
+}
+
+// checkEncoding returns an error if content starts with a UTF-16 byte order mark. Go files must
+// be UTF-8, and the errors in a UTF-16 file are not worth trying to fix.
+func checkEncoding(filename string, content []byte) error {
+	if bytes.HasPrefix(content, []byte{0xff, 0xfe}) || bytes.HasPrefix(content, []byte{0xfe, 0xff}) {
+		return fmt.Errorf("%s is encoded as UTF-16, but Go source files must be UTF-8", filename)
+	}
+	return nil
+}
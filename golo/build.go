@@ -0,0 +1,78 @@
+package golo
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// BuildResult is what Build built, for tools that embed golo and run the binary themselves.
+type BuildResult struct {
+	// Binary is the binary that was built (the test binary in test mode). It is in golo's
+	// temporary directory, so it must be copied or run before Close (or Run) is called. It is empty if
+	// nothing was built: in ci and fix modes, or in test mode for more than one package.
+	Binary string
+	// Overlay is the -overlay file that makes the go command build the fixed files instead
+	// of the originals, or "" if nothing was fixed.
+	Overlay string
+	// Fixed maps each file that was fixed to its fixed content.
+	Fixed map[string][]byte
+	// Deferred lists the errors that were deferred until runtime.
+	Deferred []Fix
+	// Report lists all the fixes (as written by Options.ReportFile).
+	Report *Report
+	// Unfixed lists the errors that golo could not fix, if it couldn't build the binary.
+	Unfixed []packages.Error
+}
+
+// Build fixes and builds the packages (calling Prepare, if it hasn't been called already)
+// without running anything, and returns what it built. If golo can't fix every error, the
+// result lists the ones it couldn't along with an error. If ctx is done before Build starts,
+// it returns ctx.Err().
+func (r *Runner) Build(ctx context.Context) (BuildResult, error) {
+	if err := ctx.Err(); err != nil {
+		return BuildResult{}, err
+	}
+	// Prepare sets up the temporary directory before anything else
+	if r.tempDir == "" {
+		if err := r.PrepareContext(ctx); err != nil {
+			return BuildResult{}, err
+		}
+	}
+
+	res := BuildResult{
+		Overlay: r.overlayFile,
+		Fixed:   map[string][]byte{},
+		Report:  newReport(r.applied),
+		Unfixed: uniqueErrors(r.unfixed),
+	}
+	for file := range r.fixed {
+		res.Fixed[file] = r.fixed[file]
+	}
+	for file := range r.spilled {
+		content, err := r.readFixed(file)
+		if err != nil {
+			return BuildResult{}, err
+		}
+		res.Fixed[file] = content
+	}
+	for _, fix := range r.applied {
+		if fix.Kind == Deferred {
+			res.Deferred = append(res.Deferred, fix)
+		}
+	}
+
+	if r.mode == "ci" || r.mode == "fix" {
+		return res, nil
+	}
+	if !r.built {
+		return res, fmt.Errorf("could not fix %d %s", len(res.Unfixed), plural(len(res.Unfixed), "error", "errors"))
+	}
+	// go test -c doesn't write a binary for packages without tests (or for more than one package)
+	if info, err := os.Stat(r.exeFile); err == nil && info.Size() > 0 {
+		res.Binary = r.exeFile
+	}
+	return res, nil
+}
@@ -0,0 +1,55 @@
+package golo
+
+import (
+	"context"
+	"go/build"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// buildConfig decides which files are part of the build: the build flags (like -tags), and
+// the environment that the go command runs in (like GOOS, GOARCH and GOFLAGS). The probe,
+// the Fixer and the go command that finally builds (or runs) the packages all share one,
+// so that golo fixes the files that are being built, and not those for another platform.
+type buildConfig struct {
+	flags []string // as passed to go build (including -o and -C)
+	env   []string
+
+	once    sync.Once
+	context build.Context
+}
+
+// newBuildConfig returns the configuration for a build with flags.
+func newBuildConfig(options Options, flags []string) *buildConfig {
+	return &buildConfig{flags: flags, env: goEnv(options)}
+}
+
+// command returns a command that runs go with args in the build's environment (which is
+// killed if ctx is done before it exits).
+func (b *buildConfig) command(ctx context.Context, args ...string) *exec.Cmd {
+	return goCommandEnv(ctx, b.env, args...)
+}
+
+// buildContext returns a build.Context that matches files in the same way as the go command
+// does for this build (build.Default is for the platform golo is running on, and has no tags).
+func (b *buildConfig) buildContext() build.Context {
+	b.once.Do(func() {
+		b.context = build.Default
+		out, err := b.command(context.Background(), "env", "GOOS", "GOARCH", "CGO_ENABLED", "GOFLAGS").Output()
+		env := strings.Split(string(out), "\n")
+		if err != nil || len(env) < 4 {
+			return
+		}
+		b.context.GOOS, b.context.GOARCH = env[0], env[1]
+		b.context.CgoEnabled = env[2] == "1"
+		// -tags on the command line overrides any in GOFLAGS
+		flags, _ := loadFlags(append(strings.Fields(env[3]), b.flags...))
+		for _, flag := range flags {
+			if tags, ok := strings.CutPrefix(flag, "-tags="); ok {
+				b.context.BuildTags = strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == ' ' })
+			}
+		}
+	})
+	return b.context
+}
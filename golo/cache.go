@@ -0,0 +1,87 @@
+package golo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// cacheEnv are the go env variables that change what the go command builds, so a fix made with
+// one value isn't reused with another.
+var cacheEnv = []string{"GOVERSION", "GOROOT", "GOPATH", "GOMOD", "GOWORK", "GOOS", "GOARCH", "GOFLAGS", "GOEXPERIMENT", "CGO_ENABLED"}
+
+// fixCacheFile returns the file in the fix cache for this run, which is named after the
+// command, the options that change how errors are fixed, the go command and golo itself, and
+// the errors that go reported before anything was fixed. The fixes in it are only reused if
+// none of the files they were made in have changed since (see loadFixCache).
+func (r *Runner) fixCacheFile() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%q\n", r.mode, r.buildArgs)
+	if wd, err := os.Getwd(); err == nil {
+		fmt.Fprintln(h, wd)
+	}
+	if exe, err := os.Executable(); err == nil {
+		if info, err := os.Stat(exe); err == nil {
+			fmt.Fprintln(h, exe, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	env, _ := r.goCommand(append([]string{"env"}, cacheEnv...)...).Output()
+	h.Write(env)
+
+	// the options that only change what golo reports don't change the fixes
+	o := r.Options
+	o.ResumeDir, o.ReportFile, o.GitHubReport, o.Baseline, o.MaxNewDeferrals = "", "", "", "", 0
+	o.Diff, o.JSON, o.ArtifactDir, o.Keep = false, false, "", false
+	options, _ := json.Marshal(o)
+	h.Write(options)
+
+	h.Write(r.probed)
+	return filepath.Join(r.fixCache, hex.EncodeToString(h.Sum(nil))+".json")
+}
+
+// loadFixCache reuses the fixes in the fix cache, if there are any for this run, instead of
+// making them again. The build that follows checks that they still work.
+func (r *Runner) loadFixCache(file string, fixer *Fixer) (bool, error) {
+	cp, err := readCheckpoint(file)
+	if cp == nil || err != nil {
+		return false, nil
+	}
+	if changed := cp.changed(); changed != "" {
+		if r.verbose {
+			fmt.Fprintln(r.log(r.stdout()), "golo: "+changed+" has changed since the fixes were cached")
+		}
+		return false, nil
+	}
+	// (Clean removes the files in the cache one at a time)
+	if missing := cp.missing(file); missing != "" {
+		if r.verbose {
+			fmt.Fprintln(r.log(r.stdout()), "golo: "+missing+" has been removed from the cache")
+		}
+		return false, nil
+	}
+	fmt.Fprintf(r.log(r.stdout()), "golo: reusing %d fixes from the cache (-nocache fixes the errors again)\n", len(cp.Applied))
+	if err := r.restoreCheckpoint(file, cp, fixer); err != nil {
+		return false, err
+	}
+	fixer.replay(cp.Applied)
+	// so that the files are still checked when the cache is updated
+	fixer.sources = map[string]bool{}
+	for file := range cp.Sources {
+		fixer.sources[file] = true
+	}
+	return true, nil
+}
+
+// saveFixCache writes the fixes to the fix cache, so that the next run can reuse them.
+// Failing to do so doesn't stop the run, so it is only logged.
+func (r *Runner) saveFixCache(file string, fixer *Fixer) {
+	if len(fixer.Applied) == 0 {
+		return
+	}
+	if err := r.writeCheckpoint(file, fixer); err != nil && r.verbose {
+		fmt.Fprintln(r.log(r.stdout()), "golo: could not cache the fixes:", err)
+	}
+}
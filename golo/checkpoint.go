@@ -0,0 +1,193 @@
+package golo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+const checkpointFile = "checkpoint.json"
+
+// checkpoint is the progress of an interrupted Prepare, saved so that it can be resumed.
+type checkpoint struct {
+	Mode      string
+	BuildArgs []string
+
+	// Sources maps every source file that was loaded to the sha256 of its content.
+	Sources map[string]string
+	Fixed   map[string][]byte
+	// Spilled maps the fixed files that are too large to keep in memory to the file next to
+	// the checkpoint that their content is saved in, so that they needn't be read back into
+	// memory to be saved (or loaded).
+	Spilled map[string]string
+	Applied []Fix
+}
+
+// saveCheckpoint writes the current progress to dir.
+func (r *Runner) saveCheckpoint(dir string, fixer *Fixer) error {
+	if err := r.sandbox.mkdirAll(dir, 0o777); err != nil {
+		return err
+	}
+	return r.writeCheckpoint(filepath.Join(dir, checkpointFile), fixer)
+}
+
+// writeCheckpoint writes the current progress to file. The files that were spilled (or would
+// be) are saved next to it, one at a time.
+func (r *Runner) writeCheckpoint(file string, fixer *Fixer) error {
+	cp := &checkpoint{
+		Mode:      r.mode,
+		BuildArgs: r.buildArgs,
+		Sources:   map[string]string{},
+		Fixed:     map[string][]byte{},
+		Spilled:   map[string]string{},
+		Applied:   fixer.Applied,
+	}
+	prefix := strings.TrimSuffix(file, filepath.Ext(file)) + "-"
+	save := func(filename string, content []byte) error {
+		saved := prefix + filepath.Base(fixer.spillPath(filename))
+		if err := r.replaceFile(saved, content); err != nil {
+			return err
+		}
+		cp.Spilled[filename] = filepath.Base(saved)
+		return nil
+	}
+	for filename, content := range r.fixed {
+		if len(content) < spillThreshold {
+			cp.Fixed[filename] = content
+		} else if err := save(filename, content); err != nil {
+			return err
+		}
+	}
+	for filename := range r.spilled {
+		content, _, err := fixer.readSpilled(filename)
+		if err != nil {
+			return err
+		}
+		if err := save(filename, content); err != nil {
+			return err
+		}
+	}
+	for file := range fixer.sources {
+		hash, err := hashFile(file)
+		if err != nil {
+			return err
+		}
+		cp.Sources[file] = hash
+	}
+
+	content, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return r.replaceFile(file, content)
+}
+
+// replaceFile writes content to file and then renames it into place, so that being
+// interrupted mid-write doesn't lose what was there before.
+func (r *Runner) replaceFile(file string, content []byte) error {
+	tmp := file + ".tmp"
+	if err := r.sandbox.writeFile(tmp, content, 0o666); err != nil {
+		return err
+	}
+	return r.sandbox.rename(tmp, file)
+}
+
+// restoreCheckpoint makes the files fixed in cp (which was read from file) the fixed files
+// again. Large files are spilled, as they were when they were fixed, instead of being
+// loaded into memory.
+func (r *Runner) restoreCheckpoint(file string, cp *checkpoint, fixer *Fixer) error {
+	for filename, content := range cp.Fixed {
+		if len(content) < spillThreshold {
+			r.fixed[filename] = content
+		} else if err := fixer.spillFile(filename, content); err != nil {
+			return err
+		}
+	}
+	for filename, saved := range cp.Spilled {
+		content, err := os.ReadFile(filepath.Join(filepath.Dir(file), filepath.Base(saved)))
+		if err != nil {
+			return err
+		}
+		if err := fixer.spillFile(filename, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// missing returns a file that cp's spilled files were saved in (next to file), but that no
+// longer exists (or "" if they all do).
+func (cp *checkpoint) missing(file string) string {
+	for _, saved := range cp.Spilled {
+		path := filepath.Join(filepath.Dir(file), filepath.Base(saved))
+		if _, err := os.Stat(path); err != nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadCheckpoint reads the progress saved in dir.
+// It returns nil if there is no checkpoint, or if it is no longer valid
+// because the source files or arguments have changed since it was written.
+func (r *Runner) loadCheckpoint(dir string) (*checkpoint, error) {
+	cp, err := readCheckpoint(filepath.Join(dir, checkpointFile))
+	if cp == nil || err != nil {
+		return nil, err
+	}
+	if cp.Mode != r.mode || !slices.Equal(cp.BuildArgs, r.buildArgs) {
+		fmt.Fprintln(r.log(r.stdout()), "golo: checkpoint in "+dir+" is for a different command, starting again")
+		return nil, nil
+	}
+	if file := cp.changed(); file != "" {
+		fmt.Fprintln(r.log(r.stdout()), "golo: "+file+" has changed since the checkpoint in "+dir+" was saved, starting again")
+		return nil, nil
+	}
+	if file := cp.missing(filepath.Join(dir, checkpointFile)); file != "" {
+		fmt.Fprintln(r.log(r.stdout()), "golo: "+file+" is missing from the checkpoint in "+dir+", starting again")
+		return nil, nil
+	}
+	return cp, nil
+}
+
+// readCheckpoint reads the checkpoint in file, or returns nil if there isn't one.
+func readCheckpoint(file string) (*checkpoint, error) {
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp := &checkpoint{}
+	if err := json.Unmarshal(content, cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", file, err)
+	}
+	return cp, nil
+}
+
+// changed returns a source file that has changed since cp was saved (or "" if none have).
+func (cp *checkpoint) changed() string {
+	for file, hash := range cp.Sources {
+		if h, err := hashFile(file); err != nil || h != hash {
+			return file
+		}
+	}
+	return ""
+}
+
+func hashFile(filename string) (string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,130 @@
+package golo
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// analyze fixes the packages without building or running them, as used by ci mode.
+func (r *Runner) analyze(fixer *Fixer) error {
+	fixer.mode = "test"
+	if err := fixer.FixContext(r.context(), r.buildArgs...); err != nil {
+		return err
+	}
+	r.unfixed = fixer.unfixed
+	r.built = len(r.unfixed) == 0
+	return nil
+}
+
+// annotate writes GitHub Actions annotations for the fixes (see writeAnnotations).
+// It returns 1 if the run should fail, and 0 otherwise.
+func (r *Runner) annotate(w io.Writer) int {
+	report := newReport(r.applied)
+	report.Unmatched = r.unmatched
+	writeAnnotations(w, &Outcome{Report: report, Added: r.added, Unfixed: uniqueErrors(r.unfixed)})
+
+	max := r.Options.MaxNewDeferrals
+	if max < 0 {
+		max = 0
+	}
+	if len(r.unfixed) > 0 || len(r.unmatched) > 0 || (r.Options.Baseline != "" && len(r.added) > max) {
+		return 1
+	}
+	return 0
+}
+
+// writeAnnotations writes GitHub Actions annotations for the deferrals that golo made (as warnings),
+// for the other fixes that were not Safe (as notices, with their confidence), and for new
+// deferrals and errors that golo could not fix (as errors).
+func writeAnnotations(w io.Writer, o *Outcome) {
+	isNew := map[token.Position]bool{}
+	for _, fix := range o.Added {
+		isNew[fix.Pos] = true
+	}
+
+	for _, fix := range o.Report.Deferrals() {
+		level := "warning"
+		title := "golo deferred this error until runtime"
+		if isNew[fix.Pos] {
+			level = "error"
+			title = "golo deferred a new error until runtime"
+		}
+		writeAnnotation(w, level, fix.Pos.Filename, fix.Pos.Line, fix.Pos.Column, title, fix.Msg)
+	}
+
+	// fixes that change what the code does are worth a look, particularly if golo was guessing.
+	for _, fix := range o.Report.Fixes {
+		if fix.Kind == Deferred || fix.Confidence == 0 || fix.Confidence == Safe {
+			continue
+		}
+		title := fmt.Sprintf("golo fixed this error (%s: %s)", fix.Kind, fix.Confidence)
+		msg := fix.Msg
+		if fix.Note != "" {
+			msg += "\n" + fix.Note
+		}
+		writeAnnotation(w, "notice", fix.Pos.Filename, fix.Pos.Line, fix.Pos.Column, title, msg)
+	}
+
+	wd, _ := os.Getwd()
+	for _, e := range o.Unfixed {
+		file, line, col := splitPos(e.Pos)
+		if rel, err := filepath.Rel(wd, file); err == nil && wd != "" && file != "" {
+			file = filepath.ToSlash(rel)
+		}
+		writeAnnotation(w, "error", file, line, col, "golo could not fix this error", e.Msg)
+	}
+	for _, e := range o.Report.Unmatched {
+		writeAnnotation(w, "error", "", 0, 0, "golo could not find "+e.Pattern, e.Err)
+	}
+}
+
+// writeAnnotation writes a GitHub Actions workflow command for a message about a file.
+func writeAnnotation(w io.Writer, level, file string, line, col int, title, msg string) {
+	props := []string{}
+	if file != "" {
+		props = append(props, "file="+escapeProperty(file))
+	}
+	if line > 0 {
+		props = append(props, "line="+strconv.Itoa(line))
+	}
+	if col > 0 {
+		props = append(props, "col="+strconv.Itoa(col))
+	}
+	props = append(props, "title="+escapeProperty(title))
+	fmt.Fprintf(w, "::%s %s::%s\n", level, strings.Join(props, ","), escapeData(msg))
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// splitPos splits a position of the form file:line:col (as used by packages.Error).
+func splitPos(pos string) (string, int, int) {
+	parts := strings.Split(pos, ":")
+	nums := []int{}
+	for len(parts) > 1 && len(nums) < 2 {
+		n, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			break
+		}
+		nums = append([]int{n}, nums...)
+		parts = parts[:len(parts)-1]
+	}
+	file := strings.Join(parts, ":")
+	switch len(nums) {
+	case 2:
+		return file, nums[0], nums[1]
+	case 1:
+		return file, nums[0], 0
+	}
+	return file, 0, 0
+}
@@ -0,0 +1,150 @@
+package golo
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"sync"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/tools/go/packages"
+)
+
+// pkgFix is the result of fixing the type errors in a package with a child Fixer.
+type pkgFix struct {
+	child *Fixer
+	out   bytes.Buffer
+	// base is f.Fixed as it was when the child was created.
+	base map[string][]byte
+	// applied is the number of fixes that the child started with.
+	applied int
+
+	fixed bool
+	err   error
+}
+
+// fixPkgs fixes the type errors in each package concurrently. Each package is fixed by a
+// child Fixer that sees the files as they were at the start of the iteration, and the results
+// are merged in order by mergePkg, so that the outcome is the same as fixing them one by one.
+// It returns nil results if the packages should be fixed one by one anyway.
+func (f *Fixer) fixPkgs(pkgs []*packages.Package) []*pkgFix {
+	results := make([]*pkgFix, len(pkgs))
+	workers := f.workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers == 1 || len(pkgs) < 2 {
+		return results
+	}
+
+	base := maps.Clone(f.Fixed)
+	sem := make(chan struct{}, workers)
+	wg := sync.WaitGroup{}
+	for i, pkg := range pkgs {
+		if len(pkg.TypeErrors) == 0 {
+			continue
+		}
+		r := &pkgFix{base: base, applied: len(f.Applied)}
+		r.child = f.child(&r.out)
+		results[i] = r
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(pkg *packages.Package) {
+			defer wg.Done()
+			r.fixed, r.err = r.child.fixPkg(pkg)
+			<-sem
+		}(pkg)
+	}
+	wg.Wait()
+	return results
+}
+
+// child returns a Fixer that can fix a package concurrently with f's other children,
+// writing its output to out.
+func (f *Fixer) child(out *bytes.Buffer) *Fixer {
+	return &Fixer{
+		mode:    f.mode,
+		verbose: f.verbose,
+		Fixed:   maps.Clone(f.Fixed),
+		// the child's fixes are appended to a copy
+		Applied: f.Applied[:len(f.Applied):len(f.Applied)],
+
+		options:   f.options,
+		config:    f.config,
+		build:     f.build,
+		ctx:       f.ctx,
+		out:       out,
+		warned:    maps.Clone(f.warned),
+		attempted: maps.Clone(f.attempted),
+		changed:   map[string]bool{},
+
+		sandbox:  f.sandbox,
+		spillDir: f.spillDir,
+		spilled:  maps.Clone(f.spilled),
+
+		parent:  f,
+		touched: map[string]bool{},
+	}
+}
+
+// mergePkg merges the result of fixing pkg concurrently into f. If a file that the child used
+// has been changed since the iteration started (by fixing an earlier package that shares it),
+// the result is out of date, so pkg is fixed again instead.
+func (f *Fixer) mergePkg(pkg *packages.Package, r *pkgFix) (bool, error) {
+	if r == nil || r.child.rerun {
+		return f.fixPkg(pkg)
+	}
+	for filename := range r.child.touched {
+		content, ok := f.Fixed[filename]
+		old, wasFixed := r.base[filename]
+		if f.changed[filename] || ok != wasFixed || !bytes.Equal(content, old) {
+			if f.verbose {
+				f.logf("golo: %s was changed while fixing %s, fixing it again", filename, pkg.PkgPath)
+			}
+			return f.fixPkg(pkg)
+		}
+	}
+
+	for filename := range r.child.touched {
+		if content, ok := r.child.Fixed[filename]; ok {
+			f.Fixed[filename] = content
+		} else {
+			delete(f.Fixed, filename)
+		}
+		if s, ok := r.child.spilled[filename]; ok {
+			f.spilled[filename] = s
+		} else {
+			delete(f.spilled, filename)
+		}
+	}
+	for filename := range r.child.changed {
+		f.changed[filename] = true
+	}
+	f.limited = f.limited || r.child.limited
+	for key := range r.child.attempted {
+		if f.attempted == nil {
+			f.attempted = map[string]bool{}
+		}
+		f.attempted[key] = true
+	}
+	for key := range r.child.warned {
+		if f.warned == nil {
+			f.warned = map[string]bool{}
+		}
+		f.warned[key] = true
+	}
+	f.Applied = append(f.Applied, r.child.Applied[r.applied:]...)
+	if f.onFix != nil {
+		for _, fix := range r.child.Applied[r.applied:] {
+			f.onFix(fix)
+		}
+	}
+
+	out := f.out
+	if out == nil {
+		out = os.Stdout
+	}
+	out.Write(r.out.Bytes())
+	return r.fixed, r.err
+}
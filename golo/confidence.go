@@ -0,0 +1,59 @@
+package golo
+
+import "fmt"
+
+// Confidence describes how sure golo is that a fix does what the code was meant to do.
+type Confidence int
+
+const (
+	// Guessing fixes change what the code does to something that is probably what was meant,
+	// for example by renaming an undefined name or reordering arguments.
+	Guessing Confidence = iota + 1
+	// Preserving fixes change the code in a way that does not change its meaning,
+	// for example by converting between types with the same underlying type.
+	Preserving
+	// Safe fixes either change nothing that runs (like renaming an unused import to _),
+	// or defer the error until runtime.
+	Safe
+)
+
+var confidenceNames = map[Confidence]string{
+	Guessing:   "guessing",
+	Preserving: "preserving",
+	Safe:       "safe",
+}
+
+func (c Confidence) String() string {
+	if name, ok := confidenceNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Confidence(%d)", int(c))
+}
+
+// ParseConfidence parses the name of a confidence level ("safe", "preserving" or "guessing").
+func ParseConfidence(s string) (Confidence, error) {
+	for c, name := range confidenceNames {
+		if name == s {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid confidence %q (expected safe, preserving or guessing)", s)
+}
+
+func (c Confidence) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Confidence) UnmarshalText(text []byte) error {
+	parsed, err := ParseConfidence(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// allows returns true if fixes with confidence c may be used.
+func (f *Fixer) allows(c Confidence) bool {
+	return c >= f.options.MinConfidence
+}
@@ -0,0 +1,77 @@
+package golo
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+)
+
+var (
+	reUnusedImport = regexp.MustCompile(`^"([^"]+)" imported (?:as (\w+) )?and not used`)
+	reUnusedVar    = regexp.MustCompile(`^declared and not used: (\w+)`)
+)
+
+// dependencies returns the deferrals that a fix for an unused import or variable depends on.
+//
+// An import or variable that is used in the original source, but not in the fixed source,
+// is only unused because golo deferred the code that used it. If that code is later fixed
+// the import or variable is needed again, so these fixes should never outlive the deferrals
+// that caused them (and should not be written back into the source).
+func (f *Fixer) dependencies(filename string, msg string) []token.Position {
+	name := ""
+	if m := reUnusedImport.FindStringSubmatch(msg); m != nil {
+		name = m[2]
+		if name == "" {
+			name = path.Base(m[1])
+		}
+	} else if m := reUnusedVar.FindStringSubmatch(msg); m != nil {
+		name = m[1]
+	} else {
+		return nil
+	}
+
+	original, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.SkipObjectResolution)
+	if err != nil || !usesName(original, name) {
+		return nil
+	}
+
+	var deps []token.Position
+	for _, fix := range f.Applied {
+		if fix.Kind == Deferred && fix.Pos.Filename == filename {
+			deps = append(deps, fix.Pos)
+		}
+	}
+	return deps
+}
+
+// usesName returns true if name is used in file, other than where it is declared.
+func usesName(file *ast.File, name string) bool {
+	declared := map[*ast.Ident]bool{}
+	used := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ImportSpec:
+			return false
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				for _, lhs := range n.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok {
+						declared[id] = true
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for _, id := range n.Names {
+				declared[id] = true
+			}
+		case *ast.Ident:
+			if n.Name == name && !declared[n] {
+				used = true
+			}
+		}
+		return !used
+	})
+	return used
+}
@@ -0,0 +1,39 @@
+package golo
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/exp/slices"
+)
+
+// edit replaces the bytes from start to end of a file with text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// nodeEdit returns an edit that replaces n (in file) with text.
+func nodeEdit(file *ast.File, n ast.Node, text string) edit {
+	return posEdit(file, n.Pos(), n.End(), text)
+}
+
+// posEdit returns an edit that replaces the range from start to end (in file) with text.
+func posEdit(file *ast.File, start, end token.Pos, text string) edit {
+	return edit{int(start - file.FileStart), int(end - file.FileStart), text}
+}
+
+// applyEdits returns content with the (non-overlapping) edits applied.
+func applyEdits(content []byte, edits []edit) []byte {
+	edits = slices.Clone(edits)
+	slices.SortFunc(edits, func(a, b edit) bool { return a.start < b.start })
+
+	ret := make([]byte, 0, len(content))
+	last := 0
+	for _, e := range edits {
+		ret = append(ret, content[last:e.start]...)
+		ret = append(ret, e.text...)
+		last = e.end
+	}
+	return append(ret, content[last:]...)
+}
@@ -0,0 +1,223 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// maxExcerpts is the number of errors shown when golo can't fix them all.
+const maxExcerpts = 10
+
+// tabWidth is the width that tabs are expanded to in excerpts, so that the caret lines up.
+const tabWidth = 4
+
+// writeExcerpts writes the errors that golo could not fix to w, each with the line it is on
+// (and the lines either side), a caret under the column, and the reason that golo could not
+// defer it. Only the first maxExcerpts errors are shown. Lines are wrapped (or clipped) to width.
+// read returns the content that the errors were reported in.
+func writeExcerpts(w io.Writer, errs []packages.Error, read func(string) ([]byte, error), width int) {
+	unique := uniqueErrors(errs)
+	fmt.Fprintf(w, "golo: could not fix %d %s:\n\n", len(unique), plural(len(unique), "error", "errors"))
+	for i, e := range unique {
+		if i == maxExcerpts {
+			n := len(unique) - maxExcerpts
+			fmt.Fprintf(w, "\ngolo: and %d more %s (run with -v to see them all)\n", n, plural(n, "error", "errors"))
+			break
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		writeExcerpt(w, e, read, width)
+	}
+}
+
+// uniqueErrors returns errs without the ones that are repeated.
+func uniqueErrors(errs []packages.Error) []packages.Error {
+	seen := map[string]bool{}
+	unique := []packages.Error{}
+	for _, e := range errs {
+		// the go command's output repeats the errors (with the overlay's filenames)
+		if e.Kind == packages.ListError && strings.HasPrefix(e.Msg, "# ") && len(errs) > 1 {
+			continue
+		}
+		if key := e.Pos + "\x00" + e.Msg; !seen[key] {
+			seen[key] = true
+			unique = append(unique, e)
+		}
+	}
+	return unique
+}
+
+// writeExcerpt writes one error for writeExcerpts.
+func writeExcerpt(w io.Writer, e packages.Error, read func(string) ([]byte, error), width int) {
+	file, line, col := splitPos(e.Pos)
+	if file == "" || file == "-" || line == 0 {
+		writeWrapped(w, "", e.Msg, width)
+		return
+	}
+	writeWrapped(w, "", fmt.Sprintf("%s:%d:%d: %s", filepath.ToSlash(relativePath(file)), line, col, e.Msg), width)
+
+	content, err := read(file)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	if line > len(lines) {
+		return
+	}
+	gutter := len(strconv.Itoa(minInt(line+1, len(lines))))
+	for n := maxInt(line-1, 1); n <= minInt(line+1, len(lines)); n++ {
+		text := lines[n-1]
+		if n != line {
+			if text, _ = clipLine(expandTabs(text), 0, width-gutter-5); text != "" || n < line {
+				fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("  %*d | %s", gutter, n, text), " "))
+			}
+			continue
+		}
+		prefix := text
+		if col-1 <= len(text) {
+			prefix = text[:maxInt(col-1, 0)]
+		}
+		text, caret := clipLine(expandTabs(text), utf8.RuneCountInString(expandTabs(prefix)), width-gutter-5)
+		fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("> %*d | %s", gutter, n, text), " "))
+		fmt.Fprintf(w, "  %*s | %s^\n", gutter, "", strings.Repeat(" ", caret))
+	}
+
+	if reason := unfixableReason(file, content, line, col); reason != "" {
+		writeWrapped(w, "  ", "golo can't defer errors in "+reason, width)
+	}
+}
+
+// unfixableReason returns why golo could not defer the error at line:col in file, as the kind
+// of place it is in (like "a generated file"), or "" if golo can usually defer errors there.
+func unfixableReason(file string, content []byte, line, col int) string {
+	if isDependency(file) {
+		return "a dependency package"
+	}
+	if _, ok := generatedBy(content); ok {
+		return "a generated file"
+	}
+
+	fset := token.NewFileSet()
+	f, _ := parser.ParseFile(fset, file, content, parser.SkipObjectResolution)
+	if f == nil || line > fset.File(f.Pos()).LineCount() {
+		return ""
+	}
+	pos := fset.File(f.Pos()).LineStart(line) + token.Pos(maxInt(col-1, 0))
+	for _, decl := range f.Decls {
+		if pos < decl.Pos() || pos > decl.End() {
+			continue
+		}
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			switch d.Tok {
+			case token.CONST:
+				return "a package-level const"
+			case token.VAR:
+				return "a package-level var"
+			case token.TYPE:
+				return "a type declaration"
+			case token.IMPORT:
+				return "an import declaration"
+			}
+		case *ast.FuncDecl:
+			if d.Body == nil || pos < d.Body.Lbrace {
+				return "a function signature"
+			}
+		}
+	}
+	return ""
+}
+
+// isDependency returns true if file is part of the standard library, in the module cache,
+// or vendored, none of which golo changes.
+func isDependency(file string) bool {
+	file = filepath.ToSlash(file)
+	if root := filepath.ToSlash(build.Default.GOROOT); root != "" && strings.HasPrefix(file, root+"/") {
+		return true
+	}
+	modCache := os.Getenv("GOMODCACHE")
+	if modCache == "" {
+		modCache = filepath.Join(strings.Split(build.Default.GOPATH, string(filepath.ListSeparator))[0], "pkg", "mod")
+	}
+	return strings.HasPrefix(file, filepath.ToSlash(modCache)+"/") || strings.Contains(file, "/vendor/")
+}
+
+// expandTabs replaces tabs with spaces, so that the caret under a line lines up however the
+// terminal displays tabs.
+func expandTabs(s string) string {
+	b := &strings.Builder{}
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := tabWidth - col%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// clipLine shortens s to fit in width (if it doesn't), keeping the rune at caret visible.
+// It returns the new line, and where caret is in it.
+func clipLine(s string, caret int, width int) (string, int) {
+	runes := []rune(strings.TrimRight(s, " "))
+	if len(runes) <= width || width < 10 {
+		return string(runes), caret
+	}
+	start := 0
+	if caret >= width-1 {
+		start = minInt(caret-width/2, len(runes)-width)
+	}
+	clipped := append([]rune{}, runes[start:start+width]...)
+	if start > 0 {
+		clipped[0] = '…'
+	}
+	if start+width < len(runes) {
+		clipped[width-1] = '…'
+	}
+	return string(clipped), caret - start
+}
+
+// writeWrapped writes msg to w, wrapping each of its lines at spaces so that they fit in
+// width, and indenting every line with indent.
+func writeWrapped(w io.Writer, indent string, msg string, width int) {
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.ReplaceAll(line, "\t", "  ")
+		cur := indent
+		for i, word := range strings.Split(line, " ") {
+			if i > 0 && utf8.RuneCountInString(cur)+1+utf8.RuneCountInString(word) > width && strings.TrimSpace(cur) != "" {
+				fmt.Fprintln(w, cur)
+				cur = indent + "  " + word
+				continue
+			}
+			if i > 0 {
+				cur += " "
+			}
+			cur += word
+		}
+		fmt.Fprintln(w, cur)
+	}
+}
+
+// terminalWidth returns the width to wrap excerpts at.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
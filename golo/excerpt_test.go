@@ -0,0 +1,101 @@
+package golo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestWriteExcerpts(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	a := filepath.Join(dir, "a.go")
+	generated := filepath.Join(dir, "a_string.go")
+	dependency := filepath.Join(dir, "vendor", "example.com", "fmt", "print.go")
+	files := map[string]string{
+		a:          "package a\n\nconst (\n\tx = 1\n\ty = \"two\" + x\n)\n\nfunc F(s Strin) {\n\tif true {\n\t\tfmt.Println(\"this line is much too long to fit in the terminal\", undefinedName)\n\t}\n}\n",
+		generated:  "// Code generated by \"stringer -type=A\"; DO NOT EDIT.\n\npackage a\n\nfunc (a A) String() string {\n\treturn \"A\" + a\n}\n",
+		dependency: "package fmt\n\nfunc Println(a ...any) (n int, err error) {\n\treturn Fprintln(os.Stdout, a...)\n}\n",
+	}
+	read := func(file string) ([]byte, error) {
+		if content, ok := files[file]; ok {
+			return []byte(content), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	errs := []packages.Error{
+		{Pos: a + ":5:6", Msg: `invalid operation: "two" + x (mismatched types untyped string and untyped int)`},
+		{Pos: a + ":8:10", Msg: "undefined: Strin"},
+		{Pos: a + ":10:68", Msg: "undefined: undefinedName"},
+		{Pos: generated + ":6:9", Msg: `invalid operation: "A" + a (mismatched types untyped string and A)`},
+		{Pos: dependency + ":4:18", Msg: "undefined: os"},
+		{Pos: "-", Msg: "import cycle not allowed"},
+		{Kind: packages.ListError, Msg: "# a\n/tmp/overlay/a.go:5:6: invalid operation"},
+	}
+	// errors are sometimes reported by more than one package
+	errs = append(errs, errs[0])
+	for i := 0; i < maxExcerpts-2; i++ {
+		errs = append(errs, packages.Error{Msg: fmt.Sprintf("error %d", i)})
+	}
+
+	out := &bytes.Buffer{}
+	writeExcerpts(out, errs, read, 60)
+	actual := out.String()
+	expected := `golo: could not fix 14 errors:
+
+a.go:5:6: invalid operation: "two" + x (mismatched types
+  untyped string and untyped int)
+  4 |     x = 1
+> 5 |     y = "two" + x
+    |         ^
+  6 | )
+  golo can't defer errors in a package-level const
+
+a.go:8:10: undefined: Strin
+  7 |
+> 8 | func F(s Strin) {
+    |          ^
+  9 |     if true {
+  golo can't defer errors in a function signature
+
+a.go:10:68: undefined: undefinedName
+   9 |     if true {
+> 10 | …uch too long to fit in the terminal", undefinedName)
+     |                                        ^
+  11 |     }
+
+a_string.go:6:9: invalid operation: "A" + a (mismatched
+  types untyped string and A)
+  5 | func (a A) String() string {
+> 6 |     return "A" + a
+    |            ^
+  7 | }
+  golo can't defer errors in a generated file
+
+vendor/example.com/fmt/print.go:4:18: undefined: os
+  3 | func Println(a ...any) (n int, err error) {
+> 4 |     return Fprintln(os.Stdout, a...)
+    |                     ^
+  5 | }
+  golo can't defer errors in a dependency package
+
+import cycle not allowed
+
+error 0
+
+error 1
+
+error 2
+
+error 3
+
+golo: and 4 more errors (run with -v to see them all)
+`
+	if actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
@@ -0,0 +1,97 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixAddress fixes attempts to take the address of something that isn't addressable
+// (like &m["key"] or &f()) by assigning it to a temporary variable before the statement,
+// and taking the address of that instead.
+//
+// For map elements this changes what the code does, as changes made through the pointer
+// no longer change the map, so the fix is noted. If there's nowhere to put the temporary
+// variable just the expression is deferred.
+func (f *Fixer) fixAddress(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) (FixKind, Confidence) {
+	if !strings.HasPrefix(msg, "invalid operation: cannot take address of ") {
+		return "", 0
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var addr *ast.UnaryExpr
+	var stmt ast.Stmt
+	for i, n := range path {
+		if n, ok := n.(*ast.UnaryExpr); ok && addr == nil && n.Op == token.AND && n.X.Pos() == pos {
+			addr = n
+		}
+		if _, ok := n.(*ast.FuncLit); ok && addr != nil {
+			break
+		}
+		if s, ok := n.(ast.Stmt); ok && addr != nil {
+			if canHoistFrom(s, path[i+1]) {
+				stmt = s
+			}
+			break
+		}
+	}
+	if addr == nil {
+		return "", 0
+	}
+
+	value := exprString(content, file, addr.X)
+	confidence := Preserving
+	if index, ok := astutil.Unparen(addr.X).(*ast.IndexExpr); ok {
+		if _, ok := underlying(pkg.TypesInfo.TypeOf(index.X)).(*types.Map); ok {
+			confidence = Guessing
+		}
+	}
+	if stmt != nil && !strings.Contains(value, "\n") && f.allows(confidence) {
+		name := unusedName(file, "tmp")
+		rewritten := applyEdits(content, []edit{
+			posEdit(file, stmt.Pos(), stmt.Pos(), name+" := "+value+"; "),
+			nodeEdit(file, addr, "&"+name),
+		})
+		if f.speculate(pkg, filename, rewritten) {
+			if confidence == Guessing {
+				f.note = fmt.Sprintf("%s is a copy of %s, so changing it won't change the map", name, value)
+				f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+			}
+			return Rewrite, confidence
+		}
+	}
+
+	x := pkg.TypesInfo.TypeOf(addr.X)
+	if x == nil {
+		return "", 0
+	}
+	t, ok := typeExpr(pkg, file, types.NewPointer(x))
+	if !ok {
+		return "", 0
+	}
+	f.replaceNode(file, filename, content, addr, f.typedPanic(t, msg))
+	return Deferred, Safe
+}
+
+// unusedName returns name, or name followed by a number, such that it is not used
+// as an identifier anywhere in file.
+func unusedName(file *ast.File, name string) string {
+	used := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			used[id.Name] = true
+		}
+		return true
+	})
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	return candidate
+}
@@ -0,0 +1,216 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixMissingArguments fixes calls that are missing arguments, which usually happens when
+// a parameter is added to a function and not every caller is updated.
+// The missing arguments are filled in with zero values (variadic parameters are optional,
+// so are left alone). If that's not possible only the call is deferred, not the whole block.
+func (f *Fixer) fixMissingArguments(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) FixKind {
+	if !strings.HasPrefix(msg, "not enough arguments in call to ") {
+		return ""
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var call *ast.CallExpr
+	var parent ast.Node
+	for i, n := range path {
+		if n, ok := n.(*ast.CallExpr); ok && n.Rparen == pos {
+			call, parent = n, path[i+1]
+			break
+		}
+	}
+	if call == nil {
+		return ""
+	}
+	sig, ok := underlying(pkg.TypesInfo.TypeOf(call.Fun)).(*types.Signature)
+	if !ok {
+		return ""
+	}
+
+	if code, imports, ok := f.padArguments(pkg, file, call, sig); ok {
+		at := call.Lparen + 1
+		if len(call.Args) > 0 {
+			at = call.Args[len(call.Args)-1].End()
+		}
+		rewritten := applyEdits(content, []edit{posEdit(file, at, at, code)})
+		for _, importPath := range imports {
+			rewritten, _ = addImport(file, rewritten, importPath)
+		}
+		f.note = fmt.Sprintf("the call to %s is passed zero values for the missing arguments (%s)", exprString(content, file, call.Fun), strings.TrimPrefix(code, ", "))
+		f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+		f.update(filename, rewritten)
+		return Rewrite
+	}
+
+	return f.deferCall(pkg, file, filename, content, call, parent, msg)
+}
+
+// padArguments returns the code to append to the arguments of call so that every
+// (non-variadic) parameter of sig has an argument, along with any imports it needs.
+func (f *Fixer) padArguments(pkg *packages.Package, file *ast.File, call *ast.CallExpr, sig *types.Signature) (string, []string, bool) {
+	params := sig.Params().Len()
+	if sig.Variadic() {
+		params--
+	}
+	if !f.allows(Guessing) || call.Ellipsis.IsValid() || len(call.Args) >= params {
+		return "", nil, false
+	}
+	// f(g()) where g returns too few values
+	if len(call.Args) == 1 {
+		if _, ok := pkg.TypesInfo.TypeOf(call.Args[0]).(*types.Tuple); ok {
+			return "", nil, false
+		}
+	}
+
+	code := ""
+	imports := []string{}
+	for i := len(call.Args); i < params; i++ {
+		zero, importPath, ok := zeroValue(pkg, file, sig.Params().At(i).Type())
+		if !ok {
+			return "", nil, false
+		}
+		if i > 0 {
+			code += ", "
+		}
+		code += zero
+		if importPath != "" && !slices.Contains(imports, importPath) {
+			imports = append(imports, importPath)
+		}
+	}
+	return code, imports, true
+}
+
+// fixExtraArguments fixes calls with too many arguments, which usually happens when a
+// parameter is removed from a function and not every caller is updated.
+// The extra arguments are dropped, but any that might have side effects are still evaluated
+// (by assigning them to _ before the statement containing the call).
+func (f *Fixer) fixExtraArguments(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) FixKind {
+	if !strings.HasPrefix(msg, "too many arguments in call to ") {
+		return ""
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var call *ast.CallExpr
+	var parent ast.Node
+	var stmt ast.Stmt
+	for i, n := range path {
+		if n, ok := n.(*ast.CallExpr); ok && call == nil && slices.IndexFunc(n.Args, func(a ast.Expr) bool { return a.Pos() == pos }) >= 0 {
+			call, parent = n, path[i+1]
+		}
+		if _, ok := n.(*ast.FuncLit); ok && call != nil {
+			break
+		}
+		if s, ok := n.(ast.Stmt); ok && call != nil {
+			if canHoistFrom(s, path[i+1]) {
+				stmt = s
+			}
+			break
+		}
+	}
+	if call == nil {
+		return ""
+	}
+	sig, ok := underlying(pkg.TypesInfo.TypeOf(call.Fun)).(*types.Signature)
+	if !ok || sig.Variadic() || call.Ellipsis.IsValid() || len(call.Args) <= sig.Params().Len() || !f.allows(Guessing) {
+		return f.deferCall(pkg, file, filename, content, call, parent, msg)
+	}
+
+	keep := sig.Params().Len()
+	extra := call.Args[keep:]
+	hoisted := ""
+	dropped := []string{}
+	for _, arg := range extra {
+		code := exprString(content, file, arg)
+		dropped = append(dropped, code)
+		if !hasSideEffects([]ast.Expr{arg}) {
+			continue
+		}
+		if stmt == nil || strings.Contains(code, "\n") {
+			return f.deferCall(pkg, file, filename, content, call, parent, msg)
+		}
+		hoisted += "_ = " + code + "; "
+	}
+
+	start := call.Lparen + 1
+	if keep > 0 {
+		start = call.Args[keep-1].End()
+	}
+	end := extra[len(extra)-1].End()
+	edits := []edit{posEdit(file, start, end, dropArguments(content[start-file.FileStart:end-file.FileStart], keep > 0))}
+	if hoisted != "" {
+		edits = append(edits, posEdit(file, stmt.Pos(), stmt.Pos(), hoisted))
+	}
+	f.note = fmt.Sprintf("dropped %s from the call to %s", strings.Join(dropped, ", "), exprString(content, file, call.Fun))
+	f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+	f.update(filename, applyEdits(content, edits))
+	return Rewrite
+}
+
+// canHoistFrom returns true if code can be inserted before stmt (whose parent is given)
+// to run once just before it.
+func canHoistFrom(stmt ast.Stmt, parent ast.Node) bool {
+	switch parent.(type) {
+	case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+	default:
+		return false
+	}
+	switch stmt.(type) {
+	case *ast.ExprStmt, *ast.AssignStmt, *ast.DeclStmt, *ast.ReturnStmt, *ast.GoStmt, *ast.DeferStmt, *ast.SendStmt, *ast.IncDecStmt:
+		return true
+	}
+	return false
+}
+
+// deferCall replaces just call with a panic, for calls that can't be fixed.
+func (f *Fixer) deferCall(pkg *packages.Package, file *ast.File, filename string, content []byte, call *ast.CallExpr, parent ast.Node, msg string) FixKind {
+	sig, ok := underlying(pkg.TypesInfo.TypeOf(call.Fun)).(*types.Signature)
+	if !ok {
+		return ""
+	}
+	if _, ok := parent.(*ast.ExprStmt); ok {
+		f.replaceNode(file, filename, content, call, fmt.Sprintf("panic(%#v)", f.panicMsg(msg)))
+		return Deferred
+	}
+	results := []string{}
+	for i := 0; i < sig.Results().Len(); i++ {
+		t, ok := typeExpr(pkg, file, sig.Results().At(i).Type())
+		if !ok {
+			return ""
+		}
+		results = append(results, t)
+	}
+	switch len(results) {
+	case 0:
+		return ""
+	case 1:
+		f.replaceNode(file, filename, content, call, f.typedPanic(results[0], msg))
+	default:
+		f.replaceNode(file, filename, content, call, f.typedPanic("("+strings.Join(results, ", ")+")", msg))
+	}
+	return Deferred
+}
+
+// dropArguments returns the code to replace removed (the source of some arguments at the end
+// of a call, and the comma before them if there are arguments before) with, so that the
+// line numbers of the rest of the file stay the same.
+func dropArguments(removed []byte, after bool) string {
+	newlines := newLinesInRange(removed)
+	// a call that ends with a newline needs a trailing comma
+	if after && newlines != "" {
+		return "," + newlines
+	}
+	return newlines
+}
@@ -0,0 +1,55 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// fixArrayLength fixes arrays with a length that is not constant by using a slice instead,
+// so that code that indexes or ranges over the array keeps working.
+// A variable declared as var buf [n]T is made with make([]T, n), so that it has the same
+// length as the array would have had.
+func (f *Fixer) fixArrayLength(file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if !strings.HasPrefix(msg, "invalid array length ") &&
+		!(strings.HasPrefix(msg, "array length ") && strings.HasSuffix(msg, " must be constant")) {
+		return false
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	for i, n := range path {
+		array, ok := n.(*ast.ArrayType)
+		if !ok || array.Len == nil || array.Len.Pos() > pos || array.Len.End() < pos {
+			continue
+		}
+		length := exprString(content, file, array.Len)
+		slice := "[]" + exprString(content, file, array.Elt)
+
+		spec, ok := path[i+1].(*ast.ValueSpec)
+		if !ok || spec.Type != array || len(spec.Values) > 0 {
+			f.note = fmt.Sprintf("[%s]%s is now a slice, so it is shared instead of copied", length, exprString(content, file, array.Elt))
+			f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+			return f.replaceNode(file, filename, content, array, slice)
+		}
+
+		names := []string{}
+		makes := []string{}
+		for _, name := range spec.Names {
+			names = append(names, name.Name)
+			makes = append(makes, "make("+slice+", "+length+")")
+		}
+		f.note = fmt.Sprintf("%s is now a slice, so it is shared instead of copied", strings.Join(names, ", "))
+		f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+
+		decl := path[i+2].(*ast.GenDecl)
+		if stmt, ok := path[i+3].(*ast.DeclStmt); ok && !decl.Lparen.IsValid() {
+			return f.replaceNode(file, filename, content, stmt, strings.Join(names, ", ")+" := "+strings.Join(makes, ", "))
+		}
+		return f.replaceNode(file, filename, content, spec, strings.Join(names, ", ")+" = "+strings.Join(makes, ", "))
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixAssignMismatch fixes assignments with a different number of variables and values,
+// which usually happens when a function gains (or loses) a result and its callers are not updated.
+//
+// Extra results of a call are assigned to _, and extra values in a list are dropped. As this
+// may silently ignore an error, a note is printed whenever it happens.
+// If there are too few values they are replaced by a call that panics, so that the variables
+// are still declared (with the right types where they are known) for the code that follows.
+func (f *Fixer) fixAssignMismatch(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) FixKind {
+	if !strings.HasPrefix(msg, "assignment mismatch: ") &&
+		!strings.HasPrefix(msg, "extra init expr") && !strings.HasPrefix(msg, "missing init expr for ") {
+		return ""
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var lhs, rhs []ast.Expr
+	var declared types.Type
+	define := false
+	for _, n := range path {
+		if n, ok := n.(*ast.AssignStmt); ok {
+			lhs, rhs, define = n.Lhs, n.Rhs, n.Tok == token.DEFINE
+			break
+		}
+		if n, ok := n.(*ast.ValueSpec); ok {
+			for _, name := range n.Names {
+				lhs = append(lhs, name)
+			}
+			rhs, define = n.Values, true
+			if n.Type != nil {
+				declared = pkg.TypesInfo.TypeOf(n.Type)
+			}
+			break
+		}
+	}
+	if len(lhs) == 0 || len(rhs) == 0 {
+		return ""
+	}
+
+	values := len(rhs)
+	var results *types.Tuple
+	if len(rhs) == 1 {
+		if t, ok := pkg.TypesInfo.TypeOf(rhs[0]).(*types.Tuple); ok {
+			results = t
+			values = t.Len()
+		}
+	}
+
+	if values > len(lhs) && f.allows(Guessing) {
+		last := lhs[len(lhs)-1]
+		if results != nil {
+			f.note = fmt.Sprintf("ignoring %d %s of %s", values-len(lhs), plural(values-len(lhs), "result", "results"), exprString(content, file, rhs[0]))
+			f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+			f.update(filename, applyEdits(content, []edit{posEdit(file, last.End(), last.End(), strings.Repeat(", _", values-len(lhs)))}))
+			return Rewrite
+		}
+		start, end := rhs[len(lhs)-1].End(), rhs[len(rhs)-1].End()
+		dropped := content[start-file.FileStart : end-file.FileStart]
+		f.note = fmt.Sprintf("ignoring %d extra %s (%s)", values-len(lhs), plural(values-len(lhs), "value", "values"), strings.Join(strings.Fields(strings.TrimPrefix(strings.TrimSpace(string(dropped)), ",")), " "))
+		f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+		f.update(filename, applyEdits(content, []edit{posEdit(file, start, end, newLinesInRange(dropped))}))
+		return Rewrite
+	}
+
+	if values < len(lhs) {
+		names := make([]string, len(lhs))
+		for i := range lhs {
+			t := declared
+			if t == nil && !define {
+				t = pkg.TypesInfo.TypeOf(lhs[i])
+			}
+			if t == nil && i < values {
+				if results != nil {
+					t = results.At(i).Type()
+				} else {
+					t = pkg.TypesInfo.TypeOf(rhs[i])
+				}
+			}
+			names[i] = "any"
+			if t != nil && t != types.Typ[types.Invalid] {
+				if s, ok := typeExpr(pkg, file, types.Default(t)); ok {
+					names[i] = s
+				}
+			}
+		}
+		start, end := rhs[0].Pos(), rhs[len(rhs)-1].End()
+		replaced := content[start-file.FileStart : end-file.FileStart]
+		code := fmt.Sprintf("func() (%s) { panic(%#v) }()", strings.Join(names, ", "), f.panicMsg(msg))
+		f.update(filename, applyEdits(content, []edit{posEdit(file, start, end, code+newLinesInRange(replaced))}))
+		return Deferred
+	}
+	return ""
+}
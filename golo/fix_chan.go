@@ -0,0 +1,192 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixMissingChanType fixes make(chan) (a syntax error) by adding a placeholder element type,
+// so that the package can be type-checked and fixChanType can work out what the type should be.
+func (f *Fixer) fixMissingChanType(file *ast.File, filename string, content []byte, offset int) bool {
+	if file == nil {
+		return false
+	}
+	pos := file.FileStart + token.Pos(offset)
+	found := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && len(call.Args) > 0 {
+			if ch, ok := call.Args[0].(*ast.ChanType); ok && isMake(call, ch) {
+				if bad, ok := ch.Value.(*ast.BadExpr); ok && bad.Pos() == pos {
+					found = true
+				}
+			}
+		}
+		return !found
+	})
+	if !found {
+		return false
+	}
+	return f.update(filename, applyEdits(content, []edit{posEdit(file, pos, pos, " _")}))
+}
+
+// fixChanType replaces the placeholder element type added by fixMissingChanType with the type
+// of the values that are sent on (or received from) the channel in the same function. If they
+// don't agree on a single type, chan struct{} is used so that the channel can still be used
+// to signal between goroutines.
+func (f *Fixer) fixChanType(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if msg != "cannot use _ as value or type" {
+		return false
+	}
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) < 3 {
+		return false
+	}
+	ch, ok := path[1].(*ast.ChanType)
+	if !ok || ch.Value != path[0] || !isMake(path[2], ch) {
+		return false
+	}
+
+	elem := "struct{}"
+	if t := f.inferChanType(pkg, file, path[2:]); t != nil {
+		if s, ok := typeExpr(pkg, file, t); ok {
+			elem = s
+		}
+	}
+	if elem == "struct{}" {
+		f.note = "could not tell what is sent on the channel, so it is now a chan struct{}"
+		f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+	}
+	return f.replaceNode(file, filename, content, ch.Value, elem)
+}
+
+// inferChanType returns the element type of the channel created by the make call at path[0],
+// or nil if the code in the same function does not use it with exactly one type.
+func (f *Fixer) inferChanType(pkg *packages.Package, file *ast.File, path []ast.Node) types.Type {
+	call := path[0]
+	var obj types.Object
+	switch n := path[1].(type) {
+	case *ast.AssignStmt:
+		for i, rhs := range n.Rhs {
+			if rhs != call || len(n.Lhs) != len(n.Rhs) {
+				continue
+			}
+			// the variable already has a type: d = make(chan)
+			if n.Tok == token.ASSIGN {
+				if t, ok := pkg.TypesInfo.TypeOf(n.Lhs[i]).(*types.Chan); ok {
+					return t.Elem()
+				}
+			}
+			if id, ok := n.Lhs[i].(*ast.Ident); ok {
+				obj = pkg.TypesInfo.ObjectOf(id)
+			}
+		}
+	case *ast.ValueSpec:
+		for i, value := range n.Values {
+			if value == call && len(n.Names) == len(n.Values) {
+				obj = pkg.TypesInfo.ObjectOf(n.Names[i])
+			}
+		}
+	}
+	if obj == nil {
+		return nil
+	}
+
+	var scope ast.Node = file
+	for _, n := range path {
+		if fn, ok := n.(*ast.FuncDecl); ok {
+			scope = fn
+		}
+	}
+	isChan := func(e ast.Expr) bool {
+		id, ok := astutil.Unparen(e).(*ast.Ident)
+		return ok && pkg.TypesInfo.ObjectOf(id) == obj
+	}
+
+	var found []types.Type
+	astutil.Apply(scope, func(c *astutil.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.SendStmt:
+			if isChan(n.Chan) {
+				found = append(found, pkg.TypesInfo.TypeOf(n.Value))
+			}
+		case *ast.UnaryExpr:
+			if n.Op != token.ARROW || !isChan(n.X) {
+				break
+			}
+			// a receive only says what the type is if it is assigned to something with a type
+			switch parent := c.Parent().(type) {
+			case *ast.AssignStmt:
+				if parent.Tok == token.ASSIGN && len(parent.Lhs) == len(parent.Rhs) {
+					found = append(found, pkg.TypesInfo.TypeOf(parent.Lhs[c.Index()]))
+				}
+			case *ast.ValueSpec:
+				if parent.Type != nil {
+					found = append(found, pkg.TypesInfo.TypeOf(parent.Type))
+				}
+			}
+		}
+		return true
+	}, nil)
+
+	var elem types.Type
+	for _, t := range found {
+		if t == nil || t == types.Typ[types.Invalid] {
+			return nil
+		}
+		t = types.Default(t)
+		if elem != nil && !types.Identical(elem, t) {
+			return nil
+		}
+		elem = t
+	}
+	return elem
+}
+
+// fixChanSize fixes make(chan T, size) where size is not an integer by making an unbuffered
+// channel instead, so that the code using the channel still runs.
+func (f *Fixer) fixChanSize(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int) bool {
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	for _, n := range path {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			continue
+		}
+		if _, ok := pkg.TypesInfo.TypeOf(call.Args[0]).(*types.Chan); !ok || !isMake(call, call.Args[0]) {
+			return false
+		}
+		size := call.Args[1]
+		if size.Pos() > pos || size.End() < pos {
+			return false
+		}
+		t := pkg.TypesInfo.TypeOf(size)
+		if t == nil || t == types.Typ[types.Invalid] {
+			return false
+		}
+		if b, ok := t.Underlying().(*types.Basic); ok && b.Info()&types.IsInteger != 0 {
+			return false
+		}
+		f.note = fmt.Sprintf("%s is not a valid buffer size, so the channel is now unbuffered (and sends block until received)",
+			strings.TrimSpace(exprString(content, file, size)))
+		f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+		return f.replaceNode(file, filename, content, size, "0")
+	}
+	return false
+}
+
+// isMake returns true if n is a call to make with typ as its first argument.
+func isMake(n ast.Node, typ ast.Expr) bool {
+	call, ok := n.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 || call.Args[0] != typ {
+		return false
+	}
+	id, ok := call.Fun.(*ast.Ident)
+	return ok && id.Name == "make"
+}
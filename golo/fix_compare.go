@@ -0,0 +1,87 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixComparison fixes == and != between slices or maps (which the go compiler only
+// allows to be compared to nil) by comparing their contents with slices.Equal,
+// maps.Equal, or reflect.DeepEqual. Any other uncomparable comparison is deferred.
+//
+// This changes what the code means (== on slices would compare identity if it were allowed),
+// so a note is printed whenever it happens.
+func (f *Fixer) fixComparison(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) FixKind {
+	if !strings.HasPrefix(msg, "invalid operation: ") ||
+		!(strings.Contains(msg, "can only be compared to nil") || strings.Contains(msg, "cannot be compared")) {
+		return ""
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var cmp *ast.BinaryExpr
+	for _, n := range path {
+		if n, ok := n.(*ast.BinaryExpr); ok && n.Pos() == pos && (n.Op == token.EQL || n.Op == token.NEQ) {
+			cmp = n
+			break
+		}
+	}
+	if cmp == nil {
+		return ""
+	}
+
+	x, y := pkg.TypesInfo.TypeOf(cmp.X), pkg.TypesInfo.TypeOf(cmp.Y)
+	if x == nil || y == nil {
+		return ""
+	}
+
+	var helpers []string
+	switch t := x.Underlying().(type) {
+	case *types.Slice:
+		if types.Comparable(t.Elem()) {
+			helpers = append(helpers, "slices.Equal")
+		}
+		helpers = append(helpers, "reflect.DeepEqual")
+	case *types.Map:
+		if types.Comparable(t.Elem()) {
+			helpers = append(helpers, "maps.Equal")
+		}
+		helpers = append(helpers, "reflect.DeepEqual")
+	}
+
+	start := int(cmp.Pos() - file.FileStart)
+	end := int(cmp.End() - file.FileStart)
+	not := ""
+	if cmp.Op == token.NEQ {
+		not = "!"
+	}
+
+	if !f.allows(Guessing) {
+		helpers = nil
+	}
+	for _, helper := range helpers {
+		pkgPath, fn, _ := strings.Cut(helper, ".")
+		rewritten := []byte(content[:start:start])
+		rewritten = append(rewritten, []byte(not+pkgPath+"."+fn+"("+exprString(content, file, cmp.X)+", "+exprString(content, file, cmp.Y)+")")...)
+		rewritten = append(rewritten, content[end:]...)
+
+		rewritten, name := addImport(file, rewritten, pkgPath)
+		if name != pkgPath {
+			continue
+		}
+		if f.speculate(pkg, filename, rewritten) {
+			f.note = fmt.Sprintf("%s now compares contents using %s (which == does not)", exprString(content, file, cmp), helper)
+			f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+			return Rewrite
+		}
+	}
+
+	f.replaceNode(file, filename, content, cmp, f.typedPanic("bool", msg))
+	return Deferred
+}
@@ -0,0 +1,118 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+var reAssignCondition = regexp.MustCompile(`^expected (boolean|boolean or range|switch) expression, found assignment`)
+
+// fixAssignCondition fixes an if, for or switch statement whose condition is an assignment
+// (x = 5) by changing it to a comparison (x == 5), which is almost always what was meant.
+// It only does so when the left side is a name (or a field) and the statement has no init
+// clause. If the statement already has one, or the right side calls a function (so that
+// if err = f() { could have been meant as if err = f(); err != nil {), the error is deferred
+// with a note saying why.
+func (f *Fixer) fixAssignCondition(file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if file == nil || !reAssignCondition.MatchString(msg) {
+		return false
+	}
+	pos := file.FileStart + token.Pos(offset)
+	var bad *ast.BadExpr
+	var stmt string
+	var init ast.Stmt
+	ast.Inspect(file, func(n ast.Node) bool {
+		if bad != nil {
+			return false
+		}
+		var cond ast.Expr
+		var s ast.Stmt
+		kind := ""
+		switch n := n.(type) {
+		case *ast.IfStmt:
+			cond, s, kind = n.Cond, n.Init, "if"
+		case *ast.ForStmt:
+			cond, s, kind = n.Cond, n.Init, "for"
+		case *ast.SwitchStmt:
+			cond, s, kind = n.Tag, n.Init, "switch"
+		}
+		if b, ok := cond.(*ast.BadExpr); ok && b.From == pos {
+			bad, init, stmt = b, s, kind
+		}
+		return true
+	})
+	if bad == nil {
+		return false
+	}
+
+	// the parser doesn't keep the assignment, so its tokens are scanned again
+	start, end := int(bad.From-file.FileStart), int(bad.To-file.FileStart)
+	assign, ok := assignOperator(content[start:end])
+	if !ok {
+		return false
+	}
+	lhs := strings.TrimSpace(string(content[start : start+assign]))
+	rhs := strings.TrimSpace(string(content[start+assign+1 : end]))
+	value, err := parser.ParseExpr(rhs)
+	if err != nil {
+		return false
+	}
+
+	why := ""
+	if init != nil {
+		why = fmt.Sprintf("the %s statement already has an init clause", stmt)
+	} else if hasCall(value) {
+		why = fmt.Sprintf("%s = %s may be meant as an init clause (%s %s = %s; ...)", lhs, rhs, stmt, lhs, rhs)
+	}
+	if why != "" {
+		f.note = "= was not changed to ==, as " + why
+		f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+		return false
+	}
+
+	if _, err := parser.ParseExpr(lhs + " == " + rhs); err != nil {
+		return false
+	}
+	f.note = fmt.Sprintf("changed %s = %s to %s == %s", lhs, rhs, lhs, rhs)
+	f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+	at := bad.From + token.Pos(assign)
+	return f.update(filename, applyEdits(content, []edit{posEdit(file, at, at+1, "==")}))
+}
+
+// assignOperator returns the offset of the = in src, if src is an assignment to a name or a
+// field (like x = 5 or s.x = 5).
+func assignOperator(src []byte) (int, bool) {
+	s := scanner.Scanner{}
+	s.Init(token.NewFileSet().AddFile("", -1, len(src)), src, nil, 0)
+	want := token.IDENT
+	for {
+		pos, tok, _ := s.Scan()
+		switch {
+		case tok == token.ASSIGN && want == token.PERIOD:
+			return int(pos) - 1, true
+		case tok != want:
+			return 0, false
+		case tok == token.IDENT:
+			want = token.PERIOD
+		default:
+			want = token.IDENT
+		}
+	}
+}
+
+// hasCall returns true if expr calls a function (or converts a value).
+func hasCall(expr ast.Expr) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if _, ok := n.(*ast.CallExpr); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
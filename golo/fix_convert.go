@@ -0,0 +1,52 @@
+package golo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// fixMismatch fixes "cannot use x (variable of type A) as B value" errors.
+// When A and B have identical underlying types an explicit conversion B(x) is inserted,
+// which preserves the meaning of the code exactly. Otherwise just x is deferred.
+func (f *Fixer) fixMismatch(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) FixKind {
+	if !strings.HasPrefix(msg, "cannot use ") || !strings.Contains(msg, " as ") {
+		return ""
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, expected := operandAt(pkg, file, pos)
+	if path == nil {
+		return ""
+	}
+	expr := path[0].(ast.Expr)
+	actual := pkg.TypesInfo.TypeOf(expr)
+
+	// If an interface isn't satisfied, the problem is likely elsewhere.
+	if actual == nil || types.IsInterface(expected) {
+		return ""
+	}
+
+	to, ok := typeExpr(pkg, file, expected)
+	if !ok {
+		return ""
+	}
+
+	if f.allows(Preserving) && types.Identical(actual.Underlying(), expected.Underlying()) {
+		conv := to
+		if strings.HasPrefix(conv, "*") || strings.HasPrefix(conv, "func") || strings.HasPrefix(conv, "<-") {
+			conv = "(" + conv + ")"
+		}
+		start := int(expr.Pos() - file.FileStart)
+		end := int(expr.End() - file.FileStart)
+		if f.speculate(pkg, filename, content[:start], []byte(conv+"("), content[start:end], []byte(")"), content[end:]) {
+			return Conversion
+		}
+	}
+
+	f.replaceNode(file, filename, content, expr, f.typedPanic(to, msg))
+	return Deferred
+}
@@ -0,0 +1,205 @@
+package golo
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// declKeywords start the lines that start top-level declarations.
+var declKeywords = [][]byte{[]byte("func"), []byte("type"), []byte("var"), []byte("const"), []byte("import"), []byte("//")}
+
+// findDeclToFix returns the range of the top-level declaration containing a syntax error.
+// The parser doesn't always know where a broken declaration ends (it may stop part way
+// through, or include the declarations after it), so the range ends at the first line that
+// starts with a } (inclusive) or looks like the start of another declaration (exclusive).
+func findDeclToFix(file *ast.File, content []byte, offset int) (int, int) {
+	pos := file.FileStart + token.Pos(offset)
+	var decl ast.Decl
+	for _, d := range file.Decls {
+		if d.Pos().IsValid() && d.Pos() <= pos {
+			decl = d
+		}
+	}
+	if decl == nil || decl.End() < pos {
+		return 0, 0
+	}
+
+	start := int(decl.Pos() - file.FileStart)
+	end := len(content)
+	for i := start; ; {
+		nl := bytes.IndexByte(content[i:], '\n')
+		if nl == -1 {
+			break
+		}
+		i += nl + 1
+		if bytes.HasPrefix(content[i:], []byte("}")) {
+			end = i + 1
+			break
+		}
+		if startsDecl(content[i:]) {
+			end = i
+			break
+		}
+	}
+	for end > start && bytes.ContainsAny(content[end-1:end], " \t\r\n") {
+		end--
+	}
+	return start, end
+}
+
+// longRange is how many bytes a range on a single line can span before it is assumed to have run
+// past the end of the declaration it is in. Minified or generated files may be one long line, and
+// without line breaks to stop at, findRangeToFix and findDeclToFix run to the end of the file.
+const longRange = 4096
+
+func isLongRange(content []byte, start, end int) bool {
+	return end-start > longRange && !bytes.ContainsAny(content[start:end], "\r\n")
+}
+
+// declRange returns the range of the top-level declaration containing a syntax error: the whole
+// function if its braces match, or else up to the next declaration keyword outside of any brackets.
+// It is used instead of findDeclToFix when the file has no lines to go by.
+func declRange(file *ast.File, content []byte, offset int) (int, int) {
+	pos := file.FileStart + token.Pos(offset)
+	var decl ast.Decl
+	for _, d := range file.Decls {
+		if d.Pos().IsValid() && d.Pos() <= pos {
+			decl = d
+		}
+	}
+	if decl == nil {
+		return 0, 0
+	}
+	start := int(decl.Pos() - file.FileStart)
+	if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil && fn.Body.Rbrace.IsValid() && fn.End() > pos {
+		return start, int(fn.End() - file.FileStart)
+	}
+
+	// the parser only stops skipping a broken declaration at const, import, type or var, so the
+	// next declaration is found from the tokens. A func followed by a name can only start one,
+	// even inside a function whose closing brace is missing.
+	end := len(content)
+	s := scanner.Scanner{}
+	s.Init(token.NewFileSet().AddFile("", -1, len(content)-start), content[start:], nil, 0)
+	depth := 0
+	prev, prevPos := token.ILLEGAL, 0
+	for end == len(content) {
+		p, tok, _ := s.Scan()
+		at := start + int(p) - 1
+		switch tok {
+		case token.EOF:
+			return start, trimDecl(content, start, len(content))
+		case token.LBRACE, token.LPAREN, token.LBRACK:
+			depth++
+		case token.RBRACE, token.RPAREN, token.RBRACK:
+			depth--
+		case token.TYPE, token.VAR, token.CONST, token.IMPORT:
+			if depth <= 0 && at > start {
+				end = at
+			}
+		case token.IDENT:
+			if prev == token.FUNC && prevPos > start {
+				end = prevPos
+			}
+		}
+		prev, prevPos = tok, at
+	}
+	return start, trimDecl(content, start, end)
+}
+
+// trimDecl returns end, moved back over any whitespace and semicolons before it.
+func trimDecl(content []byte, start, end int) int {
+	for end > start && bytes.ContainsAny(content[end-1:end], " \t\r\n;") {
+		end--
+	}
+	return end
+}
+
+func startsDecl(line []byte) bool {
+	for _, kw := range declKeywords {
+		if bytes.HasPrefix(line, kw) {
+			rest := line[len(kw):]
+			if kw[0] == '/' || len(rest) == 0 || bytes.ContainsAny(rest[:1], " \t(") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// replaceDecl returns code to replace the broken top-level declaration at content[start:end] with.
+// A function whose name (and receiver) can be parsed is replaced by one that panics, so that
+// calls to it still compile. Anything else is commented out, and uses of it are fixed later
+// in the same way as any other undefined name.
+func replaceDecl(file *ast.File, content []byte, start int, end int, offset int, msg string) string {
+	for _, d := range file.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok && int(fn.Pos()-file.FileStart) == start {
+			if stub := funcStub(file, content, fn, file.FileStart+token.Pos(offset), msg); stub != "" {
+				return stub + newLinesInRange(content[start:end])
+			}
+		}
+	}
+	return "/*" + strings.ReplaceAll(string(content[start:end]), "*/", "* /") + "*/"
+}
+
+// funcStub returns a function with the same name as fn (which has a syntax error at pos)
+// that accepts any arguments and panics with msg. It returns "" if fn is too broken for that.
+func funcStub(file *ast.File, content []byte, fn *ast.FuncDecl, pos token.Pos, msg string) string {
+	source := func(n ast.Node) (string, bool) {
+		if n.End() > pos || hasBadExpr(n) {
+			return "", false
+		}
+		return string(content[n.Pos()-file.FileStart : n.End()-file.FileStart]), true
+	}
+
+	if fn.Name == nil || fn.Name.Name == "_" || fn.Name.End() > pos {
+		return ""
+	}
+	stub := "func "
+	if fn.Recv != nil {
+		recv, ok := source(fn.Recv)
+		if !ok || len(fn.Recv.List) != 1 || !fn.Recv.Closing.IsValid() {
+			return ""
+		}
+		stub += recv + " "
+	}
+	stub += fn.Name.Name
+
+	if fn.Recv == nil && (fn.Name.Name == "main" || fn.Name.Name == "init") {
+		stub += "()"
+	} else {
+		if fn.Type.TypeParams != nil {
+			if params, ok := source(fn.Type.TypeParams); ok && fn.Type.TypeParams.Closing.IsValid() {
+				stub += params
+			}
+		}
+		stub += "(...any)"
+		if fn.Type.Results != nil {
+			if results, ok := source(fn.Type.Results); ok {
+				stub += " " + results
+			}
+		}
+	}
+	stub += fmt.Sprintf(" { panic(%#v) }", msg)
+
+	// the replacement must not change the line numbers
+	if strings.ContainsAny(stub, "\r\n") {
+		return ""
+	}
+	return stub
+}
+
+func hasBadExpr(n ast.Node) bool {
+	bad := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if _, ok := n.(*ast.BadExpr); ok {
+			bad = true
+		}
+		return !bad
+	})
+	return bad
+}
@@ -0,0 +1,151 @@
+package golo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixUndefinedVar fixes uses of undefined variables by declaring them at the start of
+// the enclosing function, so that the rest of the function still runs. If the way the
+// variable is used makes its type clear it is declared with that type, otherwise as any.
+// (If declaring the variable doesn't help, for example because name is meant to be a type,
+// the error is deferred as usual.)
+func (f *Fixer) fixUndefinedVar(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if !strings.HasPrefix(msg, "undefined: ") {
+		return false
+	}
+	name := strings.TrimPrefix(msg, "undefined: ")
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) < 2 {
+		return false
+	}
+	if id, ok := path[0].(*ast.Ident); !ok || id.Name != name {
+		return false
+	}
+	switch p := path[1].(type) {
+	case *ast.CallExpr:
+		if p.Fun == path[0] {
+			return false
+		}
+	case *ast.SelectorExpr:
+		// probably a package that hasn't been imported
+		return false
+	}
+
+	// declare it in the outermost function, so that it is visible to every use.
+	var body *ast.BlockStmt
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			body = n.Body
+		case *ast.FuncLit:
+			body = n.Body
+		}
+	}
+	if body == nil {
+		return false
+	}
+
+	t := "any"
+	ast.Inspect(body, func(n ast.Node) bool {
+		if t != "any" {
+			return false
+		}
+		if s := useType(pkg, file, n, name); s != "" {
+			t = s
+		}
+		return true
+	})
+
+	// on the same line as the {, so that line numbers don't change
+	decl := posEdit(file, body.Lbrace+1, body.Lbrace+1, " var "+name+" "+t+"; _ = "+name+";")
+	if !f.speculate(pkg, filename, applyEdits(content, []edit{decl})) {
+		return false
+	}
+	f.logf("golo: declared var %s %s at %s:%d", name, t, filename, lineOf(content, int(body.Lbrace-file.FileStart)))
+	return true
+}
+
+// useType returns the type that the undefined variable name should have, given that it
+// is used in n, or "" if that is not clear.
+func useType(pkg *packages.Package, file *ast.File, n ast.Node, name string) string {
+	isName := func(e ast.Expr) bool {
+		id, ok := e.(*ast.Ident)
+		return ok && id.Name == name && pkg.TypesInfo.Uses[id] == nil && pkg.TypesInfo.Defs[id] == nil
+	}
+	spell := func(t types.Type) string {
+		if t == nil {
+			return ""
+		}
+		if b, ok := t.(*types.Basic); ok && b.Kind() == types.UntypedNil {
+			return ""
+		}
+		s, ok := typeExpr(pkg, file, types.Default(t))
+		if !ok {
+			return ""
+		}
+		return s
+	}
+
+	switch n := n.(type) {
+	case *ast.BinaryExpr:
+		if n.Op == token.LAND || n.Op == token.LOR {
+			if isName(n.X) || isName(n.Y) {
+				return "bool"
+			}
+		} else if n.Op != token.SHL && n.Op != token.SHR {
+			if isName(n.X) {
+				return spell(pkg.TypesInfo.TypeOf(n.Y))
+			}
+			if isName(n.Y) {
+				return spell(pkg.TypesInfo.TypeOf(n.X))
+			}
+		}
+	case *ast.IndexExpr:
+		if !isName(n.X) {
+			return ""
+		}
+		elem := "any"
+		path, _ := astutil.PathEnclosingInterval(file, n.Pos(), n.End())
+		if t := expectedType(pkg, path); t != nil {
+			if s := spell(t); s != "" {
+				elem = s
+			}
+		}
+		key := pkg.TypesInfo.TypeOf(n.Index)
+		if key == nil || types.Default(key) == types.Typ[types.Int] {
+			return "[]" + elem
+		}
+		if s := spell(key); s != "" {
+			return "map[" + s + "]" + elem
+		}
+	case *ast.RangeStmt:
+		if isName(n.X) {
+			return "[]any"
+		}
+	case *ast.AssignStmt:
+		if n.Tok != token.ASSIGN || len(n.Lhs) != len(n.Rhs) {
+			return ""
+		}
+		for i, lhs := range n.Lhs {
+			if isName(lhs) {
+				return spell(pkg.TypesInfo.TypeOf(n.Rhs[i]))
+			}
+		}
+	case *ast.Ident:
+		if !isName(n) {
+			return ""
+		}
+		path, _ := astutil.PathEnclosingInterval(file, n.Pos(), n.End())
+		if t := expectedType(pkg, path); t != nil {
+			return spell(t)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package golo
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixDefer fixes errors in the arguments of a deferred call by replacing just the broken
+// argument with a panic, so that the defer statement still runs any cleanup it can.
+// (The arguments to a deferred call are evaluated when the defer statement runs, so
+// this panics at the same point the original error was.)
+//
+// If the error is in the function being deferred there's nothing to run, so the defer
+// is dropped along with the rest of the block, but a warning is printed.
+func (f *Fixer) fixDefer(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+
+	for _, n := range path {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		d, ok := n.(*ast.DeferStmt)
+		if !ok {
+			continue
+		}
+
+		call := d.Call
+		for _, arg := range call.Args {
+			if arg.Pos() > pos || arg.End() < pos {
+				continue
+			}
+			t := expectedType(pkg, []ast.Node{arg, call})
+			if t == nil {
+				break
+			}
+			if s, ok := typeExpr(pkg, file, t); ok {
+				return f.replaceNode(file, filename, content, arg, f.typedPanic(s, msg))
+			}
+		}
+
+		if call.Fun.Pos() <= pos && call.Fun.End() >= pos {
+			f.logf("golo: warning: cleanup dropped: the deferred call at %s:%d will not run", filename, lineOf(content, offset))
+		}
+		return false
+	}
+	return false
+}
+
+// lineOf returns the line number of offset in content.
+func lineOf(content []byte, offset int) int {
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,72 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// reCheckFunc matches the names of functions whose result says whether something is valid.
+var reCheckFunc = regexp.MustCompile(`^(?:[Vv]alidate|[Cc]heck|[Vv]erify|[Mm]ust)(?:[A-Z0-9_]|$)`)
+
+// fixDiscardedCheck fixes an unused variable that holds the error or bool result of a check
+// (like err := validate(x), where the author forgot the if err != nil), by panicking if the check
+// fails, instead of renaming the variable to _ (which would silently skip the check). It is only
+// used if Options.CheckDiscards is set. The panic is added on the same line, so line numbers
+// don't change.
+func (f *Fixer) fixDiscardedCheck(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int) bool {
+	if !f.options.CheckDiscards || !f.allows(Guessing) || pkg == nil || pkg.TypesInfo == nil {
+		return false
+	}
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) < 3 {
+		return false
+	}
+	ident, ok := path[0].(*ast.Ident)
+	if !ok {
+		return false
+	}
+	assign, ok := path[1].(*ast.AssignStmt)
+	if !ok || assign.Tok != token.DEFINE || len(assign.Rhs) != 1 || !inStmtList(path[2]) {
+		return false
+	}
+	call, ok := astutil.Unparen(assign.Rhs[0]).(*ast.CallExpr)
+	if !ok || !reCheckFunc.MatchString(calleeName(call)) {
+		return false
+	}
+	obj := pkg.TypesInfo.Defs[ident]
+	if obj == nil {
+		return false
+	}
+
+	check := ""
+	switch t := obj.Type(); {
+	case types.Identical(t, types.Universe.Lookup("error").Type()):
+		check = fmt.Sprintf("if %s != nil { panic(%s) }", ident.Name, ident.Name)
+	case types.Identical(t.Underlying(), types.Typ[types.Bool]):
+		check = fmt.Sprintf("if !%s { panic(%#v) }", ident.Name, "golo: "+exprString(content, file, call)+" returned false")
+	default:
+		return false
+	}
+	f.note = fmt.Sprintf("%s is checked instead of being discarded", ident.Name)
+	f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+	return f.update(filename, applyEdits(content, []edit{posEdit(file, assign.End(), assign.End(), "; "+check)}))
+}
+
+// calleeName returns the name of the function (or method) that call calls, or "" if it
+// isn't called by name.
+func calleeName(call *ast.CallExpr) string {
+	switch fn := astutil.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		return fn.Name
+	case *ast.SelectorExpr:
+		return fn.Sel.Name
+	}
+	return ""
+}
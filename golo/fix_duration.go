@@ -0,0 +1,102 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// fixDuration fixes integers that are used as a time.Duration, like client.Timeout = timeout
+// where timeout is an int. If a neighbouring assignment (or field in the same literal) multiplies
+// by a unit, like Deadline: 3 * time.Second, the integer is assumed to be in the same unit:
+// time.Duration(timeout) * time.Second. Otherwise it is just converted, which makes it a number
+// of nanoseconds (almost never what was meant), so a warning is printed.
+func (f *Fixer) fixDuration(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) (FixKind, Confidence) {
+	if !strings.HasPrefix(msg, "cannot use ") || !strings.Contains(msg, " as time.Duration value") || !f.allows(Preserving) {
+		return "", 0
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, expected := operandAt(pkg, file, pos)
+	if path == nil || !isDuration(expected) {
+		return "", 0
+	}
+	expr := path[0].(ast.Expr)
+	actual, ok := underlying(pkg.TypesInfo.TypeOf(expr)).(*types.Basic)
+	if !ok || actual.Info()&types.IsInteger == 0 || actual.Info()&types.IsUntyped != 0 {
+		return "", 0
+	}
+	to, ok := typeExpr(pkg, file, expected)
+	if !ok {
+		return "", 0
+	}
+
+	value := exprString(content, file, expr)
+	conv := to + "(" + value + ")"
+	if unit := durationUnit(pkg, content, file, path); unit != "" && f.allows(Guessing) {
+		if f.speculate(pkg, filename, applyEdits(content, []edit{nodeEdit(file, expr, conv+" * "+unit)})) {
+			f.note = fmt.Sprintf("%s is assumed to be in the same unit as the code around it (%s)", value, unit)
+			f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+			return Rewrite, Guessing
+		}
+	}
+	if f.speculate(pkg, filename, applyEdits(content, []edit{nodeEdit(file, expr, conv)})) {
+		f.note = fmt.Sprintf("%s is now a number of nanoseconds, it probably needs multiplying by a unit like time.Second", value)
+		f.logf("golo: warning: %s at %s:%d", f.note, filename, lineOf(content, offset))
+		return Conversion, Preserving
+	}
+	return "", 0
+}
+
+// durationUnit returns the unit (like time.Second) that the statements next to path[0] in
+// the same block (or the elements next to it in the same literal) multiply durations by,
+// or "" if there isn't one.
+func durationUnit(pkg *packages.Package, content []byte, file *ast.File, path []ast.Node) string {
+	for _, n := range path[1:] {
+		var values []ast.Expr
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			for _, stmt := range n.List {
+				if a, ok := stmt.(*ast.AssignStmt); ok {
+					values = append(values, a.Rhs...)
+				}
+			}
+		case *ast.CompositeLit:
+			for _, e := range n.Elts {
+				if kv, ok := e.(*ast.KeyValueExpr); ok {
+					values = append(values, kv.Value)
+				}
+			}
+		case *ast.FuncDecl, *ast.FuncLit:
+			return ""
+		default:
+			continue
+		}
+		for _, v := range values {
+			b, ok := v.(*ast.BinaryExpr)
+			if !ok || b.Op != token.MUL || b.Pos() <= path[0].Pos() && path[0].End() <= b.End() {
+				continue
+			}
+			for _, operand := range []ast.Expr{b.X, b.Y} {
+				sel, ok := operand.(*ast.SelectorExpr)
+				if !ok {
+					continue
+				}
+				if c, ok := pkg.TypesInfo.Uses[sel.Sel].(*types.Const); ok && c.Pkg() != nil && c.Pkg().Path() == "time" && isDuration(c.Type()) {
+					return exprString(content, file, sel)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// isDuration returns true if t is time.Duration.
+func isDuration(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Duration"
+}
@@ -0,0 +1,209 @@
+package golo
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// reUnexported matches errors for names that another package does not export (before Go 1.24
+// the message doesn't start with "name"). If the name is not used by the package's exported
+// API then it is not in the export data either, so it is reported as undefined.
+var reUnexported = regexp.MustCompile(`^(?:(?:name )?(\w+) not exported by package \w+|cannot refer to unexported name \w+\.(\w+)|undefined: \w+\.(\w+))$`)
+
+// exportShimFile is the file that export shims are added to, in the package that declares
+// the unexported function. It only ever exists in the overlay.
+const exportShimFile = "golo_exports.go"
+
+// exportShimPrefix is the start of the name of each export shim.
+const exportShimPrefix = "GoloExport_"
+
+// fixUnexported fixes calls to unexported functions in other packages in the main module
+// (with Options.ExportShims), by adding an exported function that calls it to that package
+// and calling that instead. The shims are added to a new file (exportShimFile) that golo fix
+// never writes, so this is not done in fix mode.
+func (f *Fixer) fixUnexported(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if !f.options.ExportShims || f.mode == "fix" {
+		return false
+	}
+	m := reUnexported.FindStringSubmatch(msg)
+	if m == nil {
+		return false
+	}
+	name := m[1] + m[2] + m[3]
+	if ast.IsExported(name) {
+		return false
+	}
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) < 3 {
+		return false
+	}
+	sel, ok := path[1].(*ast.SelectorExpr)
+	if !ok || sel.Sel != path[0] || sel.Sel.Name != name {
+		return false
+	}
+	if call, ok := path[2].(*ast.CallExpr); !ok || call.Fun != sel {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	imported, ok := pkg.TypesInfo.Uses[id].(*types.PkgName)
+	if !ok {
+		return false
+	}
+
+	dir, files, ok := f.mainModulePackage(imported.Imported().Path(), filename)
+	if !ok {
+		return false
+	}
+	shim := exportShimPrefix + name
+	shimName := filepath.Join(dir, exportShimFile)
+	shims, err := f.readFile(shimName)
+	if err != nil {
+		shims = []byte(fmt.Sprintf("// Code generated by golo for -export-shims. DO NOT EDIT.\n\n"+
+			"// The functions in this file export functions that other packages in the module call,\n"+
+			"// so that the code runs until they are exported. golo fix never writes this file.\n\n"+
+			"package %s\n", imported.Imported().Name()))
+	} else if _, ok := f.Fixed[shimName]; !ok {
+		// the package already has a file with this name
+		return false
+	}
+
+	if !bytes.Contains(shims, []byte("\nfunc "+shim+"(")) {
+		decl, imports, ok := f.exportShim(files, name, shim)
+		if !ok {
+			return false
+		}
+		for _, spec := range imports {
+			spec = "\nimport " + spec + "\n"
+			if bytes.Contains(shims, []byte(spec)) {
+				continue
+			}
+			// imports go straight after the package clause
+			i := bytes.Index(shims, []byte("\npackage "))
+			i += bytes.IndexByte(shims[i+1:], '\n') + 2
+			shims = bytes.Join([][]byte{shims[:i], []byte(spec), shims[i:]}, nil)
+		}
+		f.update(shimName, shims, []byte("\n"+decl+"\n"))
+	}
+
+	f.note = fmt.Sprintf("%s.%s is called through %s, which only golo can see; export it to fix this", id.Name, name, shim)
+	f.logf("golo: note: %s", f.note)
+	return f.replaceNode(file, filename, content, sel.Sel, shim)
+}
+
+// exportShim returns an exported function called shim that calls the function called name
+// declared in one of files, and the import specs it needs.
+func (f *Fixer) exportShim(files []string, name string, shim string) (string, []string, bool) {
+	for _, filename := range files {
+		content, err := f.readFile(filename)
+		if err != nil {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filename, content, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		for _, d := range file.Decls {
+			decl, ok := d.(*ast.FuncDecl)
+			if !ok || decl.Recv != nil || decl.Name.Name != name {
+				continue
+			}
+			if decl.Type.TypeParams != nil {
+				return "", nil, false
+			}
+
+			params, args := []string{}, []string{}
+			for _, field := range decl.Type.Params.List {
+				n := maxInt(len(field.Names), 1)
+				for i := 0; i < n; i++ {
+					arg := fmt.Sprintf("p%d", len(args))
+					params = append(params, arg+" "+exprString(content, file, field.Type))
+					if _, ok := field.Type.(*ast.Ellipsis); ok {
+						arg += "..."
+					}
+					args = append(args, arg)
+				}
+			}
+			results := ""
+			if decl.Type.Results != nil {
+				results = exprString(content, file, decl.Type.Results)
+			}
+			call := fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+			if results != "" {
+				call = "return " + call
+			}
+			return fmt.Sprintf("func %s(%s) %s { %s }", shim, strings.Join(params, ", "), results, call),
+				usedImports(file, decl.Type), true
+		}
+	}
+	return "", nil, false
+}
+
+// usedImports returns the import specs (as written in file) of the packages used in n.
+func usedImports(file *ast.File, n ast.Node) []string {
+	used := map[string]bool{}
+	ast.Inspect(n, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	specs := []string{}
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if !used[name] {
+			continue
+		}
+		if spec.Name != nil {
+			specs = append(specs, spec.Name.Name+" "+spec.Path.Value)
+		} else {
+			specs = append(specs, spec.Path.Value)
+		}
+	}
+	return specs
+}
+
+// mainModulePackage returns the directory and Go files of the package with the given import
+// path, if it is in the main module, and the main module also contains filename.
+func (f *Fixer) mainModulePackage(importPath string, filename string) (string, []string, bool) {
+	out, err := f.goCommand("list", "-e", "-f", "{{.Dir}}\t{{with .Module}}{{.Main}}\t{{.Dir}}{{end}}{{range .GoFiles}}\t{{.}}{{end}}", importPath).Output()
+	if err != nil {
+		return "", nil, false
+	}
+	fields := strings.Split(strings.TrimSpace(string(out)), "\t")
+	if len(fields) < 3 || fields[1] != "true" || fields[0] == "" {
+		return "", nil, false
+	}
+	if rel, err := filepath.Rel(fields[2], filename); err != nil || strings.HasPrefix(rel, "..") {
+		return "", nil, false
+	}
+	files := []string{}
+	for _, name := range fields[3:] {
+		files = append(files, filepath.Join(fields[0], name))
+	}
+	return fields[0], files, true
+}
@@ -0,0 +1,249 @@
+package golo
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// fixFormats fixes printf-style calls in pkg (calls to functions whose last parameters are
+// a format string and ...any) where the format string is a literal that doesn't match the
+// arguments: verbs for the wrong type of argument are replaced with %v, and extra arguments
+// are dropped.
+//
+// These are not compile errors, so fixFormats only runs if Options.FixFormat is set, and
+// only once pkg type-checks, and each fix is printed.
+func (f *Fixer) fixFormats(pkg *packages.Package) bool {
+	if !f.options.FixFormat || !f.allows(Guessing) || pkg.TypesInfo == nil {
+		return false
+	}
+	if f.formatted == nil {
+		f.formatted = map[string]bool{}
+	}
+
+	fixed := false
+	for _, file := range pkg.Syntax {
+		filename := pkg.Fset.Position(file.Pos()).Filename
+		if f.formatted[filename] || f.changed[filename] || !f.sources[filename] {
+			continue
+		}
+		f.formatted[filename] = true
+		content, err := f.readFile(filename)
+		if err != nil || len(content) != pkg.Fset.File(file.Pos()).Size() {
+			continue
+		}
+
+		edits := []edit{}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				edits = append(edits, f.formatEdits(pkg, file, content, call)...)
+			}
+			return true
+		})
+		if len(edits) > 0 {
+			f.update(filename, applyEdits(content, edits))
+			f.changed[filename] = true
+			fixed = true
+		}
+	}
+	return fixed
+}
+
+// formatEdits returns the edits needed to make the format string in call match its arguments.
+func (f *Fixer) formatEdits(pkg *packages.Package, file *ast.File, content []byte, call *ast.CallExpr) []edit {
+	sig, ok := underlying(pkg.TypesInfo.TypeOf(call.Fun)).(*types.Signature)
+	if !ok || !sig.Variadic() || sig.Params().Len() < 2 || call.Ellipsis.IsValid() {
+		return nil
+	}
+	params := sig.Params()
+	last, ok := params.At(params.Len() - 1).Type().(*types.Slice)
+	if !ok || !types.IsInterface(last.Elem()) || last.Elem().Underlying().(*types.Interface).NumMethods() > 0 {
+		return nil
+	}
+	if basic, ok := params.At(params.Len() - 2).Type().(*types.Basic); !ok || basic.Kind() != types.String {
+		return nil
+	}
+	if len(call.Args) < params.Len()-1 {
+		return nil
+	}
+	lit, ok := call.Args[params.Len()-2].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return nil
+	}
+	args := call.Args[params.Len()-1:]
+	verbs, ok := parseVerbs(exprString(content, file, lit))
+	if !ok {
+		return nil
+	}
+
+	name := exprString(content, file, call.Fun)
+	edits := []edit{}
+	i := 0
+	for _, v := range verbs {
+		if v.star {
+			i++
+			continue
+		}
+		if i >= len(args) {
+			break
+		}
+		arg := args[i]
+		i++
+		t := pkg.TypesInfo.TypeOf(arg)
+		if t == nil || verbAccepts(v.verb, t) {
+			continue
+		}
+		pos := lit.Pos() + token.Pos(v.offset)
+		f.reportFormat(pkg.Fset.Position(pos), fmt.Sprintf("%s format %%%c has arg %s of wrong type %s", name, v.verb, exprString(content, file, arg), t))
+		edits = append(edits, posEdit(file, pos, pos+1, "v"))
+	}
+
+	// before go1.20 fmt.Errorf only wraps the error for the first %w, and prints the others as
+	// %!w(...), so they are changed to %v
+	if isErrorf(pkg, call) && f.goBefore(20) {
+		wrapped := false
+		for _, v := range verbs {
+			if v.verb != 'w' {
+				continue
+			}
+			if !wrapped {
+				wrapped = true
+				continue
+			}
+			pos := lit.Pos() + token.Pos(v.offset)
+			f.note = "before go1.20 fmt.Errorf only wraps the error for the first %w, so errors.Is and errors.As won't find this one"
+			f.reportFormat(pkg.Fset.Position(pos), name+" call has more than one error-wrapping directive %w")
+			edits = append(edits, posEdit(file, pos, pos+1, "v"))
+		}
+	}
+
+	// arguments with side effects are left alone (fmt will print them as %!(EXTRA ...))
+	if i < len(args) && !hasSideEffects(args[i:]) {
+		f.reportFormat(pkg.Fset.Position(args[i].Pos()), fmt.Sprintf("%s call needs %d %s but has %d %s", name,
+			i, plural(i, "arg", "args"), len(args), plural(len(args), "arg", "args")))
+		start, end := lit.End(), args[len(args)-1].End()
+		if i > 0 {
+			start = args[i-1].End()
+		}
+		edits = append(edits, posEdit(file, start, end, dropArguments(content[start-file.FileStart:end-file.FileStart], true)))
+	}
+	return edits
+}
+
+// isErrorf returns true if call calls fmt.Errorf.
+func isErrorf(pkg *packages.Package, call *ast.CallExpr) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	fn, ok := pkg.TypesInfo.Uses[sel.Sel].(*types.Func)
+	return ok && fn.Pkg() != nil && fn.Pkg().Path() == "fmt" && fn.Name() == "Errorf"
+}
+
+func (f *Fixer) reportFormat(pos token.Position, msg string) {
+	// the edits are made after every call has been checked
+	f.report(errors.New(pos.String()+": "+msg), pos, msg, Rewrite, Guessing, nil)
+}
+
+// formatVerb is a verb in a format string, with the offset of its verb character.
+type formatVerb struct {
+	verb   rune
+	offset int
+	// star is true for the * in a width or precision, which uses an argument
+	star bool
+}
+
+// parseVerbs returns the verbs in the source code of a format string literal.
+// It returns false if the format uses explicit argument indexes, which are not supported.
+func parseVerbs(lit string) ([]formatVerb, bool) {
+	verbs := []formatVerb{}
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '%' {
+			continue
+		}
+		i++
+		for i < len(lit) && strings.IndexByte("+-# 0", lit[i]) >= 0 {
+			i++
+		}
+		for i < len(lit) && (lit[i] >= '0' && lit[i] <= '9' || lit[i] == '.' || lit[i] == '*' || lit[i] == '[') {
+			if lit[i] == '[' {
+				return nil, false
+			}
+			if lit[i] == '*' {
+				verbs = append(verbs, formatVerb{star: true})
+			}
+			i++
+		}
+		if i >= len(lit) {
+			break
+		}
+		if lit[i] == '%' {
+			continue
+		}
+		verbs = append(verbs, formatVerb{verb: rune(lit[i]), offset: i})
+	}
+	return verbs, true
+}
+
+// verbAccepts returns false if fmt would print an argument of type t incorrectly with verb.
+// Only arguments of basic types are checked; anything more complicated is assumed to be fine.
+func verbAccepts(verb rune, t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok || strings.ContainsRune("vT", verb) {
+		return true
+	}
+	if hasMethod(t, "Format") || hasMethod(t, "String") || hasMethod(t, "Error") {
+		return true
+	}
+	info := basic.Info()
+	switch verb {
+	case 'd', 'c', 'U', 'o', 'O':
+		return info&types.IsInteger != 0
+	case 'b':
+		return info&(types.IsInteger|types.IsFloat|types.IsComplex) != 0
+	case 'x', 'X':
+		return info&(types.IsInteger|types.IsFloat|types.IsComplex|types.IsString) != 0
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		return info&(types.IsFloat|types.IsComplex) != 0
+	case 's':
+		return info&types.IsString != 0
+	case 'q':
+		return info&(types.IsString|types.IsInteger) != 0
+	case 't':
+		return info&types.IsBoolean != 0
+	case 'p':
+		return basic.Kind() == types.UnsafePointer
+	}
+	return true
+}
+
+// hasMethod returns true if t (or *t) has a method with the given name.
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// hasSideEffects returns true if evaluating any of exprs might do something.
+func hasSideEffects(exprs []ast.Expr) bool {
+	effects := false
+	for _, e := range exprs {
+		ast.Inspect(e, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				effects = true
+			case *ast.UnaryExpr:
+				if n.Op == token.ARROW {
+					effects = true
+				}
+			}
+			return !effects
+		})
+	}
+	return effects
+}
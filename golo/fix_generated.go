@@ -0,0 +1,138 @@
+package golo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+var (
+	reGenerated  = regexp.MustCompile(`(?m)^// Code generated (?:by )?(.*?)\.? DO NOT EDIT\.$`)
+	reGoGenerate = regexp.MustCompile(`(?m)^//go:generate (.*)$`)
+)
+
+// generator is a //go:generate directive.
+type generator struct {
+	file      string
+	directive string
+}
+
+// command returns the go generate command that runs just this directive.
+func (g generator) command() []string {
+	return []string{"generate", "-run", "^" + regexp.QuoteMeta("//go:generate "+g.directive) + "$", g.file}
+}
+
+// fixGenerated handles errors in generated files (like a stringer file that is out of date
+// because a constant was added). If the package has a //go:generate directive for the
+// generator named in the file's "Code generated" comment, then with Options.RunGenerators
+// it is run, and the package is checked again. Otherwise the error is fixed as usual, with
+// a note saying how to regenerate the file.
+func (f *Fixer) fixGenerated(pkg *packages.Package, filename string, content []byte) FixKind {
+	g, ok := f.findGenerator(pkg, content)
+	if !ok {
+		return ""
+	}
+	cmd := "go " + strings.Join(quoteArgs(g.command()), " ")
+
+	if !f.options.RunGenerators || f.ranGenerator(g) {
+		f.note = fmt.Sprintf("%s is generated, run %s to regenerate it", filepath.Base(filename), cmd)
+		f.warnOnce(g.file+":"+g.directive, "golo: note: %s", f.note)
+		return ""
+	}
+	// generators change files on disk, so this must not happen while other packages are being fixed
+	if f.parent != nil {
+		f.rerun = true
+		return postponed
+	}
+
+	f.generated[g] = true
+	f.logf("golo: running %s (outside golo's sandbox, as -run-generators asks)", cmd)
+	before, err := os.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	out, err := f.goCommand(g.command()...).CombinedOutput()
+	if err != nil {
+		f.logf("golo: %s failed: %s\n%s", cmd, err, strings.TrimRight(string(out), "\n"))
+		return ""
+	}
+	if after, err := os.ReadFile(filename); err != nil || bytes.Equal(before, after) {
+		f.logf("golo: %s did not change %s", cmd, filepath.Base(filename))
+		return ""
+	}
+	// the regenerated file replaces any fixes to the old one
+	delete(f.Fixed, filename)
+	delete(f.spilled, filename)
+	return Regenerated
+}
+
+// ranGenerator returns true if g has already been run.
+func (f *Fixer) ranGenerator(g generator) bool {
+	if f.generated == nil {
+		f.generated = map[generator]bool{}
+	}
+	return f.generated[g]
+}
+
+// findGenerator returns the //go:generate directive in pkg that runs the generator named
+// in content's "Code generated" comment.
+func (f *Fixer) findGenerator(pkg *packages.Package, content []byte) (generator, bool) {
+	by, ok := generatedBy(content)
+	if !ok {
+		return generator{}, false
+	}
+	fields := strings.Fields(by)
+	if len(fields) == 0 {
+		return generator{}, false
+	}
+	// e.g. // Code generated by "stringer -type=Color"; DO NOT EDIT.
+	name := path.Base(strings.Trim(fields[0], `"';,`))
+
+	for _, file := range pkg.GoFiles {
+		source, err := f.readFile(file)
+		if err != nil {
+			continue
+		}
+		for _, d := range reGoGenerate.FindAllSubmatch(source, -1) {
+			directive := strings.TrimSpace(string(d[1]))
+			for _, arg := range strings.Fields(directive) {
+				if path.Base(arg) == name {
+					return generator{file: file, directive: directive}, true
+				}
+			}
+		}
+	}
+	return generator{}, false
+}
+
+// generatedBy returns what generated content, according to its "Code generated" comment
+// (and false if it doesn't have one).
+func generatedBy(content []byte) (string, bool) {
+	header := content
+	if i := bytes.Index(content, []byte("\npackage ")); i > -1 {
+		header = content[:i]
+	}
+	m := reGenerated.FindSubmatch(header)
+	if m == nil {
+		return "", false
+	}
+	return string(m[1]), true
+}
+
+// quoteArgs quotes args that the shell would otherwise change.
+func quoteArgs(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t\n\"'\\$*?[]^(){}|&;<>`!#~") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted[i] = arg
+	}
+	return quoted
+}
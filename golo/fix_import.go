@@ -0,0 +1,98 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixMissingImport fixes references to packages that haven't been imported (like fmt.Println
+// in a file that doesn't import fmt) by adding the import.
+// The package is one that another file in the package imports with that name, or else
+// the only standard library package with that name. If there's more than one it's
+// not clear which was meant, so the error is deferred instead.
+func (f *Fixer) fixMissingImport(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	name, ok := strings.CutPrefix(msg, "undefined: ")
+	if !ok || !token.IsIdentifier(name) {
+		return false
+	}
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) < 2 {
+		return false
+	}
+	if sel, ok := path[1].(*ast.SelectorExpr); !ok || sel.X != path[0] {
+		return false
+	}
+
+	candidates := map[string]bool{}
+	for _, other := range pkg.Syntax {
+		for _, spec := range other.Imports {
+			p, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			if spec.Name != nil && spec.Name.Name == name || spec.Name == nil && importName(pkg, p) == name {
+				candidates[p] = true
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		for _, p := range f.stdPackages()[name] {
+			candidates[p] = true
+		}
+	}
+	if len(candidates) != 1 {
+		return false
+	}
+
+	for importPath := range candidates {
+		rewritten, as := addImport(file, content, importPath)
+		if as != name {
+			// addImport would use a different name, so add the import with an explicit one
+			rewritten = applyEdits(content, []edit{posEdit(file, file.Name.End(), file.Name.End(), fmt.Sprintf("; import %s %q", name, importPath))})
+		}
+		if f.speculate(pkg, filename, rewritten) {
+			f.logf("golo: imported %q for %s at %s:%d", importPath, name, filename, lineOf(content, offset))
+			return true
+		}
+	}
+	return false
+}
+
+// importName returns the name of the package with the given path (as imported by pkg).
+func importName(pkg *packages.Package, importPath string) string {
+	if imp, ok := pkg.Imports[importPath]; ok && imp.Name != "" {
+		return imp.Name
+	}
+	return path.Base(importPath)
+}
+
+// stdPackages returns the packages in the standard library that can be imported,
+// by name.
+func (f *Fixer) stdPackages() map[string][]string {
+	if f.parent != nil {
+		return f.parent.stdPackages()
+	}
+	f.stdOnce.Do(func() {
+		f.std = map[string][]string{}
+		cmd := f.goCommand("list", "std")
+		out, err := cmd.Output()
+		if err != nil {
+			return
+		}
+		for _, p := range strings.Fields(string(out)) {
+			if strings.Contains(p, "internal") || strings.HasPrefix(p, "vendor/") {
+				continue
+			}
+			f.std[path.Base(p)] = append(f.std[path.Base(p)], p)
+		}
+	})
+	return f.std
+}
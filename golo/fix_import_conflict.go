@@ -0,0 +1,121 @@
+package golo
+
+import (
+	"go/ast"
+	"go/types"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// fixImportConflict fixes code where a variable has the same name as an imported package
+// (for example a variable called url in a file that imports net/url).
+// The import is renamed (to neturl), and any references to the package in the file are
+// updated to use the new name, so that both the variable and the package can be used.
+func (f *Fixer) fixImportConflict(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	var name string
+	switch {
+	case strings.Contains(msg, "already declared through import of package"):
+		name, _, _ = strings.Cut(msg, " ")
+	case strings.HasPrefix(msg, "use of package ") && strings.HasSuffix(msg, " not in selector"):
+		name = strings.TrimSuffix(strings.TrimPrefix(msg, "use of package "), " not in selector")
+	case strings.Contains(msg, "undefined (type ") && strings.Contains(msg, "has no field or method"):
+		name, _, _ = strings.Cut(msg, ".")
+	default:
+		return false
+	}
+
+	var spec *ast.ImportSpec
+	var imported *types.Package
+	for _, s := range file.Imports {
+		p, err := strconv.Unquote(s.Path.Value)
+		if err != nil {
+			continue
+		}
+		for _, imp := range pkg.Types.Imports() {
+			if imp.Path() != p {
+				continue
+			}
+			if (s.Name == nil && imp.Name() == name) || (s.Name != nil && s.Name.Name == name) {
+				spec, imported = s, imp
+			}
+		}
+	}
+	if spec == nil {
+		return false
+	}
+
+	alias := importAlias(pkg, file, imported.Path())
+	edits := []edit{}
+	if spec.Name != nil {
+		edits = append(edits, nodeEdit(file, spec.Name, alias))
+	} else {
+		edits = append(edits, posEdit(file, spec.Path.Pos(), spec.Path.Pos(), alias+" "))
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		id, ok := sel.X.(*ast.Ident)
+		if !ok || id.Name != name || !sel.Sel.IsExported() || imported.Scope().Lookup(sel.Sel.Name) == nil {
+			return true
+		}
+		if obj := pkg.TypesInfo.Uses[id]; obj != nil {
+			if _, isPkg := obj.(*types.PkgName); !isPkg {
+				if o, _, _ := types.LookupFieldOrMethod(obj.Type(), true, obj.Pkg(), sel.Sel.Name); o != nil {
+					return true
+				}
+			}
+		}
+		edits = append(edits, nodeEdit(file, id, alias))
+		return true
+	})
+	if len(edits) == 1 {
+		return false
+	}
+
+	if !f.speculate(pkg, filename, applyEdits(content, edits)) {
+		return false
+	}
+	f.logf("golo: renamed import %s to %s to avoid a conflict with %s", spec.Path.Value, alias, name)
+	return true
+}
+
+// importAlias returns an unused name for the package at importPath (e.g. neturl for net/url).
+func importAlias(pkg *packages.Package, file *ast.File, importPath string) string {
+	parts := strings.Split(importPath, "/")
+	base := path.Base(importPath)
+	if len(parts) > 1 {
+		base = parts[len(parts)-2] + base
+	}
+	base = strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' {
+			return -1
+		}
+		return r
+	}, base)
+
+	used := func(name string) bool {
+		if pkg.Types.Scope().Lookup(name) != nil {
+			return true
+		}
+		found := false
+		ast.Inspect(file, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == name {
+				found = true
+			}
+			return !found
+		})
+		return found
+	}
+
+	alias := base
+	for i := 2; used(alias); i++ {
+		alias = base + strconv.Itoa(i)
+	}
+	return alias
+}
@@ -0,0 +1,153 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixIndex defers errors in the index of an index expression (m[42] on a map[string]T,
+// s["key"] on a slice, or a constant index out of range) by replacing just the
+// index expression with a panic of the element type.
+// If the index expression is assigned to, the assignment statement is replaced instead.
+func (f *Fixer) fixIndex(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if !strings.Contains(msg, "map index") && !strings.Contains(msg, "must be integer") &&
+		!strings.Contains(msg, "out of bounds") && !strings.Contains(msg, "cannot convert") {
+		return false
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+
+	var index *ast.IndexExpr
+	parent := -1
+	for i, n := range path {
+		if n, ok := n.(*ast.IndexExpr); ok && n.Index.Pos() <= pos && n.Index.End() >= pos {
+			index = n
+			parent = i + 1
+			break
+		}
+	}
+	if index == nil || parent >= len(path) {
+		return false
+	}
+
+	var elem types.Type
+	commaOk := false
+	switch t := underlying(pkg.TypesInfo.TypeOf(index.X)).(type) {
+	case *types.Map:
+		elem = t.Elem()
+		commaOk = true
+		// e.g. a []byte used as a key after fixMapKey made the map's keys strings
+		if k := pkg.TypesInfo.TypeOf(index.Index); k != nil && !isUntyped(k) && types.ConvertibleTo(k, t.Key()) {
+			if key, ok := typeExpr(pkg, file, t.Key()); ok {
+				f.note = fmt.Sprintf("use %s(%s) as the key", key, exprString(content, file, index.Index))
+				msg += " (" + f.note + ")"
+			}
+		}
+	case *types.Slice:
+		elem = t.Elem()
+	case *types.Array:
+		elem = t.Elem()
+		if strings.Contains(msg, "out of bounds") {
+			if arr, ok := typeExpr(pkg, file, t); ok {
+				msg = fmt.Sprintf("index %s out of bounds for %s", exprString(content, file, index.Index), arr)
+			}
+		}
+	case *types.Pointer:
+		if arr, ok := t.Elem().Underlying().(*types.Array); ok {
+			elem = arr.Elem()
+		}
+	case *types.Basic:
+		if t.Info()&types.IsString != 0 {
+			elem = types.Typ[types.Byte]
+		}
+	}
+	if elem == nil {
+		return false
+	}
+
+	switch p := path[parent].(type) {
+	case *ast.AssignStmt:
+		for _, lhs := range p.Lhs {
+			if lhs == index {
+				return f.replaceNode(file, filename, content, p, fmt.Sprintf("panic(%#v)", f.panicMsg(msg)))
+			}
+		}
+		if commaOk && len(p.Lhs) == 2 && len(p.Rhs) == 1 {
+			return f.replaceCommaOk(pkg, file, filename, content, index, elem, msg)
+		}
+	case *ast.IncDecStmt:
+		return f.replaceNode(file, filename, content, p, fmt.Sprintf("panic(%#v)", f.panicMsg(msg)))
+	case *ast.ValueSpec:
+		if commaOk && len(p.Names) == 2 && len(p.Values) == 1 {
+			return f.replaceCommaOk(pkg, file, filename, content, index, elem, msg)
+		}
+	}
+
+	t, ok := typeExpr(pkg, file, elem)
+	if !ok {
+		return false
+	}
+	return f.replaceNode(file, filename, content, index, f.typedPanic(t, msg))
+}
+
+// fixMapKey fixes map types whose key type can't be compared (like map[[]byte]T), by using
+// string keys instead. Uses of the map with the old key type are then deferred by fixIndex,
+// with a note saying how to convert the key.
+func (f *Fixer) fixMapKey(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if !strings.HasPrefix(msg, "invalid map key type ") {
+		return false
+	}
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	for _, n := range path {
+		m, ok := n.(*ast.MapType)
+		if !ok || m.Key.Pos() > pos || m.Key.End() < pos {
+			continue
+		}
+		switch underlying(pkg.TypesInfo.TypeOf(m.Key)).(type) {
+		case *types.Slice, *types.Map, *types.Signature:
+		default:
+			return false
+		}
+		f.note = fmt.Sprintf("%s is now map[string]%s", exprString(content, file, m), exprString(content, file, m.Value))
+		f.logf("golo: note: %s at %s:%d", f.note, filename, lineOf(content, offset))
+		return f.replaceNode(file, filename, content, m.Key, "string")
+	}
+	return false
+}
+
+// isUntyped returns true if t is the type of an untyped constant (or nil).
+func isUntyped(t types.Type) bool {
+	b, ok := t.(*types.Basic)
+	return ok && b.Info()&types.IsUntyped != 0
+}
+
+// replaceCommaOk replaces a map index used in the v, ok := m[k] form with a panic that
+// still produces two values.
+func (f *Fixer) replaceCommaOk(pkg *packages.Package, file *ast.File, filename string, content []byte, index *ast.IndexExpr, elem types.Type, msg string) bool {
+	t, ok := typeExpr(pkg, file, elem)
+	if !ok {
+		return false
+	}
+	return f.replaceNode(file, filename, content, index, f.typedPanic("("+t+", bool)", msg))
+}
+
+// underlying returns the underlying type of t, or nil if t is nil.
+func underlying(t types.Type) types.Type {
+	if t == nil {
+		return nil
+	}
+	return t.Underlying()
+}
+
+// exprString returns the source code of e.
+func exprString(content []byte, file *ast.File, e ast.Node) string {
+	return string(content[e.Pos()-file.FileStart : e.End()-file.FileStart])
+}
@@ -0,0 +1,214 @@
+package golo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixPromotedField fixes struct literals that set a field promoted from an embedded struct
+// (which only selectors can do), by moving the value into a literal for the embedded struct:
+// Config{Timeout: t} becomes Config{HTTPConfig: HTTPConfig{Timeout: t}} (older versions of go
+// don't allow promoted fields in literals at all, and none allow them through an embedded pointer).
+// If the field could come from more than one embedded struct, the literal is deferred instead,
+// with a message listing where it could come from.
+func (f *Fixer) fixPromotedField(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) FixKind {
+	if !(strings.HasPrefix(msg, "unknown field ") && strings.Contains(msg, " in struct literal of type ")) &&
+		!strings.HasPrefix(msg, "use of promoted field ") && !strings.HasPrefix(msg, "cannot specify promoted field ") &&
+		!strings.HasPrefix(msg, "invalid implicit pointer indirection to reach ") {
+		return ""
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var kv *ast.KeyValueExpr
+	var lit *ast.CompositeLit
+	for i, n := range path {
+		if n, ok := n.(*ast.KeyValueExpr); ok && n.Key.Pos() == pos && i+1 < len(path) {
+			kv = n
+			lit, _ = path[i+1].(*ast.CompositeLit)
+			break
+		}
+	}
+	if kv == nil || lit == nil {
+		return ""
+	}
+	key, ok := kv.Key.(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	t := pkg.TypesInfo.TypeOf(lit)
+	if p, ok := underlying(t).(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	s, ok := underlying(t).(*types.Struct)
+	if !ok {
+		return ""
+	}
+
+	paths := promotionPaths(s, key.Name, 0)
+	if len(paths) == 0 {
+		return ""
+	}
+	if len(paths) > 1 {
+		typ, ok := typeExpr(pkg, file, t)
+		if !ok {
+			return ""
+		}
+		names := []string{}
+		for _, p := range paths {
+			names = append(names, fieldPathString(p))
+		}
+		f.replaceNode(file, filename, content, lit, f.typedPanic(typ, msg+" (it could be "+strings.Join(names, " or ")+")"))
+		return Deferred
+	}
+
+	if !f.allows(Preserving) {
+		return ""
+	}
+	// the value is nested in the literal for the embedded struct, or in a new one if there isn't one yet
+	elems, rbrace := lit.Elts, lit.Rbrace
+	embedded := paths[0][:len(paths[0])-1]
+	for len(embedded) > 0 {
+		nested := literalField(elems, embedded[0].Name())
+		if nested == nil {
+			break
+		}
+		if u, ok := nested.(*ast.UnaryExpr); ok && u.Op == token.AND {
+			nested = u.X
+		}
+		c, ok := nested.(*ast.CompositeLit)
+		if !ok {
+			return ""
+		}
+		elems, rbrace, embedded = c.Elts, c.Rbrace, embedded[1:]
+	}
+	value := key.Name + ": " + exprString(content, file, kv.Value)
+	for i := len(embedded) - 1; i >= 0; i-- {
+		elem := embedded[i].Type()
+		prefix := ""
+		if p, ok := elem.(*types.Pointer); ok {
+			elem, prefix = p.Elem(), "&"
+		}
+		typ, ok := typeExpr(pkg, file, elem)
+		if !ok {
+			return ""
+		}
+		value = embedded[i].Name() + ": " + prefix + typ + "{" + value + "}"
+	}
+
+	if len(embedded) == len(paths[0])-1 {
+		if f.speculate(pkg, filename, applyEdits(content, []edit{nodeEdit(file, kv, value)})) {
+			return Rewrite
+		}
+		return ""
+	}
+
+	// the element is removed (along with its comma), and the value added to the end of the
+	// literal for the embedded struct that is already there
+	start, end := int(kv.Pos()-file.FileStart), int(kv.End()-file.FileStart)
+	for i := end; i < len(content); i++ {
+		if content[i] == ',' {
+			end = i + 1
+			break
+		}
+		if content[i] != ' ' && content[i] != '\t' {
+			break
+		}
+	}
+	// so that an element on a line of its own doesn't leave the indentation behind
+	for start > 0 && (content[start-1] == ' ' || content[start-1] == '\t') {
+		start--
+	}
+	insert := int(rbrace - file.FileStart)
+	sep := ""
+	if last := lastNonSpace(content[:insert]); last != '{' && last != ',' {
+		sep = ", "
+	}
+	edits := []edit{
+		{start, end, newLinesInRange(content[start:end])},
+		{insert, insert, sep + value},
+	}
+	if f.speculate(pkg, filename, applyEdits(content, edits)) {
+		return Rewrite
+	}
+	return ""
+}
+
+// promotionPaths returns the chains of embedded fields through which a field called name is
+// promoted into s, that are as short as each other (as longer ones are hidden by shorter ones).
+func promotionPaths(s *types.Struct, name string, depth int) [][]*types.Var {
+	// embedded structs can embed each other through pointers
+	if depth > 8 {
+		return nil
+	}
+	var found [][]*types.Var
+	for i := 0; i < s.NumFields(); i++ {
+		field := s.Field(i)
+		if !field.Embedded() {
+			continue
+		}
+		t := field.Type()
+		if p, ok := underlying(t).(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		embedded, ok := underlying(t).(*types.Struct)
+		if !ok {
+			continue
+		}
+		for j := 0; j < embedded.NumFields(); j++ {
+			if embedded.Field(j).Name() == name {
+				found = append(found, []*types.Var{field, embedded.Field(j)})
+			}
+		}
+		for _, p := range promotionPaths(embedded, name, depth+1) {
+			found = append(found, append([]*types.Var{field}, p...))
+		}
+	}
+	shortest := [][]*types.Var{}
+	for _, p := range found {
+		if len(shortest) > 0 && len(p) > len(shortest[0]) {
+			continue
+		}
+		if len(shortest) > 0 && len(p) < len(shortest[0]) {
+			shortest = shortest[:0]
+		}
+		shortest = append(shortest, p)
+	}
+	return shortest
+}
+
+// fieldPathString returns a chain of fields in the form used by selectors, e.g. HTTPConfig.Timeout.
+func fieldPathString(path []*types.Var) string {
+	names := []string{}
+	for _, v := range path {
+		names = append(names, v.Name())
+	}
+	return strings.Join(names, ".")
+}
+
+// literalField returns the value of the element of a struct literal with the given key, or nil.
+func literalField(elems []ast.Expr, name string) ast.Expr {
+	for _, e := range elems {
+		if kv, ok := e.(*ast.KeyValueExpr); ok {
+			if id, ok := kv.Key.(*ast.Ident); ok && id.Name == name {
+				return kv.Value
+			}
+		}
+	}
+	return nil
+}
+
+// lastNonSpace returns the last byte of content that isn't whitespace, or 0.
+func lastNonSpace(content []byte) byte {
+	for i := len(content) - 1; i >= 0; i-- {
+		if c := content[i]; c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return c
+		}
+	}
+	return 0
+}
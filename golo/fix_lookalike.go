@@ -0,0 +1,91 @@
+package golo
+
+import (
+	"bytes"
+	"go/scanner"
+	"go/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// lookalikes maps characters that are easily pasted into code (from chat, documents or web
+// pages) to the ASCII characters they stand in for. Outside of strings and comments the
+// scanner rejects them all, and none of them can mean anything else there.
+var lookalikes = map[rune]string{
+	// no-break and other spaces
+	'\u00a0': " ", '\u2000': " ", '\u2001': " ", '\u2002': " ", '\u2003': " ", '\u2004': " ", '\u2005': " ",
+	'\u2006': " ", '\u2007': " ", '\u2008': " ", '\u2009': " ", '\u200a': " ", '\u202f': " ", '\u205f': " ", '\u3000': " ",
+	// zero-width spaces and joiners (and byte order marks after the start of the file)
+	'\u200b': "", '\u200c': "", '\u200d': "", '\u2060': "", '\ufeff': "",
+	// curly quotes and primes
+	'\u201c': `"`, '\u201d': `"`, '\u201e': `"`, '\u2033': `"`, '\u2018': "'", '\u2019': "'", '\u2032': "'",
+	// en dash, em dash and minus sign
+	'\u2013': "-", '\u2014': "-", '\u2212': "-",
+}
+
+// fixLookalikes fixes an illegal character that is one of the lookalikes by replacing every
+// lookalike in the file (outside of strings and comments) at once. Pasted code tends to have
+// many of them, and fixing them one by one would use up all the attempts to parse the file.
+// Characters that aren't lookalikes are left to be deferred.
+func (f *Fixer) fixLookalikes(filename string, content []byte, offset int, msg string) bool {
+	if !strings.HasPrefix(msg, "illegal character U+") && !strings.HasPrefix(msg, "curly quotation mark") && msg != "illegal byte order mark" {
+		return false
+	}
+	if offset >= len(content) {
+		return false
+	}
+	if r, _ := utf8.DecodeRune(content[offset:]); !isLookalike(r) {
+		return false
+	}
+
+	// strings and comments can contain anything, so they are left alone
+	literals := [][2]int{}
+	s := scanner.Scanner{}
+	s.Init(token.NewFileSet().AddFile(filename, -1, len(content)), content, nil, scanner.ScanComments)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.STRING || tok == token.CHAR || tok == token.COMMENT {
+			start, end := int(pos)-1, int(pos)-1+len(lit)
+			// the scanner drops carriage returns from raw strings
+			if strings.HasPrefix(lit, "`") {
+				if i := bytes.IndexByte(content[start+1:], '`'); i >= 0 {
+					end = start + i + 2
+				}
+			}
+			literals = append(literals, [2]int{start, end})
+		}
+	}
+
+	out := make([]byte, 0, len(content))
+	count := 0
+	for i := 0; i < len(content); {
+		if len(literals) > 0 && i >= literals[0][0] {
+			out = append(out, content[i:literals[0][1]]...)
+			i = literals[0][1]
+			literals = literals[1:]
+			continue
+		}
+		r, size := utf8.DecodeRune(content[i:])
+		if isLookalike(r) {
+			out = append(out, lookalikes[r]...)
+			count++
+		} else {
+			out = append(out, content[i:i+size]...)
+		}
+		i += size
+	}
+	if count == 0 {
+		return false
+	}
+	f.logf("golo: note: replaced %d %s (like non-breaking spaces and curly quotes) in %s", count, plural(count, "lookalike character", "lookalike characters"), filename)
+	return f.update(filename, out)
+}
+
+// isLookalike returns true if r is one of the lookalikes.
+func isLookalike(r rune) bool {
+	_, ok := lookalikes[r]
+	return ok
+}
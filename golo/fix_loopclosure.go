@@ -0,0 +1,219 @@
+package golo
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/loopclosure"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixLoopClosures fixes the loop variables that vet's loopclosure check reports as captured
+// by a func literal that may run after the iteration is over (in a go or defer statement, or a
+// parallel subtest), by declaring a copy of the variable at the start of the loop body (v := v),
+// which is what Go 1.22 does for every loop.
+//
+// These are not compile errors, so fixLoopClosures only runs if Options.FixLoopClosure is set,
+// only once pkg type-checks, and only if its loops share their variables between iterations
+// (because the go command, or the go version in the module's go.mod, is older than go1.22).
+// The check is run again when the package is reloaded, and if it still finds variables
+// captured in a file that was fixed, they are left alone.
+func (f *Fixer) fixLoopClosures(pkg *packages.Package) bool {
+	if !f.options.FixLoopClosure || !f.allows(Guessing) || pkg.TypesInfo == nil || !f.sharesLoopVars(pkg) {
+		return false
+	}
+	if f.closures == nil {
+		f.closures = map[string]bool{}
+	}
+
+	byFile := map[*ast.File][]analysis.Diagnostic{}
+	for _, d := range loopClosures(pkg) {
+		for _, file := range pkg.Syntax {
+			if file.FileStart <= d.Pos && d.Pos < file.FileEnd {
+				byFile[file] = append(byFile[file], d)
+			}
+		}
+	}
+
+	fixed := false
+	for _, file := range pkg.Syntax {
+		diags := byFile[file]
+		filename := pkg.Fset.Position(file.Pos()).Filename
+		if len(diags) == 0 || f.changed[filename] || !f.sources[filename] {
+			continue
+		}
+		if f.closures[filename] {
+			f.warnOnce("loopclosure:"+filename, "golo: note: the loop variables captured in %s were left alone, as copying them didn't fix every capture", filename)
+			continue
+		}
+		content, err := f.readFile(filename)
+		if err != nil || len(content) != pkg.Fset.File(file.Pos()).Size() {
+			continue
+		}
+
+		// each variable is copied once, however many times it is captured
+		copies := map[ast.Stmt][]string{}
+		reported := []analysis.Diagnostic{}
+		for _, d := range diags {
+			loop, name, ok := capturedLoop(pkg, file, d.Pos)
+			if !ok {
+				continue
+			}
+			if !slices.Contains(copies[loop], name) {
+				copies[loop] = append(copies[loop], name)
+			}
+			reported = append(reported, d)
+		}
+		if len(reported) == 0 {
+			continue
+		}
+		edits := []edit{}
+		for loop, names := range copies {
+			edits = append(edits, copyEdit(file, content, loopBody(loop), names))
+		}
+		for _, d := range reported {
+			pos := pkg.Fset.Position(d.Pos)
+			name := strings.TrimSuffix(strings.TrimPrefix(d.Message, "loop variable "), " captured by func literal")
+			f.note = fmt.Sprintf("%s is now copied for each iteration of the loop (as from Go 1.22), so the func literal sees the value from its own iteration", name)
+			f.report(errors.New(pos.String()+": "+d.Message), pos, d.Message, Rewrite, Guessing, content)
+		}
+		f.update(filename, applyEdits(content, edits))
+		f.changed[filename] = true
+		f.closures[filename] = true
+		fixed = true
+	}
+	return fixed
+}
+
+// sharesLoopVars returns true if the loops in pkg share their variables between iterations,
+// as they did before Go 1.22.
+func (f *Fixer) sharesLoopVars(pkg *packages.Package) bool {
+	if f.goBefore(22) {
+		return true
+	}
+	return pkg.Module != nil && pkg.Module.GoVersion != "" && versionBefore(pkg.Module.GoVersion, 22)
+}
+
+// loopClosures runs vet's loopclosure check on pkg, and returns what it reports.
+func loopClosures(pkg *packages.Package) []analysis.Diagnostic {
+	diags := []analysis.Diagnostic{}
+	pass := &analysis.Pass{
+		Analyzer:   loopclosure.Analyzer,
+		Fset:       pkg.Fset,
+		Files:      pkg.Syntax,
+		Pkg:        pkg.Types,
+		TypesInfo:  pkg.TypesInfo,
+		TypesSizes: pkg.TypesSizes,
+		ResultOf:   map[*analysis.Analyzer]any{inspect.Analyzer: inspector.New(pkg.Syntax)},
+		Report:     func(d analysis.Diagnostic) { diags = append(diags, d) },
+	}
+	if _, err := loopclosure.Analyzer.Run(pass); err != nil {
+		return nil
+	}
+	return diags
+}
+
+// capturedLoop returns the loop whose variable is captured at pos (as reported by
+// loopclosure), and the name of the variable. A three-clause for loop is only returned if its
+// body doesn't change the variable, as a change to the copy wouldn't be seen by the loop.
+func capturedLoop(pkg *packages.Package, file *ast.File, pos token.Pos) (ast.Stmt, string, bool) {
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) == 0 {
+		return nil, "", false
+	}
+	id, ok := path[0].(*ast.Ident)
+	if !ok {
+		return nil, "", false
+	}
+	obj := pkg.TypesInfo.Uses[id]
+	if obj == nil {
+		return nil, "", false
+	}
+	for _, n := range path[1:] {
+		switch n := n.(type) {
+		case *ast.RangeStmt:
+			if isObj(pkg, n.Key, obj) || isObj(pkg, n.Value, obj) {
+				return n, id.Name, true
+			}
+		case *ast.ForStmt:
+			if n.Init != nil && initDeclares(pkg, n.Init, obj) {
+				return n, id.Name, !changes(pkg, n.Body, obj)
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// isObj returns true if e is an identifier for obj.
+func isObj(pkg *packages.Package, e ast.Expr, obj types.Object) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && pkg.TypesInfo.ObjectOf(id) == obj
+}
+
+// initDeclares returns true if init (the init statement of a for loop) declares obj.
+func initDeclares(pkg *packages.Package, init ast.Stmt, obj types.Object) bool {
+	assign, ok := init.(*ast.AssignStmt)
+	if !ok || assign.Tok != token.DEFINE {
+		return false
+	}
+	for _, lhs := range assign.Lhs {
+		if isObj(pkg, lhs, obj) {
+			return true
+		}
+	}
+	return false
+}
+
+// changes returns true if body assigns to obj (or takes its address).
+func changes(pkg *packages.Package, body *ast.BlockStmt, obj types.Object) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				found = found || isObj(pkg, lhs, obj)
+			}
+		case *ast.IncDecStmt:
+			found = found || isObj(pkg, n.X, obj)
+		case *ast.UnaryExpr:
+			found = found || n.Op == token.AND && isObj(pkg, n.X, obj)
+		}
+		return !found
+	})
+	return found
+}
+
+// loopBody returns the body of a for or range statement.
+func loopBody(loop ast.Stmt) *ast.BlockStmt {
+	if r, ok := loop.(*ast.RangeStmt); ok {
+		return r.Body
+	}
+	return loop.(*ast.ForStmt).Body
+}
+
+// copyEdit returns the edit that declares a copy of each of names at the start of body, on a
+// line of its own (indented like the first statement) unless the body is all on one line.
+func copyEdit(file *ast.File, content []byte, body *ast.BlockStmt, names []string) edit {
+	decls := []string{}
+	for _, name := range names {
+		decls = append(decls, name+" := "+name)
+	}
+	if len(body.List) > 0 {
+		start := int(body.List[0].Pos() - file.FileStart)
+		line := strings.LastIndexByte(string(content[:start]), '\n') + 1
+		if indent := string(content[line:start]); line > int(body.Lbrace-file.FileStart) && strings.TrimSpace(indent) == "" {
+			return edit{line, line, indent + strings.Join(decls, "\n"+indent) + "\n"}
+		}
+	}
+	at := int(body.Lbrace-file.FileStart) + 1
+	return edit{at, at, " " + strings.Join(decls, "; ") + ";"}
+}
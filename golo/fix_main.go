@@ -0,0 +1,94 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// missingMain is the message for a main package without a func main (as the linker reports it).
+const missingMain = "function main is undeclared in the main package"
+
+// fixMain fixes the package that golo run was asked to run, if it can't be run. A package that
+// isn't a main package (usually a directory under cmd/ whose package clause was never changed
+// from the directory's name) is renamed to main in the overlay. A main package without a func
+// main gets one that panics, so that the build completes and says what is wrong when it runs.
+// If Options.GuessMain is set, and the package has exactly one exported function without any
+// parameters, func main calls that instead.
+func (f *Fixer) fixMain(pkg *packages.Package) bool {
+	if f.mode != "run" || len(pkg.Errors) > 0 || pkg.Types == nil || len(pkg.Syntax) == 0 {
+		return false
+	}
+	// (pkg.Name and pkg.PkgPath aren't loaded)
+	if pkg.Types.Name() != "main" {
+		return f.mains[pkg.Types.Path()] && f.renameToMain(pkg)
+	}
+	if pkg.Types.Scope().Lookup("main") != nil {
+		return false
+	}
+
+	file := pkg.Syntax[0]
+	filename := pkg.Fset.File(file.Pos()).Name()
+	content, err := f.readFile(filename)
+	if err != nil {
+		return false
+	}
+	code := fmt.Sprintf("panic(%#v)", "golo: func main is missing")
+	kind, confidence := Deferred, Safe
+	switch run := niladicFunc(pkg.Types); {
+	case run != "" && f.options.GuessMain && f.allows(Guessing):
+		code, kind, confidence = run+"()", Declared, Guessing
+		f.note = fmt.Sprintf("func main calls %s, as it is the only exported function without parameters", run)
+		f.logf("golo: note: %s", f.note)
+	case run != "":
+		f.logf("golo: note: run with -guess-main to call %s from func main", run)
+	}
+	f.update(filename, content, []byte("\nfunc main() { "+code+" }\n"))
+	f.changed[filename] = true
+	pos := pkg.Fset.Position(file.Package)
+	f.report(&packages.Error{Pos: pos.String(), Msg: missingMain}, pos, missingMain, kind, confidence, content)
+	return true
+}
+
+// renameToMain changes the package clause of each of pkg's files to package main.
+func (f *Fixer) renameToMain(pkg *packages.Package) bool {
+	msg := fmt.Sprintf("package %s is not a main package", pkg.Types.Path())
+	f.logf("golo: note: changed package %s to package main, so that it can be run", pkg.Types.Name())
+	for i, file := range pkg.Syntax {
+		filename := pkg.Fset.File(file.Pos()).Name()
+		content, err := f.readFile(filename)
+		if err != nil {
+			return false
+		}
+		f.update(filename, applyEdits(content, []edit{nodeEdit(file, file.Name, "main")}))
+		f.changed[filename] = true
+		// the package is only reported once, at its first file
+		if i == 0 {
+			pos := pkg.Fset.Position(file.Package)
+			f.report(&packages.Error{Pos: pos.String(), Msg: msg}, pos, msg, Rewrite, Preserving, content)
+		}
+	}
+	return true
+}
+
+// niladicFunc returns the name of the only exported function in pkg that has no parameters
+// (or "" if there isn't exactly one).
+func niladicFunc(pkg *types.Package) string {
+	found := ""
+	for _, name := range pkg.Scope().Names() {
+		fn, ok := pkg.Scope().Lookup(name).(*types.Func)
+		if !ok || !ast.IsExported(name) {
+			continue
+		}
+		if sig := fn.Type().(*types.Signature); sig.Params().Len() > 0 || sig.TypeParams().Len() > 0 {
+			continue
+		}
+		if found != "" {
+			return ""
+		}
+		found = name
+	}
+	return found
+}
@@ -0,0 +1,212 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/packages"
+)
+
+var reMissingMethod = regexp.MustCompile(`does not implement .* \(missing method (\w+)\)$`)
+
+// fixMissingMethod fixes types that don't implement an interface because the interface has
+// a method that the type doesn't (usually because it was just added to the interface), by
+// adding a method that panics to the type. The method is added to the end of the file that
+// declares the type, with the signature from the interface, and a pointer receiver if
+// the type's other methods have them.
+func (f *Fixer) fixMissingMethod(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) FixKind {
+	m := reMissingMethod.FindStringSubmatch(msg)
+	if m == nil {
+		return ""
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, expected := operandAt(pkg, file, pos)
+	if path == nil || !types.IsInterface(expected) {
+		return ""
+	}
+	t := pkg.TypesInfo.TypeOf(path[0].(ast.Expr))
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() != pkg.Types || named.TypeParams().Len() > 0 {
+		return ""
+	}
+	obj, _, _ := types.LookupFieldOrMethod(expected, false, nil, m[1])
+	method, ok := obj.(*types.Func)
+	if !ok {
+		return ""
+	}
+
+	// the method goes in the file that declares the type
+	declFile := fileAt(pkg, named.Obj().Pos())
+	if declFile == nil {
+		return ""
+	}
+	declName := pkg.Fset.Position(declFile.Pos()).Filename
+	if f.changed[declName] && declName != filename {
+		return postponed
+	}
+	declContent, err := f.readFile(declName)
+	if err != nil || len(declContent) != int(declFile.FileEnd-declFile.FileStart) {
+		return ""
+	}
+
+	sig, ok := methodSignature(pkg, declFile, method)
+	if !ok {
+		return ""
+	}
+	recv, pointer := receiverOf(named)
+	recvType := named.Obj().Name()
+	if pointer {
+		recvType = "*" + recvType
+	}
+	stub := fmt.Sprintf("%s\nfunc (%s %s) %s { panic(%#v) }", stubMarker, recv, recvType, sig, "missing method "+method.Name())
+
+	rewritten := append(declContent[:len(declContent):len(declContent)], []byte("\n"+stub+"\n")...)
+	f.update(declName, rewritten)
+	f.changed[declName] = true
+	f.logf("golo: added method %s to %s in %s", method.Name(), named.Obj().Name(), declName)
+	return Deferred
+}
+
+var reWrongMethod = regexp.MustCompile(`does not implement .* \(wrong type for method (\w+)\)`)
+
+// fixWrongMethod fixes types that don't implement an interface because one of their methods
+// has the wrong signature. If golo added the method (it is marked with stubMarker), then the
+// interface has changed since, so the method is replaced with one with the new signature.
+// Methods that someone wrote are left alone (so the error is deferred), with a note saying
+// what the signature should be.
+func (f *Fixer) fixWrongMethod(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) FixKind {
+	m := reWrongMethod.FindStringSubmatch(msg)
+	if m == nil {
+		return ""
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, expected := operandAt(pkg, file, pos)
+	if path == nil || !types.IsInterface(expected) {
+		return ""
+	}
+	t := pkg.TypesInfo.TypeOf(path[0].(ast.Expr))
+	obj, _, _ := types.LookupFieldOrMethod(t, true, pkg.Types, m[1])
+	have, ok := obj.(*types.Func)
+	if !ok || have.Pkg() != pkg.Types {
+		return ""
+	}
+	obj, _, _ = types.LookupFieldOrMethod(expected, false, pkg.Types, m[1])
+	want, ok := obj.(*types.Func)
+	if !ok {
+		return ""
+	}
+
+	declFile := fileAt(pkg, have.Pos())
+	if declFile == nil {
+		return ""
+	}
+	var decl *ast.FuncDecl
+	for _, d := range declFile.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok && fn.Name.Pos() == have.Pos() {
+			decl = fn
+		}
+	}
+	if decl == nil || decl.Recv == nil || len(decl.Recv.List) != 1 {
+		return ""
+	}
+	sig, ok := methodSignature(pkg, declFile, want)
+	if !ok {
+		return ""
+	}
+	recvType := types.ExprString(decl.Recv.List[0].Type)
+	declName := pkg.Fset.Position(declFile.Pos()).Filename
+	declContent, err := f.readFile(declName)
+	if err != nil || len(declContent) != int(declFile.FileEnd-declFile.FileStart) {
+		return ""
+	}
+
+	if !isStub(declFile, declContent, decl) {
+		f.note = fmt.Sprintf("%s.%s should be %s", strings.TrimPrefix(recvType, "*"), have.Name(), sig)
+		f.logf("golo: note: %s", f.note)
+		return ""
+	}
+	if f.changed[declName] && declName != filename {
+		return postponed
+	}
+	recv := ""
+	if names := decl.Recv.List[0].Names; len(names) == 1 {
+		recv = names[0].Name + " "
+	}
+	stub := fmt.Sprintf("func (%s%s) %s { panic(%#v) }", recv, recvType, sig, "missing method "+want.Name())
+
+	start, end := int(decl.Pos()-declFile.FileStart), int(decl.End()-declFile.FileStart)
+	f.update(declName, declContent[:start], []byte(stub+newLinesInRange(declContent[start:end])), declContent[end:])
+	f.changed[declName] = true
+	f.note = fmt.Sprintf("golo updated its stub for %s.%s", strings.TrimPrefix(recvType, "*"), want.Name())
+	f.logf("golo: updated method %s of %s in %s", want.Name(), strings.TrimPrefix(recvType, "*"), declName)
+	return Deferred
+}
+
+// methodSignature returns the signature of method (without func or the receiver), with
+// types written as they would be in file.
+func methodSignature(pkg *packages.Package, file *ast.File, method *types.Func) (string, bool) {
+	sig := method.Type().(*types.Signature)
+	params := []string{}
+	for i := 0; i < sig.Params().Len(); i++ {
+		pt := sig.Params().At(i).Type()
+		prefix := ""
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			pt, prefix = pt.(*types.Slice).Elem(), "..."
+		}
+		s, ok := typeExpr(pkg, file, pt)
+		if !ok {
+			return "", false
+		}
+		params = append(params, prefix+s)
+	}
+	results := []string{}
+	for i := 0; i < sig.Results().Len(); i++ {
+		s, ok := typeExpr(pkg, file, sig.Results().At(i).Type())
+		if !ok {
+			return "", false
+		}
+		results = append(results, s)
+	}
+	result := strings.Join(results, ", ")
+	if len(results) > 1 {
+		result = "(" + result + ")"
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s(%s) %s", method.Name(), strings.Join(params, ", "), result)), true
+}
+
+// fileAt returns the file in pkg that contains pos.
+func fileAt(pkg *packages.Package, pos token.Pos) *ast.File {
+	for _, f := range pkg.Syntax {
+		if f.FileStart <= pos && pos <= f.FileEnd {
+			return f
+		}
+	}
+	return nil
+}
+
+// receiverOf returns the name of the receiver to use for a new method on named,
+// and whether it should be a pointer, based on its existing methods.
+func receiverOf(named *types.Named) (string, bool) {
+	name := string(unicode.ToLower([]rune(named.Obj().Name())[0]))
+	pointer := false
+	for i := 0; i < named.NumMethods(); i++ {
+		recv := named.Method(i).Type().(*types.Signature).Recv()
+		if _, ok := recv.Type().(*types.Pointer); ok {
+			pointer = true
+		}
+		if recv.Name() != "" && recv.Name() != "_" {
+			name = recv.Name()
+		}
+	}
+	return name, pointer
+}
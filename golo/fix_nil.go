@@ -0,0 +1,94 @@
+package golo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixNil fixes uses of nil where the type that is expected can't be nil.
+// Where a value is needed nil is replaced with the zero value of the expected type,
+// which is usually what was meant. Comparisons with nil are deferred instead, as it's
+// not clear what they should do.
+func (f *Fixer) fixNil(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) FixKind {
+	pos := file.FileStart + token.Pos(offset)
+
+	if strings.HasPrefix(msg, "invalid operation: ") && strings.Contains(msg, "untyped nil") {
+		path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+		for _, n := range path {
+			if cmp, ok := n.(*ast.BinaryExpr); ok && (cmp.Op == token.EQL || cmp.Op == token.NEQ) && (isNil(cmp.X) || isNil(cmp.Y)) {
+				f.replaceNode(file, filename, content, cmp, f.typedPanic("bool", msg))
+				return Deferred
+			}
+		}
+		return ""
+	}
+
+	if !strings.HasPrefix(msg, "cannot use nil as ") || !f.allows(Preserving) {
+		return ""
+	}
+	path, expected := operandAt(pkg, file, pos)
+	if path == nil || !isNil(path[0]) {
+		return ""
+	}
+
+	zero, importPath, ok := zeroValue(pkg, file, expected)
+	if !ok {
+		return ""
+	}
+	rewritten := applyEdits(content, []edit{nodeEdit(file, path[0], zero)})
+	if importPath != "" {
+		rewritten, _ = addImport(file, rewritten, importPath)
+	}
+	f.update(filename, rewritten)
+	return Rewrite
+}
+
+func isNil(n ast.Node) bool {
+	id, ok := n.(*ast.Ident)
+	return ok && id.Name == "nil"
+}
+
+// zeroValue returns an expression for the zero value of t, as it should be written in file.
+// If the expression uses a package that file does not import, its path is also returned
+// so that the caller can add the import.
+func zeroValue(pkg *packages.Package, file *ast.File, t types.Type) (string, string, bool) {
+	if _, ok := t.(*types.TypeParam); ok {
+		s, ok := typeExpr(pkg, file, t)
+		return "*new(" + s + ")", "", ok
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false", "", true
+		case u.Info()&types.IsString != 0:
+			return `""`, "", true
+		case u.Info()&types.IsNumeric != 0:
+			return "0", "", true
+		}
+		return "", "", false
+	case *types.Pointer, *types.Slice, *types.Map, *types.Chan, *types.Signature, *types.Interface:
+		return "nil", "", true
+	}
+
+	if s, ok := typeExpr(pkg, file, t); ok {
+		return s + "{}", "", true
+	}
+	// addImport refers to the package by the last element of its path
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.TypeArgs().Len() > 0 {
+		return "", "", false
+	}
+	p := named.Obj().Pkg()
+	if path.Base(p.Path()) != p.Name() {
+		return "", "", false
+	}
+	return p.Name() + "." + named.Obj().Name() + "{}", p.Path(), true
+}
@@ -0,0 +1,144 @@
+package golo
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+var reFoundPackages = regexp.MustCompile(`found packages (\w+) \(.*\) and (\w+) \(.*\) in (.*)$`)
+
+// packageClashDir returns the directory that msg says contains files from more than one package.
+func packageClashDir(msg string) (string, bool) {
+	m := reFoundPackages.FindStringSubmatch(msg)
+	if m == nil {
+		return "", false
+	}
+	return m[3], true
+}
+
+// fixPackageClash fixes directories that contain files from more than one package
+// (usually because a scratch main.go was left in a library's directory).
+// The package that most of the files are in wins: a file in another package that declares
+// func main() is excluded from the build with //go:build ignore, and the package clauses
+// of any other files are changed to match.
+func (f *Fixer) fixPackageClash(pkg *packages.Package) bool {
+	var msg, dir string
+	for _, e := range pkg.Errors {
+		if d, ok := packageClashDir(e.Msg); ok {
+			msg, dir = e.Msg, d
+			break
+		}
+	}
+	if dir == "" {
+		return false
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+
+	type source struct {
+		filename string
+		content  []byte
+		file     *ast.File
+	}
+	fset := token.NewFileSet()
+	sources := []source{}
+	count := map[string]int{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		filename := filepath.Join(dir, name)
+		content, err := f.readFile(filename)
+		if err != nil {
+			return false
+		}
+		// only files that the build would include count
+		ctx := f.buildContext()
+		ctx.OpenFile = func(string) (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(content)), nil }
+		if ok, err := ctx.MatchFile(dir, name); err != nil || !ok {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+		if file == nil || file.Name == nil {
+			return false
+		}
+		sources = append(sources, source{filename, content, file})
+		count[file.Name.Name]++
+	}
+
+	if len(count) < 2 {
+		return false
+	}
+	// the package with the most files wins (and if it's a tie, a stray main is more likely)
+	winner := ""
+	for name := range count {
+		switch {
+		case winner == "" || count[name] > count[winner]:
+			winner = name
+		case count[name] < count[winner]:
+		case (name == "main") != (winner == "main"):
+			if winner == "main" {
+				winner = name
+			}
+		case name < winner:
+			winner = name
+		}
+	}
+
+	for _, s := range sources {
+		if s.file.Name.Name == winner {
+			continue
+		}
+		pos := fset.Position(s.file.Package)
+		if s.file.Name.Name == "main" && declaresMain(s.file) {
+			f.logf("golo: note: ignoring %s, as it is a main package in package %s's directory", s.filename, winner)
+			f.update(s.filename, ignoreFile(s.file, s.content))
+		} else {
+			f.logf("golo: note: %s is now in package %s (not %s)", s.filename, winner, s.file.Name.Name)
+			f.update(s.filename, applyEdits(s.content, []edit{nodeEdit(s.file, s.file.Name, winner)}))
+		}
+		f.changed[s.filename] = true
+		f.report(&packages.Error{Pos: pos.String(), Msg: msg}, pos, msg, Rewrite, Guessing, s.content)
+	}
+	return true
+}
+
+func declaresMain(file *ast.File) bool {
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreFile returns content with a //go:build ignore constraint, so that the go command skips it.
+// Any existing constraint is replaced. (The file's line numbers don't matter as it isn't built.)
+func ignoreFile(file *ast.File, content []byte) []byte {
+	edits := []edit{}
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, "//go:build") || strings.HasPrefix(c.Text, "// +build") {
+				edits = append(edits, nodeEdit(file, c, "//"))
+			}
+		}
+	}
+	edits = append(edits, posEdit(file, file.FileStart, file.FileStart, "//go:build ignore\n\n"))
+	return applyEdits(content, edits)
+}
@@ -0,0 +1,42 @@
+package golo
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+var rePointerReceiver = regexp.MustCompile(`does not implement \S+ \(method \S+ has pointer receiver\)$`)
+
+// fixPointerReceiver fixes a value that is used as an interface, but whose type only implements
+// the interface through methods with pointer receivers (usually because one of them was changed
+// to mutate its receiver), by using a pointer to the value instead. This only works if the value
+// is addressable (or a composite literal), so values like map elements are deferred instead.
+func (f *Fixer) fixPointerReceiver(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if !rePointerReceiver.MatchString(msg) {
+		return false
+	}
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var value ast.Expr
+	for _, n := range path {
+		if e, ok := n.(ast.Expr); ok && e.Pos() == pos {
+			value = e
+		}
+	}
+	if value == nil {
+		return false
+	}
+
+	_, isLiteral := astutil.Unparen(value).(*ast.CompositeLit)
+	if tv, ok := pkg.TypesInfo.Types[value]; !ok || !tv.Addressable() && !isLiteral {
+		if f.verbose {
+			f.logf("golo: %s is not addressable, so it can't be used as a pointer", exprString(content, file, value))
+		}
+		return false
+	}
+	return f.speculate(pkg, filename, applyEdits(content, []edit{posEdit(file, value.Pos(), value.Pos(), "&")}))
+}
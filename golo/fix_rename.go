@@ -0,0 +1,147 @@
+package golo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixFuzzyRename fixes "undefined: name" errors by using a declaration from the same
+// package with a similar name (differing only in case, or by at most two edits),
+// as often happens after renaming something without updating every use.
+// If there is not exactly one such declaration that fits, nothing is changed.
+//
+// This is a guess, so it is only done if Options.FuzzyRename is set, and it is
+// reported prominently.
+func (f *Fixer) fixFuzzyRename(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if !f.options.FuzzyRename || !strings.HasPrefix(msg, "undefined: ") {
+		return false
+	}
+	name := strings.TrimPrefix(msg, "undefined: ")
+
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) < 2 {
+		return false
+	}
+	id, ok := path[0].(*ast.Ident)
+	if !ok || id.Name != name {
+		return false
+	}
+	call, _ := path[1].(*ast.CallExpr)
+	if call != nil && call.Fun != id {
+		call = nil
+	}
+
+	var caseOnly, close []types.Object
+	scope := pkg.Types.Scope()
+	for _, n := range scope.Names() {
+		obj := scope.Lookup(n)
+		if n == name || !fits(pkg, obj, call) {
+			continue
+		}
+		if strings.EqualFold(n, name) {
+			caseOnly = append(caseOnly, obj)
+		} else if editDistance(n, name) <= 2 {
+			close = append(close, obj)
+		}
+	}
+
+	candidates := caseOnly
+	if len(candidates) == 0 {
+		candidates = close
+	}
+	if len(candidates) != 1 {
+		if len(candidates) > 1 && f.verbose {
+			f.logf("golo: not renaming %s, it could be any of %v", name, candidates)
+		}
+		return false
+	}
+
+	to := candidates[0].Name()
+	if !f.speculate(pkg, filename, applyEdits(content, []edit{nodeEdit(file, id, to)})) {
+		return false
+	}
+	f.logf("golo: GUESSED: using %s instead of undefined %s at %s:%d", to, name, filename, lineOf(content, offset))
+	return true
+}
+
+// fits returns true if obj could be used in place of an undefined identifier.
+// If call is non-nil, the identifier is being called, and so obj must be a function
+// that accepts the arguments given.
+func fits(pkg *packages.Package, obj types.Object, call *ast.CallExpr) bool {
+	if call == nil {
+		_, isVar := obj.(*types.Var)
+		_, isConst := obj.(*types.Const)
+		return isVar || isConst
+	}
+
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	params := sig.Params()
+	if len(call.Args) != params.Len() && !(sig.Variadic() && len(call.Args) >= params.Len()-1) {
+		return false
+	}
+	for i, arg := range call.Args {
+		t := pkg.TypesInfo.TypeOf(arg)
+		if t == nil {
+			continue
+		}
+		var param types.Type
+		if sig.Variadic() && i >= params.Len()-1 {
+			param = params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+		} else {
+			param = params.At(i).Type()
+		}
+		if !types.AssignableTo(t, param) {
+			return false
+		}
+	}
+	return true
+}
+
+// editDistance returns the levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(first int, rest ...int) int {
+	for _, n := range rest {
+		if n < first {
+			first = n
+		}
+	}
+	return first
+}
+
+func maxInt(first int, rest ...int) int {
+	for _, n := range rest {
+		if n > first {
+			first = n
+		}
+	}
+	return first
+}
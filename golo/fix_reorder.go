@@ -0,0 +1,102 @@
+package golo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixArgumentOrder fixes calls where the arguments are given in the wrong order, for example
+// f(name, count) when f is declared as f(count int, name string).
+// The arguments are only reordered if there is exactly one order in which every argument
+// has the same type as its parameter, otherwise the mismatched argument is deferred as usual.
+func (f *Fixer) fixArgumentOrder(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if !strings.HasPrefix(msg, "cannot use ") || !strings.Contains(msg, " in argument to ") {
+		return false
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var call *ast.CallExpr
+	for _, n := range path {
+		if n, ok := n.(*ast.CallExpr); ok && n.Lparen < pos {
+			call = n
+			break
+		}
+	}
+	if call == nil || call.Ellipsis.IsValid() || len(call.Args) < 2 || len(call.Args) > 6 {
+		return false
+	}
+	sig, ok := underlying(pkg.TypesInfo.TypeOf(call.Fun)).(*types.Signature)
+	if !ok || sig.Variadic() || sig.Params().Len() != len(call.Args) {
+		return false
+	}
+
+	args := make([]types.Type, len(call.Args))
+	for i, arg := range call.Args {
+		if args[i] = pkg.TypesInfo.TypeOf(arg); args[i] == nil {
+			return false
+		}
+		args[i] = types.Default(args[i])
+	}
+
+	var order []int
+	matches := 0
+	permute(len(args), func(p []int) {
+		for i, j := range p {
+			if !types.Identical(args[j], sig.Params().At(i).Type()) {
+				return
+			}
+		}
+		matches++
+		order = append(order[:0], p...)
+	})
+	if matches != 1 {
+		return false
+	}
+
+	edits := []edit{}
+	for i, j := range order {
+		arg := exprString(content, file, call.Args[j])
+		// moving an argument that spans lines would change the line numbers of the code after it
+		if strings.Contains(arg, "\n") {
+			return false
+		}
+		if i != j {
+			edits = append(edits, nodeEdit(file, call.Args[i], arg))
+		}
+	}
+	if len(edits) == 0 {
+		return false
+	}
+	if !f.speculate(pkg, filename, applyEdits(content, edits)) {
+		return false
+	}
+	f.logf("golo: reordered arguments to %s at %s:%d", exprString(content, file, call.Fun), filename, lineOf(content, offset))
+	return true
+}
+
+// permute calls fn with every permutation of 0..n-1.
+func permute(n int, fn func([]int)) {
+	p := make([]int, n)
+	used := make([]bool, n)
+	var rec func(int)
+	rec = func(i int) {
+		if i == n {
+			fn(p)
+			return
+		}
+		for j := 0; j < n; j++ {
+			if !used[j] {
+				used[j], p[i] = true, j
+				rec(i + 1)
+				used[j] = false
+			}
+		}
+	}
+	rec(0)
+}
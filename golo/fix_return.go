@@ -0,0 +1,107 @@
+package golo
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixMissingReturn fixes functions that don't end in a return statement by adding a panic
+// where the return is missing, so that all of the existing code still runs.
+// If the function ends with an if/else in which some branches return, the panic is added
+// to the end of the branches that don't, otherwise it is added to the end of the function.
+func (f *Fixer) fixMissingReturn(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if msg != "missing return" {
+		return false
+	}
+
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var body *ast.BlockStmt
+	for _, n := range path {
+		if n, ok := n.(*ast.BlockStmt); ok && n.Rbrace == pos {
+			body = n
+			break
+		}
+	}
+	if body == nil {
+		return false
+	}
+	fn := enclosingFunc(file, body.Lbrace)
+
+	// the panic goes just before the }, on the same line so line numbers don't change
+	panicBefore := func(rbrace token.Pos) edit {
+		line := content[:rbrace-file.FileStart]
+		if i := bytes.LastIndexByte(line, '\n'); i >= 0 {
+			line = line[i+1:]
+		}
+		code := f.deferStmt(file, content, fn, f.panicMsg(msg)) + "; "
+		if len(bytes.TrimSpace(line)) > 0 {
+			return posEdit(file, rbrace, rbrace, "; "+code)
+		}
+		return posEdit(file, rbrace, rbrace, code)
+	}
+
+	if len(body.List) > 0 {
+		if last, ok := body.List[len(body.List)-1].(*ast.IfStmt); ok && last.Else != nil {
+			edits := []edit{}
+			for _, block := range nonTerminatingBranches(last) {
+				edits = append(edits, panicBefore(block.Rbrace))
+			}
+			if len(edits) > 0 && f.speculate(pkg, filename, applyEdits(content, edits)) {
+				return true
+			}
+		}
+	}
+	return f.update(filename, applyEdits(content, []edit{panicBefore(body.Rbrace)}))
+}
+
+// nonTerminatingBranches returns the blocks of an if/else chain that can finish without returning.
+func nonTerminatingBranches(stmt *ast.IfStmt) []*ast.BlockStmt {
+	blocks := []*ast.BlockStmt{}
+	if !isTerminating(stmt.Body) {
+		blocks = append(blocks, stmt.Body)
+	}
+	switch e := stmt.Else.(type) {
+	case *ast.BlockStmt:
+		if !isTerminating(e) {
+			blocks = append(blocks, e)
+		}
+	case *ast.IfStmt:
+		if e.Else == nil {
+			return nil
+		}
+		blocks = append(blocks, nonTerminatingBranches(e)...)
+	}
+	return blocks
+}
+
+// isTerminating is a simplified version of the spec's definition of a terminating statement.
+// It is only used to choose where to add a panic, so it doesn't matter if it's occasionally wrong.
+func isTerminating(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return s.Tok == token.GOTO
+	case *ast.ExprStmt:
+		call, ok := s.X.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		id, ok := call.Fun.(*ast.Ident)
+		return ok && id.Name == "panic"
+	case *ast.BlockStmt:
+		return len(s.List) > 0 && isTerminating(s.List[len(s.List)-1])
+	case *ast.IfStmt:
+		return s.Else != nil && isTerminating(s.Body) && isTerminating(s.Else)
+	case *ast.ForStmt:
+		return s.Cond == nil
+	case *ast.LabeledStmt:
+		return isTerminating(s.Stmt)
+	}
+	return false
+}
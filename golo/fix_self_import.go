@@ -0,0 +1,171 @@
+package golo
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// reImportStack matches the lines in which the go command explains an import cycle:
+// "package a", followed by a line "\timports b from a.go" for each import in the cycle
+// (or "\timports b: import cycle not allowed" for the last, before Go 1.27).
+var reImportStack = regexp.MustCompile(`^(?:package|\timports) (\S+?)(?::|\s|$)`)
+
+// reSelfImport matches the error for an import that is part of a cycle. On Go 1.21 a
+// cycle through a test variant lists its ID too ("[a [a.test]]").
+var reSelfImport = regexp.MustCompile(`^could not import (\S+) \(import cycle: \[(\S+)(?: \[\S+\.test\])?\]\)$`)
+
+// reTestSelfImport matches the error for a _test.go file that imports the package it is in
+// (go list drops the import from the test variant, so there is no metadata for it).
+var reTestSelfImport = regexp.MustCompile(`^could not import (\S+) \(no metadata for (\S+)\)$`)
+
+// selfImports returns the packages that the go command's output says import themselves
+// (which it reports without a "# package" header, so rePackage doesn't find them).
+func selfImports(out []byte) []string {
+	pkgs := []string{}
+	prev := ""
+	for _, line := range strings.Split(string(out), "\n") {
+		m := reImportStack.FindStringSubmatch(line)
+		if m == nil {
+			prev = ""
+			continue
+		}
+		// (the cycle is "in test" when a _test.go file imports its own package)
+		if m[1] == prev && (strings.HasSuffix(line, ": import cycle not allowed") || strings.HasSuffix(line, ": import cycle not allowed in test")) {
+			pkgs = append(pkgs, prev)
+		}
+		prev = m[1]
+	}
+	return pkgs
+}
+
+// fixSelfImport fixes files that import the package they are in (usually because they were
+// copied from another package) by removing the import, and the package name from everything
+// that was used through it (so a.Foo becomes Foo). This also fixes external test files that
+// were moved into the package under test without removing its import. Cycles between
+// different packages can't be fixed like this, so they are left alone.
+func (f *Fixer) fixSelfImport(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	// a package that imports itself is the only thing in its import cycle
+	m := reSelfImport.FindStringSubmatch(msg)
+	if m == nil && strings.HasSuffix(filename, "_test.go") {
+		// the test variant's ID is "path [path.test]"
+		if m = reTestSelfImport.FindStringSubmatch(msg); m != nil && m[1] != strings.Fields(pkg.ID)[0] {
+			m = nil
+		}
+	}
+	if m == nil || m[1] != m[2] {
+		return false
+	}
+	importPath := m[1]
+	pos := file.FileStart + token.Pos(offset)
+	var spec *ast.ImportSpec
+	var decl *ast.GenDecl
+	for _, d := range file.Decls {
+		if g, ok := d.(*ast.GenDecl); ok && g.Tok == token.IMPORT {
+			for _, s := range g.Specs {
+				if s.Pos() <= pos && pos < s.End() {
+					spec, decl = s.(*ast.ImportSpec), g
+				}
+			}
+		}
+	}
+	if spec == nil {
+		return false
+	}
+
+	name := file.Name.Name
+	if spec.Name != nil {
+		name = spec.Name.Name
+	}
+	edits := []edit{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && id.Name == name {
+			// the import failed, so the name is usually undefined (but may be shadowed)
+			obj := pkg.TypesInfo.Uses[id]
+			if p, ok := obj.(*types.PkgName); obj == nil || ok && p.Imported().Path() == importPath {
+				edits = append(edits, posEdit(file, sel.Pos(), sel.Sel.Pos(), ""))
+				return false
+			}
+		}
+		return true
+	})
+
+	// the import's line is removed (or the whole declaration, if it is the only import in it)
+	start, end := spec.Pos(), spec.End()
+	if len(decl.Specs) == 1 {
+		start, end = decl.Pos(), decl.End()
+	}
+	s, e := int(start-file.FileStart), int(end-file.FileStart)
+	for s > 0 && (content[s-1] == ' ' || content[s-1] == '\t') {
+		s--
+	}
+	if e < len(content) && content[e] == '\n' && (s == 0 || content[s-1] == '\n') {
+		e++
+		// along with the blank line after a declaration
+		if len(decl.Specs) == 1 && e < len(content) && content[e] == '\n' && s > 1 && content[s-2] == '\n' {
+			e++
+		}
+	}
+	edits = append(edits, edit{s, e, ""})
+
+	if !f.speculate(pkg, filename, applyEdits(content, edits)) {
+		return false
+	}
+	f.logf("golo: removed the import of %s from itself at %s:%d", importPath, filename, lineOf(content, offset))
+	return true
+}
+
+// fixOwnQualifier fixes uses of the package's own name as a qualifier (so greet.Name becomes
+// Name in package greet), which are left behind when the import of the package was removed
+// from a file in it (usually a test file that was moved from package greet_test into greet).
+// The qualifier is only removed if the name it qualifies is declared in the package, and is
+// not shadowed where it is used.
+func (f *Fixer) fixOwnQualifier(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	name := file.Name.Name
+	if msg != "undefined: "+name || pkg.Types == nil {
+		return false
+	}
+	pos := file.FileStart + token.Pos(offset)
+	scope := pkg.TypesInfo.Scopes[file]
+	edits := []edit{}
+	found := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		id, ok := sel.X.(*ast.Ident)
+		if !ok || id.Name != name || pkg.TypesInfo.Uses[id] != nil {
+			return true
+		}
+		obj := pkg.Types.Scope().Lookup(sel.Sel.Name)
+		if obj == nil {
+			return true
+		}
+		if scope != nil {
+			if _, use := scope.Innermost(sel.Pos()).LookupParent(sel.Sel.Name, sel.Pos()); use != obj {
+				return true
+			}
+		}
+		found = found || id.Pos() == pos
+		edits = append(edits, posEdit(file, sel.Pos(), sel.Sel.Pos(), ""))
+		return false
+	})
+	if !found {
+		return false
+	}
+
+	if !f.speculate(pkg, filename, applyEdits(content, edits)) {
+		return false
+	}
+	f.logf("golo: removed %s. (the package's own name) from %d %s at %s:%d", name, len(edits), plural(len(edits), "use", "uses"), filename, lineOf(content, offset))
+	return true
+}
@@ -0,0 +1,128 @@
+package golo
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixUndefinedFunc fixes calls to undefined functions by adding a stub function that
+// panics to the end of the file, so that only calling it fails (instead of the whole block
+// containing the call). The stub accepts any arguments, and returns as many results as
+// the call sites in the package need.
+func (f *Fixer) fixUndefinedFunc(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) bool {
+	if !strings.HasPrefix(msg, "undefined: ") {
+		return false
+	}
+	name := strings.TrimPrefix(msg, "undefined: ")
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) < 2 {
+		return false
+	}
+	if id, ok := path[0].(*ast.Ident); !ok || id.Name != name {
+		return false
+	}
+	if call, ok := path[1].(*ast.CallExpr); !ok || call.Fun != path[0] {
+		return false
+	}
+
+	// look at every call in the package, so that the stub works for all of them
+	var results []string
+	for _, syntax := range pkg.Syntax {
+		astutil.Apply(syntax, func(c *astutil.Cursor) bool {
+			call, ok := c.Node().(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if id, ok := call.Fun.(*ast.Ident); !ok || id.Name != name || pkg.TypesInfo.Uses[id] != nil {
+				return true
+			}
+			if r := stubResults(pkg, syntax, call, c.Parent()); len(r) > len(results) {
+				results = r
+			} else if len(r) == len(results) {
+				for i := range r {
+					if r[i] != results[i] {
+						results[i] = "any"
+					}
+				}
+			}
+			return true
+		}, nil)
+	}
+
+	var stub string
+	switch len(results) {
+	case 0:
+		stub = fmt.Sprintf("func %s(...any) { panic(%#v) }", name, msg)
+	case 1:
+		stub = fmt.Sprintf("func %s(...any) %s { panic(%#v) }", name, results[0], msg)
+	default:
+		stub = fmt.Sprintf("func %s(...any) (%s) { panic(%#v) }", name, strings.Join(results, ", "), msg)
+	}
+	if f.verbose {
+		f.logf("golo: adding %s", stub)
+	}
+
+	// adding to the end of the file doesn't change any line numbers
+	stub = "\n" + stubMarker + "\n" + stub + "\n"
+	if !bytes.HasSuffix(content, []byte("\n")) {
+		stub = "\n" + stub
+	}
+	return f.update(filename, content, []byte(stub))
+}
+
+// stubMarker is the comment on the line before each function and method that golo adds,
+// so that golo can tell that it is safe to change them if they are written to disk (by golo fix).
+const stubMarker = "//golo:stub"
+
+// isStub returns true if decl was added by golo. (Packages are parsed without comments,
+// so this looks at the line before decl in content.)
+func isStub(file *ast.File, content []byte, decl *ast.FuncDecl) bool {
+	before := bytes.TrimRight(content[:decl.Pos()-file.FileStart], " \t\r\n")
+	return bytes.HasSuffix(before, []byte("\n"+stubMarker))
+}
+
+// stubResults returns the result types needed by a call to a stub, given its parent.
+// Results are "any" unless the context makes the type clear.
+func stubResults(pkg *packages.Package, file *ast.File, call *ast.CallExpr, parent ast.Node) []string {
+	switch p := parent.(type) {
+	case *ast.ExprStmt, *ast.GoStmt, *ast.DeferStmt:
+		return nil
+	case *ast.AssignStmt:
+		if len(p.Rhs) == 1 && len(p.Lhs) > 1 {
+			results := []string{}
+			for range p.Lhs {
+				results = append(results, "any")
+			}
+			return results
+		}
+	case *ast.IfStmt:
+		if p.Cond == call {
+			return []string{"bool"}
+		}
+	case *ast.ForStmt:
+		if p.Cond == call {
+			return []string{"bool"}
+		}
+	case *ast.UnaryExpr:
+		if p.Op == token.NOT {
+			return []string{"bool"}
+		}
+	}
+
+	if t := expectedType(pkg, []ast.Node{call, parent}); t != nil {
+		if _, isTuple := t.(*types.Tuple); !isTuple {
+			if s, ok := typeExpr(pkg, file, t); ok {
+				return []string{s}
+			}
+		}
+	}
+	return []string{"any"}
+}
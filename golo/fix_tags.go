@@ -0,0 +1,250 @@
+package golo
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// tagProblem is a problem with a struct field's tag.
+type tagProblem struct {
+	pos        token.Pos
+	msg        string
+	note       string
+	confidence Confidence
+}
+
+// tagFix is the edit that fixes the problems with one struct field's tag.
+type tagFix struct {
+	edit     edit
+	problems []tagProblem
+}
+
+// fixTags fixes the struct tags in pkg that have the same key more than once (by dropping
+// the later ones, which reflect.StructTag.Get ignores anyway), and the fields of a struct whose
+// JSON names differ only in case (which encoding/json can't tell apart when decoding), by giving
+// the later field its own name with a json tag (e.g. `json:"Id2"`).
+//
+// These are not compile errors, so fixTags only runs if Options.FixTags is set, and only once
+// pkg type-checks. The fixed file is checked again, and nothing is changed unless that
+// finds no more problems.
+func (f *Fixer) fixTags(pkg *packages.Package) bool {
+	if !f.options.FixTags || !f.allows(Preserving) {
+		return false
+	}
+	if f.tagged == nil {
+		f.tagged = map[string]bool{}
+	}
+
+	fixed := false
+	for _, file := range pkg.Syntax {
+		filename := pkg.Fset.Position(file.Pos()).Filename
+		if f.tagged[filename] || f.changed[filename] || !f.sources[filename] {
+			continue
+		}
+		f.tagged[filename] = true
+		content, err := f.readFile(filename)
+		if err != nil || len(content) != pkg.Fset.File(file.Pos()).Size() {
+			continue
+		}
+
+		fixes := tagFixes(file, f.allows(Guessing))
+		if len(fixes) == 0 {
+			continue
+		}
+		edits := make([]edit, len(fixes))
+		for i, fix := range fixes {
+			edits[i] = fix.edit
+		}
+		after := applyEdits(content, edits)
+		if checked, err := parser.ParseFile(token.NewFileSet(), filename, after, 0); err != nil || len(tagFixes(checked, f.allows(Guessing))) > 0 {
+			f.logf("golo: note: the struct tags in %s were left alone, as fixing them didn't fix every problem", filename)
+			continue
+		}
+		for _, fix := range fixes {
+			for _, p := range fix.problems {
+				pos := pkg.Fset.Position(p.pos)
+				f.note = p.note
+				f.report(errors.New(pos.String()+": "+p.msg), pos, p.msg, Rewrite, p.confidence, content)
+			}
+		}
+		f.update(filename, after)
+		f.changed[filename] = true
+		fixed = true
+	}
+	return fixed
+}
+
+// tagFixes returns the fixes for the problems with the struct tags in file. Fields whose JSON
+// names collide are only renamed if rename is set, as that changes how they are encoded.
+func tagFixes(file *ast.File, rename bool) []tagFix {
+	fixes := []tagFix{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if s, ok := n.(*ast.StructType); ok {
+			fixes = append(fixes, structTagFixes(file, s, rename)...)
+		}
+		return true
+	})
+	return fixes
+}
+
+func structTagFixes(file *ast.File, s *ast.StructType, rename bool) []tagFix {
+	fixes := []tagFix{}
+	// the field that uses each JSON name, by the name in lower case
+	names := map[string]string{}
+	for _, field := range s.Fields.List {
+		var pairs []tagPair
+		raw := true
+		if field.Tag != nil {
+			tag, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				continue
+			}
+			// malformed tags are left for vet to explain
+			var ok bool
+			if pairs, ok = parseTag(tag); !ok {
+				continue
+			}
+			raw = strings.HasPrefix(field.Tag.Value, "`")
+		}
+		problems := []tagProblem{}
+
+		seen := map[string]bool{}
+		unique := pairs[:0:0]
+		for _, p := range pairs {
+			if seen[p.key] {
+				problems = append(problems, tagProblem{
+					pos:        field.Tag.Pos(),
+					msg:        fmt.Sprintf("struct field tag %s repeats the %s key", field.Tag.Value, p.key),
+					confidence: Preserving,
+				})
+				continue
+			}
+			seen[p.key] = true
+			unique = append(unique, p)
+		}
+		pairs = unique
+
+		// embedded fields (and fields declared together, which share a tag) are left alone
+		if len(field.Names) == 1 && field.Names[0].IsExported() {
+			fieldName := field.Names[0].Name
+			name, options := fieldName, ""
+			i := pairIndex(pairs, "json")
+			if i >= 0 {
+				name, options, _ = strings.Cut(pairs[i].value, ",")
+				if name == "" {
+					name = fieldName
+				}
+			}
+			if other := names[strings.ToLower(name)]; other != "" && name != "-" && rename {
+				renamed := name
+				for n := 2; names[strings.ToLower(renamed)] != ""; n++ {
+					renamed = name + strconv.Itoa(n)
+				}
+				value := renamed
+				if options != "" {
+					value += "," + options
+				}
+				p := tagPair{key: "json", value: value, raw: "json:" + strconv.Quote(value)}
+				if i >= 0 {
+					pairs[i] = p
+				} else {
+					pairs = append(pairs, p)
+				}
+				problems = append(problems, tagProblem{
+					pos:        field.Pos(),
+					msg:        fmt.Sprintf("struct field %s has JSON name %q, which differs only in case from %s's", fieldName, name, other),
+					note:       fmt.Sprintf("%s is encoded as %q in JSON", fieldName, renamed),
+					confidence: Guessing,
+				})
+				name = renamed
+			}
+			if name != "-" {
+				names[strings.ToLower(name)] = fieldName
+			}
+		}
+
+		if len(problems) == 0 {
+			continue
+		}
+		tag := quoteTag(joinTag(pairs), raw)
+		e := posEdit(file, field.Type.End(), field.Type.End(), " "+tag)
+		if field.Tag != nil {
+			e = nodeEdit(file, field.Tag, tag)
+		}
+		fixes = append(fixes, tagFix{edit: e, problems: problems})
+	}
+	return fixes
+}
+
+// tagPair is one key:"value" pair in a struct tag, with the text it was written as.
+type tagPair struct {
+	key, value, raw string
+}
+
+// parseTag splits tag into its key:"value" pairs, as reflect.StructTag.Lookup reads them.
+// It returns false if tag is not in the conventional format.
+func parseTag(tag string) ([]tagPair, bool) {
+	pairs := []tagPair{}
+	for {
+		tag = strings.TrimLeft(tag, " ")
+		if tag == "" {
+			return pairs, true
+		}
+		i := 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return nil, false
+		}
+		key := tag[:i]
+		j := i + 2
+		for j < len(tag) && tag[j] != '"' {
+			if tag[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(tag) {
+			return nil, false
+		}
+		value, err := strconv.Unquote(tag[i+1 : j+1])
+		if err != nil {
+			return nil, false
+		}
+		pairs = append(pairs, tagPair{key: key, value: value, raw: tag[:j+1]})
+		tag = tag[j+1:]
+	}
+}
+
+func pairIndex(pairs []tagPair, key string) int {
+	for i, p := range pairs {
+		if p.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func joinTag(pairs []tagPair) string {
+	raw := make([]string, len(pairs))
+	for i, p := range pairs {
+		raw[i] = p.raw
+	}
+	return strings.Join(raw, " ")
+}
+
+// quoteTag returns the literal for tag: a raw string if the tag was one (and still can be).
+func quoteTag(tag string, raw bool) string {
+	if raw && !strings.Contains(tag, "`") {
+		return "`" + tag + "`"
+	}
+	return strconv.Quote(tag)
+}
@@ -0,0 +1,122 @@
+package golo
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fixTestHelper handles references from non-test files to helpers that are declared in the
+// package's _test.go files (which compile fine under go test, but not in any other build).
+// Deferring these would only move the problem somewhere less obvious, so unless
+// Options.MoveTestHelpers is set (in which case the declaration is copied into the file that
+// uses it) the error is left for the user to fix, with a note saying where the helper is.
+// It returns false if the error is not of this kind.
+func (f *Fixer) fixTestHelper(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) (FixKind, bool) {
+	if !strings.HasPrefix(msg, "undefined: ") || strings.HasSuffix(filename, "_test.go") || strings.HasSuffix(pkg.Types.Name(), "_test") {
+		return "", false
+	}
+	name := strings.TrimPrefix(msg, "undefined: ")
+	pos := file.FileStart + token.Pos(offset)
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	if len(path) < 2 {
+		return "", false
+	}
+	if id, ok := path[0].(*ast.Ident); !ok || id.Name != name {
+		return "", false
+	}
+	if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == path[0] {
+		return "", false
+	}
+
+	testFile, helper := f.findTestHelper(filepath.Dir(filename), pkg.Types.Name(), name)
+	if testFile == "" {
+		return "", false
+	}
+	// go test builds the package with its test files, so there is nothing to fix.
+	if f.mode == "test" {
+		return "", true
+	}
+	line := lineOf(content, offset)
+
+	if f.options.MoveTestHelpers && f.allows(Preserving) {
+		// adding to the end of the file doesn't change any line numbers
+		code := "\n" + helper + "\n"
+		if !bytes.HasSuffix(content, []byte("\n")) {
+			code = "\n" + code
+		}
+		f.logf("golo: note: copied %s from %s to %s", name, filepath.Base(testFile), filepath.Base(filename))
+		f.update(filename, content, []byte(code))
+		return Moved, true
+	}
+
+	f.warnOnce(testFile+":"+name, "golo: %s:%d: %s is defined in %s; move it to a non-test file (or use -move-test-helpers)", filename, line, name, filepath.Base(testFile))
+	return "", true
+}
+
+// findTestHelper returns the _test.go file of package pkgName in dir that declares name,
+// and the source of that declaration.
+func (f *Fixer) findTestHelper(dir string, pkgName string, name string) (string, string) {
+	ctx := build.Default
+	ctx.OpenFile = func(filename string) (io.ReadCloser, error) {
+		content, err := f.readFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+	bp, err := ctx.ImportDir(dir, 0)
+	if err != nil || bp.Name != pkgName {
+		return "", ""
+	}
+
+	for _, testName := range bp.TestGoFiles {
+		testFile := filepath.Join(dir, testName)
+		content, err := f.readFile(testFile)
+		if err != nil {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), testFile, content, 0)
+		if err != nil {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if declares(decl, name) {
+				return testFile, string(content[decl.Pos()-file.FileStart : decl.End()-file.FileStart])
+			}
+		}
+	}
+	return "", ""
+}
+
+// declares returns true if decl is a top-level declaration of name.
+func declares(decl ast.Decl, name string) bool {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		return decl.Recv == nil && decl.Name.Name == name
+	case *ast.GenDecl:
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				if spec.Name.Name == name {
+					return true
+				}
+			case *ast.ValueSpec:
+				for _, n := range spec.Names {
+					if n.Name == name {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
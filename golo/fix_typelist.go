@@ -0,0 +1,136 @@
+package golo
+
+import (
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// typeListElem is one type in a type list, and the offsets of its first and last tokens.
+type typeListElem struct {
+	start, end int
+	toks       []token.Token
+	lit        string
+}
+
+// fixTypeList fixes a constraint written with a type list from the generics design draft
+// (interface{ type int, string }), which go1.18 replaced with unions, by rewriting the list as
+// a union. A type list matched any type whose underlying type was in the list, so the types
+// that can be are written with a ~ (~int | ~string). Named types can't, so they only match
+// themselves, which is the closest a union gets (and if their underlying type is in the list
+// too, the union has overlapping terms, which is left for the type checker to report).
+func (f *Fixer) fixTypeList(filename string, content []byte, offset int, msg string) bool {
+	if msg != "expected '}', found 'type'" {
+		return false
+	}
+	s := scanner.Scanner{}
+	s.Init(token.NewFileSet().AddFile(filename, -1, len(content)), content, nil, 0)
+
+	// the type must be directly inside an interface's braces
+	interfaces := []bool{}
+	last := token.ILLEGAL
+	for {
+		pos, tok, _ := s.Scan()
+		if tok == token.EOF || int(pos)-1 > offset {
+			return false
+		}
+		if int(pos)-1 == offset {
+			if tok != token.TYPE || len(interfaces) == 0 || !interfaces[len(interfaces)-1] {
+				return false
+			}
+			break
+		}
+		switch tok {
+		case token.LBRACE:
+			interfaces = append(interfaces, last == token.INTERFACE)
+		case token.RBRACE:
+			if len(interfaces) > 0 {
+				interfaces = interfaces[:len(interfaces)-1]
+			}
+		}
+		last = tok
+	}
+
+	// the types are separated by commas outside of any brackets, and the list ends at the end
+	// of the line (or of the interface)
+	elems := []typeListElem{{start: -1}}
+	commas := []int{}
+	depth := 0
+	for {
+		pos, tok, lit := s.Scan()
+		off := int(pos) - 1
+		if tok == token.EOF || tok == token.ILLEGAL || depth < 0 {
+			return false
+		}
+		if depth == 0 && (tok == token.SEMICOLON || tok == token.RBRACE) {
+			break
+		}
+		switch tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			depth--
+		}
+		if depth == 0 && tok == token.COMMA {
+			commas = append(commas, off)
+			elems = append(elems, typeListElem{start: -1})
+			continue
+		}
+		e := &elems[len(elems)-1]
+		if e.start == -1 {
+			e.start, e.lit = off, lit
+		}
+		e.toks = append(e.toks, tok)
+		e.end = off + len(tok.String())
+		if lit != "" {
+			e.end = off + len(lit)
+		}
+	}
+
+	edits := []edit{}
+	union := []string{}
+	for _, e := range elems {
+		if e.start == -1 {
+			return false
+		}
+		elem := string(content[e.start:e.end])
+		if underlyingOnly(e) {
+			edits = append(edits, edit{e.start, e.start, "~"})
+			elem = "~" + elem
+		}
+		union = append(union, elem)
+	}
+	if _, err := parser.ParseExpr("interface{ " + strings.Join(union, " | ") + " }"); err != nil {
+		return false
+	}
+	edits = append(edits, edit{offset, elems[0].start, ""})
+	for _, c := range commas {
+		edits = append(edits, edit{c, c + 1, " |"})
+	}
+
+	f.logf("golo: note: changed type list to %s at %s:%d", strings.Join(union, " | "), filename, lineOf(content, offset))
+	return f.update(filename, applyEdits(content, edits))
+}
+
+// underlyingOnly returns true if the type in a type list is its own underlying type (so it
+// can be written with a ~ in a union): a predeclared type (other than error and any), or a
+// type literal.
+func underlyingOnly(e typeListElem) bool {
+	switch e.toks[0] {
+	case token.LBRACK, token.MAP, token.CHAN, token.ARROW, token.FUNC, token.STRUCT, token.MUL:
+		return true
+	case token.IDENT:
+		if len(e.toks) != 1 {
+			return false
+		}
+		obj, ok := types.Universe.Lookup(e.lit).(*types.TypeName)
+		if !ok {
+			return false
+		}
+		_, basic := obj.Type().(*types.Basic)
+		return basic
+	}
+	return false
+}
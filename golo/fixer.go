@@ -2,16 +2,22 @@ package golo
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/scanner"
 	"go/token"
+	"go/types"
+	"io"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
+	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/packages"
@@ -20,71 +26,387 @@ import (
 // Fixer attempts to populate Fixed (a map from filename to updated content) by repeatingly
 // loading the packages given and altering the sourcecode.
 // Fixed can be passed to the go compiler using the `-overlay` flags.
+// Use NewFixer to create one.
 type Fixer struct {
 	mode    string
 	verbose bool
-	Fixed   map[string][]byte
+	// Fixed is the supported way to get the fixed files: it maps each file that was fixed
+	// to its new content. Files already in Fixed are read from there instead of from disk.
+	Fixed map[string][]byte
+
+	// Applied lists the errors that were fixed, in the order they were fixed.
+	Applied []Fix
+
+	options Options
+	config  *packages.Config
+	out     io.Writer
+
+	// build is the configuration of the build being fixed (if not set, packages are loaded
+	// as go list would load them), so that the files loaded are those being built.
+	build *buildConfig
+	// ctx is the context passed to FixContext (see context).
+	ctx context.Context
+
+	// sources contains every file that has been loaded while fixing.
+	sources map[string]bool
+	// originals, if set, maps each source file to the sha256 of its content when it was
+	// first loaded (so that fix mode can check that it hasn't changed before overwriting it).
+	originals map[string]string
+	// checkpoint, if set, is called after each iteration that fixed something.
+	checkpoint func() error
+	// onFix, if set, is called with each fix as it is made.
+	onFix func(Fix)
+	// generated contains the generators that have been run (see fixGenerated).
+	generated map[generator]bool
+	// warned contains the keys of the warnings that have been logged by warnOnce.
+	warned map[string]bool
+	// unfixed contains the errors that could not be fixed in the last iteration.
+	unfixed []packages.Error
+	// changed contains the files fixed in this iteration. A file can be in more than one
+	// package (e.g. a package and the same package compiled with its tests), and once it is
+	// fixed the positions of errors in it from other packages are out of date.
+	changed map[string]bool
+	// note is added to the next Fix that is reported, by fixes that change what the code does.
+	note string
+	// where is the position of the error being fixed, e.g. "main.go:42: " (see panicMsg).
+	where string
+	// mains contains the packages that golo run was asked to run, by import path, if they
+	// aren't main packages (see fixMain).
+	mains map[string]bool
+	// formatted contains the files that fixFormats has checked.
+	formatted map[string]bool
+	// tagged contains the files that fixTags has checked.
+	tagged map[string]bool
+	// closures contains the files that fixLoopClosures has fixed.
+	closures map[string]bool
+	// attempted contains the errors (as "file:line:col: msg") that have been fixed, so that if
+	// one comes back, golo says it can't make progress instead of fixing it again.
+	attempted map[string]bool
+	// limited is set if a fix loop stopped because it reached Options.MaxFixes.
+	limited bool
+
+	// sandbox checks where files are written.
+	sandbox *sandbox
+	// spillDir, if set, is where fixed files are moved to save memory (see spill).
+	spillDir string
+	spilled  map[string]spilledFile
+
+	// mu is held while parseFile uses f, as go/packages calls it concurrently.
+	mu sync.Mutex
+
+	// workers is the number of packages to fix at once (GOMAXPROCS if zero).
+	workers int
+	// parent is the Fixer that created this one to fix a package concurrently (see fixPkgs).
+	parent *Fixer
+	// touched, if set, records the files that this Fixer read or changed.
+	touched map[string]bool
+	// rerun is set by a child Fixer if its package must be fixed again by its parent.
+	rerun bool
+
+	stdOnce sync.Once
+	std     map[string][]string
+
+	goCacheOnce sync.Once
+	goCacheDir  string
+	goCacheErr  error
+
+	goVersionOnce sync.Once
+	goVersionName string
+}
+
+// Fix describes an error that the Fixer worked around.
+type Fix struct {
+	Pos  token.Position
+	Msg  string
+	Kind FixKind
+	// Confidence is how sure golo is that the fix does what was meant.
+	Confidence Confidence `json:",omitempty"`
+
+	// Note explains how the fix changes what the code does, if it does.
+	Note string `json:",omitempty"`
+
+	// Policy is the policy of the package the error was in, if Options.Strict was set
+	// (so it is always Lenient, as errors in strict packages aren't fixed).
+	Policy Policy `json:",omitempty"`
+
+	// DependsOn lists the deferrals that made this fix necessary, for fixes that would not
+	// have been needed if golo hadn't deferred some code (see dependencies).
+	DependsOn []token.Position `json:",omitempty"`
+
+	// Replaced is the range of bytes that the fix replaced in the file that the error was in
+	// (as golo read it, so it includes earlier fixes). It is nil if that file was not changed,
+	// for example when a missing method was added to another file.
+	Replaced *Span `json:",omitempty"`
 }
 
+// Span is a range of bytes in a file.
+type Span struct {
+	Start, End int
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("[%d:%d]", s.Start, s.End)
+}
+
+// FixKind describes how an error was fixed.
+type FixKind string
+
+const (
+	// Deferred fixes replace the broken code with a panic.
+	Deferred FixKind = "deferred"
+	// UnusedImport fixes rename an unused import to _.
+	UnusedImport FixKind = "unused-import"
+	// UnusedVar fixes rename an unused variable to _.
+	UnusedVar FixKind = "unused-var"
+	// Checked fixes panic if the unused result of a check (like err := validate(x)) says it failed.
+	Checked FixKind = "checked"
+	// UselessAssignment fixes replace := with = when there are no new variables.
+	UselessAssignment FixKind = "useless-assignment"
+	// Conversion fixes insert a conversion between types with the same underlying type.
+	Conversion FixKind = "conversion"
+	// Rewrite fixes replace code that does not compile with code that does something similar.
+	Rewrite FixKind = "rewrite"
+	// Renamed fixes replace an undefined name with a similar one that is defined.
+	Renamed FixKind = "renamed"
+	// Declared fixes declare an undefined variable.
+	Declared FixKind = "declared"
+	// Imported fixes add an import for a package that is used but not imported.
+	Imported FixKind = "imported"
+	// Reordered fixes swap the arguments to a call into the only order that type-checks.
+	Reordered FixKind = "reordered"
+
+	// Moved fixes copy a declaration that is only in the package's _test.go files into the file that uses it.
+	Moved FixKind = "moved"
+
+	// Regenerated fixes run the go:generate directive that generated the broken file.
+	Regenerated FixKind = "regenerated"
+
+	// postponed is returned by fixError for errors that can't be fixed until the next
+	// iteration, because the fix would change a file that has already been changed.
+	postponed FixKind = "postponed"
+)
+
+// NewFixer returns a Fixer for the given mode ("build", "run" or "test", which also fixes
+// the packages' tests). Fixes are added to fixed (which may be nil), so a map returned by
+// an earlier Fixer can be passed in to continue from where it left off.
+// If verbose, the Fixer logs more about what it is doing.
 func NewFixer(mode string, verbose bool, fixed map[string][]byte) *Fixer {
 	f := &Fixer{
 		mode:    mode,
 		verbose: verbose,
 		Fixed:   fixed,
+		out:     os.Stdout,
+		mains:   map[string]bool{},
 	}
+	f.options.MaxFixes = DefaultMaxFixes
 	if fixed == nil {
 		f.Fixed = map[string][]byte{}
 	}
+	return f
 }
 
 // Fix attempts to fix the go packages given.
 // It updates f.Fixed
 func (f *Fixer) Fix(pkgNames ...string) error {
-	for i := 0; i < 10; i++ {
-		config := &packages.Config{
-			Mode:      packages.NeedTypes | packages.NeedSyntax,
-			ParseFile: f.parseFile,
-			Overlay:   f.Fixed,
+	return f.FixContext(context.Background(), pkgNames...)
+}
+
+// FixContext is like Fix, but stops loading packages (and running go commands) once ctx is done,
+// and returns ctx.Err().
+func (f *Fixer) FixContext(ctx context.Context, pkgNames ...string) error {
+	f.ctx = ctx
+	states := map[[sha256.Size]byte]bool{}
+	for i := 0; ; i++ {
+		if max := f.options.MaxFixes; max > 0 && i >= max {
+			f.limited = true
+			f.logf("golo: stopped after reloading the packages %d times (-max-fixes raises the limit)", max)
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
 		}
-		if f.mode == "test" {
-			config.Tests = true
+		config, err := f.loadConfig()
+		if err != nil {
+			return err
 		}
+		applied := len(f.Applied)
 		pkgs, err := packages.Load(config, pkgNames...)
 
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err != nil {
 			return fmt.Errorf("packages.Load failed: %w", err)
 		}
+		if err := f.checkStrict(pkgs, applied); err != nil {
+			return err
+		}
 
 		fixed := false
+		f.unfixed = nil
+		f.changed = map[string]bool{}
+		seen := map[packages.Error]bool{}
+		results := f.fixPkgs(pkgs)
 
-		for _, pkg := range pkgs {
-			if f, err := f.fixPkg(pkg); err != nil {
+		for i, pkg := range pkgs {
+			f.addSources(pkg)
+			if f.fixPackageClash(pkg) {
+				fixed = true
+				continue
+			}
+			if f.fixMain(pkg) {
+				fixed = true
+				continue
+			}
+			if ok, err := f.mergePkg(pkg, results[i]); err != nil {
 				return err
-			} else if f {
+			} else if ok {
 				fixed = true
+			} else if len(pkg.Errors) == 0 {
+				if f.fixFormats(pkg) {
+					fixed = true
+				}
+				if f.fixTags(pkg) {
+					fixed = true
+				}
+				if f.fixLoopClosures(pkg) {
+					fixed = true
+				}
+				freeSyntax(pkg)
+			} else {
+				for _, e := range pkg.Errors {
+					if !seen[e] {
+						seen[e] = true
+						f.unfixed = append(f.unfixed, e)
+					}
+				}
 			}
 		}
+		if err := f.spill(); err != nil {
+			return err
+		}
 		if !fixed {
 			return nil
 		}
+		// fixes that undo each other would otherwise go round forever
+		state := f.state()
+		if states[state] {
+			for _, fix := range f.Applied[applied:] {
+				f.logf("golo: unable to make progress on %s: %s", fix.Pos, fix.Msg)
+			}
+			return nil
+		}
+		states[state] = true
+		if f.checkpoint != nil {
+			if err := f.checkpoint(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// state returns a hash of every fixed file, so that FixContext can tell if an iteration
+// left them as an earlier one did.
+func (f *Fixer) state() [sha256.Size]byte {
+	h := sha256.New()
+	files := maps.Keys(f.Fixed)
+	slices.Sort(files)
+	for _, filename := range files {
+		sum := sha256.Sum256(f.Fixed[filename])
+		fmt.Fprintf(h, "%s\x00%x\n", filename, sum)
+	}
+	spilled := maps.Keys(f.spilled)
+	slices.Sort(spilled)
+	for _, filename := range spilled {
+		fmt.Fprintf(h, "%s\x00%x\n", filename, f.spilled[filename].Hash)
+	}
+	var state [sha256.Size]byte
+	h.Sum(state[:0])
+	return state
+}
+
+func (f *Fixer) addSources(pkg *packages.Package) {
+	if f.sources == nil {
+		f.sources = map[string]bool{}
+	}
+	for _, file := range pkg.GoFiles {
+		if f.originals != nil && !f.sources[file] {
+			if hash, err := hashFile(file); err == nil {
+				f.originals[file] = hash
+			}
+		}
+		f.sources[file] = true
 	}
-	return nil
 }
 
+func (f *Fixer) loadConfig() (*packages.Config, error) {
+	config := &packages.Config{}
+	if f.config != nil {
+		*config = *f.config
+	}
+	config.Mode |= packages.NeedFiles | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo
+	// fixLoopClosures needs the module's go version
+	if f.options.FixLoopClosure {
+		config.Mode |= packages.NeedModule
+	}
+	if config.Env == nil && f.build != nil {
+		config.Env = f.build.env
+	} else if config.Env == nil {
+		config.Env = goEnv(f.options)
+	}
+	if f.build != nil {
+		// go list takes the same build flags, except -o (and -C becomes the directory)
+		flags, dir := loadFlags(f.build.flags)
+		config.BuildFlags = append(config.BuildFlags[:len(config.BuildFlags):len(config.BuildFlags)], flags...)
+		if dir != "" && config.Dir == "" {
+			config.Dir = dir
+		}
+	}
+	if f.ctx != nil {
+		config.Context = f.ctx
+	}
+	config.ParseFile = f.parseFile
+	// parseFile updates f.Fixed while go/packages is still reading the overlay
+	config.Overlay = maps.Clone(f.Fixed)
+	if f.mode == "test" {
+		config.Tests = true
+	}
+	if len(f.spilled) > 0 {
+		if err := f.spillOverlay(config); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// fixPkg fixes the type errors in pkg. Only the first error in each file is fixed, as
+// fixing it changes the positions of the others (so they are fixed in the next iteration).
 func (f *Fixer) fixPkg(pkg *packages.Package) (bool, error) {
-	if len(pkg.TypeErrors) == 0 {
-		return false, nil
+	fixed := false
+	for _, e := range pkg.TypeErrors {
+		ok, err := f.fixTypeError(pkg, e)
+		if err != nil {
+			return fixed, err
+		}
+		fixed = fixed || ok
 	}
+	return fixed, nil
+}
 
-	// TODO: handle more than one error per iteration (easy for separate files...)
-	e := pkg.TypeErrors[0]
+// fixTypeError fixes e, unless the file it is in has already been changed in this iteration.
+func (f *Fixer) fixTypeError(pkg *packages.Package, e types.Error) (bool, error) {
 	fi := e.Fset.File(e.Pos)
-	position := fi.PositionFor(e.Pos, false)
+	position, isCgo, err := f.cgoPosition(fi, e.Pos)
+	if err != nil {
+		return false, err
+	}
+	if f.changed[position.Filename] {
+		return true, nil
+	}
 
 	offset := position.Offset
 	var file *ast.File
 	var content []byte
-	var err error
 
 	for _, ast := range pkg.Syntax {
 		if ast.Pos() <= e.Pos && ast.End() >= e.Pos {
@@ -92,9 +414,7 @@ func (f *Fixer) fixPkg(pkg *packages.Package) (bool, error) {
 		}
 	}
 
-	// This happens for CGO builds
-	if strings.HasPrefix(position.Filename, goCache()) {
-		position = fi.PositionFor(e.Pos, true)
+	if isCgo {
 		content, err = f.readFile(position.Filename)
 		if err != nil {
 			return false, err
@@ -126,98 +446,424 @@ func (f *Fixer) fixPkg(pkg *packages.Package) (bool, error) {
 		}
 	}
 
-	if f.fixError(file, position.Filename, content, offset, e.Msg) {
-		fmt.Println("golo: " + strings.ReplaceAll(e.Error(), "\n", "\ngolo: "))
+	if !f.attempt(position, e.Msg) {
+		return false, nil
+	}
+	if kind, confidence := f.fixError(pkg, file, position.Filename, content, offset, e.Msg); kind == postponed {
+		return false, nil
+	} else if kind != "" {
+		f.changed[position.Filename] = true
+		f.attempted[position.String()+": "+e.Msg] = true
+		f.report(e, position, e.Msg, kind, confidence, content)
 		return true, nil
 	}
+	f.note = ""
 
 	return false, nil
 }
 
+// attempt returns false if the error at pos has already been fixed, which means the fix
+// didn't work (the same error came back), so fixing it again won't either.
+func (f *Fixer) attempt(pos token.Position, msg string) bool {
+	if f.attempted == nil {
+		f.attempted = map[string]bool{}
+	}
+	key := pos.String() + ": " + msg
+	if f.attempted[key] {
+		f.warnOnce("progress:"+key, "golo: unable to make progress on %s", key)
+		return false
+	}
+	return true
+}
+
 func (f *Fixer) readFile(filename string) ([]byte, error) {
+	if f.touched != nil {
+		f.touched[filename] = true
+	}
 	if ret, ok := f.Fixed[filename]; ok {
 		return ret, nil
 	}
-	return os.ReadFile(filename)
+	if ret, ok, err := f.readSpilled(filename); ok {
+		return ret, err
+	}
+	content, err := os.ReadFile(filename)
+	return stripBOM(content), err
 }
 
+// parseFile parses a file for go/packages, fixing any syntax errors in it.
+// go/packages parses files concurrently, so f.mu is held while f is used.
 func (f *Fixer) parseFile(fset *token.FileSet, filename string, content []byte) (*ast.File, error) {
-	// bail after 10 times around to avoid infinite looping if we're not helping
-	i := 0
-	for {
-		i++
+	// once files are spilled, go/packages reads the original files from disk (see spillOverlay)
+	f.mu.Lock()
+	if len(f.spilled) > 0 {
+		if fixed, ok := f.Fixed[filename]; ok {
+			content = fixed
+		} else if spilled, ok, err := f.readSpilled(filename); ok {
+			if err != nil {
+				f.mu.Unlock()
+				return nil, err
+			}
+			content = spilled
+		}
+	}
+	f.mu.Unlock()
+	if err := checkEncoding(filename, content); err != nil {
+		return nil, err
+	}
+	content = stripBOM(content)
+
+	// bail after Options.MaxFixes times around (or if a fix undoes an earlier one) to avoid
+	// infinite looping if we're not helping
+	seen := map[[sha256.Size]byte]bool{}
+	for fixes := 0; ; fixes++ {
 		file, err := parser.ParseFile(fset, filename, content, 0)
 		if err == nil {
 			return file, nil
 		}
 
 		errs, ok := err.(scanner.ErrorList)
-		if i >= 10 || !ok || len(errs) == 0 {
+		if !ok || len(errs) == 0 {
+			return file, err
+		}
+		if max := f.options.MaxFixes; max > 0 && fixes >= max {
+			f.mu.Lock()
+			f.limited = true
+			f.warnOnce("max-fixes:"+filename, "golo: stopped fixing %s after %d syntax errors (-max-fixes raises the limit)", filename, max)
+			f.mu.Unlock()
 			return file, err
 		}
+		sum := sha256.Sum256(content)
+		if seen[sum] {
+			return file, err
+		}
+		seen[sum] = true
 
 		e := errs[0]
 
-		fixed := f.fixError(file, filename, content, e.Pos.Offset, e.Msg)
-		if !fixed {
+		f.mu.Lock()
+		if !f.attempt(e.Pos, e.Msg) {
+			f.mu.Unlock()
+			return file, err
+		}
+		kind, confidence := f.fixError(nil, file, filename, content, e.Pos.Offset, e.Msg)
+		if kind == "" {
+			f.mu.Unlock()
 			return file, err
 		}
+		f.attempted[e.Pos.String()+": "+e.Msg] = true
+		before := content
 		content = f.Fixed[filename]
-		fmt.Println("golo: " + strings.ReplaceAll(e.Error(), "\n", "\ngolo: "))
+		f.report(e, e.Pos, e.Msg, kind, confidence, before)
+		f.mu.Unlock()
+	}
+}
+
+// report logs the error that was just fixed, and records it in f.Applied.
+// before is the content of the file the error was in before it was fixed (if known).
+func (f *Fixer) report(e error, pos token.Position, msg string, kind FixKind, confidence Confidence, before []byte) {
+	f.logf("golo: %s", strings.ReplaceAll(e.Error(), "\n", "\ngolo: "))
+	fix := Fix{Pos: pos, Msg: msg, Kind: kind, Confidence: confidence, Note: f.note}
+	f.note = ""
+	if len(f.options.Strict) > 0 {
+		fix.Policy = Lenient
+	}
+	if kind == UnusedImport || kind == UnusedVar {
+		fix.DependsOn = f.dependencies(pos.Filename, msg)
+	}
+	if after, ok := f.Fixed[pos.Filename]; ok && before != nil {
+		fix.Replaced = replacedSpan(before, after)
+	}
+	f.Applied = append(f.Applied, fix)
+	if f.onFix != nil && f.parent == nil {
+		f.onFix(fix)
+	}
+}
+
+// replay logs and records fixes that were made by an earlier run, as report does.
+func (f *Fixer) replay(fixes []Fix) {
+	for _, fix := range fixes {
+		f.logf("golo: %s: %s", fix.Pos, strings.ReplaceAll(fix.Msg, "\n", "\ngolo: "))
+		f.Applied = append(f.Applied, fix)
+		if f.onFix != nil {
+			f.onFix(fix)
+		}
 	}
 }
 
+func (f *Fixer) logf(format string, args ...any) {
+	out := f.out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format+"\n", args...)
+}
+
+// warnOnce logs a warning the first time it is called with key.
+func (f *Fixer) warnOnce(key string, format string, args ...any) {
+	if f.warned == nil {
+		f.warned = map[string]bool{}
+	}
+	if !f.warned[key] {
+		f.warned[key] = true
+		f.logf(format, args...)
+	}
+}
+
+// replacedSpan returns the range of bytes in before that were replaced to make after.
+func replacedSpan(before, after []byte) *Span {
+	if bytes.Equal(before, after) {
+		return nil
+	}
+	start := 0
+	for start < len(before) && start < len(after) && before[start] == after[start] {
+		start++
+	}
+	end := len(before)
+	for end > start && len(after)-(len(before)-end) > start && before[end-1] == after[len(after)-(len(before)-end)-1] {
+		end--
+	}
+	return &Span{Start: start, End: end}
+}
+
 func newLinesInRange(s []byte) string {
+	// ranges can be most of a large file, so only the line breaks are looked at
 	n := []byte{}
-	for _, b := range s {
-		if b == '\n' || b == '\r' {
-			n = append(n, b)
-		}
+	for i := bytes.IndexAny(s, "\r\n"); i >= 0; i = bytes.IndexAny(s, "\r\n") {
+		n = append(n, s[i])
+		s = s[i+1:]
 	}
 	return string(n)
 }
 
-func (f *Fixer) fixError(file *ast.File, filename string, content []byte, offset int, msg string) bool {
+// fixError updates f.Fixed so that the error at offset is no longer present,
+// and returns how it did so (or "" if it could not), and how confident it is in the fix.
+// pkg is nil for syntax errors, which are fixed before type information is available.
+//
+// Strategies that are less confident than Options.MinConfidence are skipped, so that
+// the error is deferred instead.
+func (f *Fixer) fixError(pkg *packages.Package, file *ast.File, filename string, content []byte, offset int, msg string) (FixKind, Confidence) {
+	f.where = fmt.Sprintf("%s:%d: ", filepath.Base(filename), lineOf(content, offset))
+
 	// We handle these cases specially because they can be caused by other changes that we made.
 	// (also, yolo)
 	if strings.Contains(msg, "imported and not used") {
-		return f.fixUnusedImport(file, filename, content, offset)
+		if f.fixUnusedImport(file, filename, content, offset) {
+			return UnusedImport, Safe
+		}
+		return "", 0
 	}
 	if strings.Contains(msg, "declared and not used") {
-		return f.fixUnusedVar(file, filename, content, offset)
+		if f.fixDiscardedCheck(pkg, file, filename, content, offset) {
+			return Checked, Guessing
+		}
+		if f.fixUnusedVar(file, filename, content, offset) {
+			return UnusedVar, Safe
+		}
+		return "", 0
 	}
 	if strings.Contains(msg, "no new variables on left side of :=") {
-		return f.fixUselessAssignment(file, filename, content, offset)
+		if f.fixUselessAssignment(file, filename, content, offset) {
+			return UselessAssignment, Safe
+		}
+		return "", 0
+	}
+
+	if pkg == nil && f.allows(Preserving) && f.fixLookalikes(filename, content, offset, msg) {
+		return Rewrite, Preserving
+	}
+	if pkg == nil && f.allows(Guessing) && f.fixAssignCondition(file, filename, content, offset, msg) {
+		return Rewrite, Guessing
+	}
+	if pkg == nil && f.allows(Preserving) && f.fixMissingChanType(file, filename, content, offset) {
+		return Rewrite, Preserving
+	}
+	if pkg == nil && f.allows(Preserving) && f.fixTypeList(filename, content, offset, msg) {
+		return Rewrite, Preserving
+	}
+
+	// These cases can be fixed, or deferred more narrowly than the rest of the block.
+	if pkg != nil && pkg.TypesInfo != nil {
+		if kind := f.fixGenerated(pkg, filename, content); kind != "" {
+			return kind, Preserving
+		}
+		if f.allows(Preserving) && f.fixSelfImport(pkg, file, filename, content, offset, msg) {
+			return Rewrite, Preserving
+		}
+		if f.allows(Preserving) && f.fixOwnQualifier(pkg, file, filename, content, offset, msg) {
+			return Rewrite, Preserving
+		}
+		if f.allows(Guessing) && f.fixMapKey(pkg, file, filename, content, offset, msg) {
+			return Rewrite, Guessing
+		}
+		if f.fixIndex(pkg, file, filename, content, offset, msg) {
+			return Deferred, Safe
+		}
+		if f.allows(Guessing) && f.fixChanSize(pkg, file, filename, content, offset) {
+			return Rewrite, Guessing
+		}
+		if f.allows(Guessing) && f.fixChanType(pkg, file, filename, content, offset, msg) {
+			return Rewrite, Guessing
+		}
+		if kind := f.fixNil(pkg, file, filename, content, offset, msg); kind != "" {
+			return kind, confidenceOf(kind, Preserving)
+		}
+		if kind, confidence := f.fixAddress(pkg, file, filename, content, offset, msg); kind != "" {
+			return kind, confidence
+		}
+		if f.allows(Preserving) && f.fixPointerReceiver(pkg, file, filename, content, offset, msg) {
+			return Rewrite, Preserving
+		}
+		if kind := f.fixMissingArguments(pkg, file, filename, content, offset, msg); kind != "" {
+			return kind, confidenceOf(kind, Guessing)
+		}
+		if kind := f.fixExtraArguments(pkg, file, filename, content, offset, msg); kind != "" {
+			return kind, confidenceOf(kind, Guessing)
+		}
+		if f.allows(Guessing) && f.fixArgumentOrder(pkg, file, filename, content, offset, msg) {
+			return Reordered, Guessing
+		}
+		if kind, confidence := f.fixDuration(pkg, file, filename, content, offset, msg); kind != "" {
+			return kind, confidence
+		}
+		if kind := f.fixMismatch(pkg, file, filename, content, offset, msg); kind != "" {
+			return kind, confidenceOf(kind, Preserving)
+		}
+		if kind := f.fixPromotedField(pkg, file, filename, content, offset, msg); kind != "" {
+			return kind, confidenceOf(kind, Preserving)
+		}
+		if kind := f.fixMissingMethod(pkg, file, filename, content, offset, msg); kind != "" {
+			return kind, Safe
+		}
+		if kind := f.fixWrongMethod(pkg, file, filename, content, offset, msg); kind != "" {
+			return kind, Safe
+		}
+		if kind := f.fixAssignMismatch(pkg, file, filename, content, offset, msg); kind != "" {
+			return kind, confidenceOf(kind, Guessing)
+		}
+		if kind, ok := f.fixTestHelper(pkg, file, filename, content, offset, msg); ok {
+			return kind, confidenceOf(kind, Preserving)
+		}
+		if f.allows(Preserving) && f.fixUnexported(pkg, file, filename, content, offset, msg) {
+			return Rewrite, Preserving
+		}
+		if f.allows(Preserving) && f.fixMissingImport(pkg, file, filename, content, offset, msg) {
+			return Imported, Preserving
+		}
+		if f.allows(Guessing) && f.fixFuzzyRename(pkg, file, filename, content, offset, msg) {
+			return Renamed, Guessing
+		}
+		if f.fixUndefinedFunc(pkg, file, filename, content, offset, msg) {
+			return Deferred, Safe
+		}
+		if f.allows(Guessing) && f.fixUndefinedVar(pkg, file, filename, content, offset, msg) {
+			return Declared, Guessing
+		}
+		if f.fixMissingReturn(pkg, file, filename, content, offset, msg) {
+			return Deferred, Safe
+		}
+		if f.fixDefer(pkg, file, filename, content, offset, msg) {
+			return Deferred, Safe
+		}
+		if kind := f.fixComparison(pkg, file, filename, content, offset, msg); kind != "" {
+			return kind, confidenceOf(kind, Guessing)
+		}
+		if f.allows(Preserving) && f.fixImportConflict(pkg, file, filename, content, offset, msg) {
+			return Rewrite, Preserving
+		}
+		if f.allows(Guessing) && f.fixArrayLength(file, filename, content, offset, msg) {
+			return Rewrite, Guessing
+		}
 	}
 
-	// If we have something we can't fix, find the affected range and panic() when hit at runtime
+	// If we have something we can't fix, find the affected range and panic() when hit at runtime.
+	// Type errors only affect their own statement, but after a syntax error the rest of the block
+	// can't be trusted.
 	start, end, tail := f.findRangeToFix(file, content, offset)
+	if pkg != nil {
+		if s, e, ok := f.findStatementToFix(file, offset); ok {
+			start, end, tail = s, e, nil
+		}
+	}
 	if start == end {
 		if f.verbose {
-			fmt.Println("golo:  error outside of function declaration: ", msg)
+			f.logf("golo:  error outside of function declaration: %s", msg)
 		}
-		return false
+		return "", 0
+	}
+
+	// in a file that is all on one line, the whole of the declaration is replaced instead, as the
+	// range found by looking for line breaks may run on through the rest of the file
+	if pkg == nil && isLongRange(content, start, end) {
+		if s, e := declRange(file, content, offset); e > s && e-s < end-start {
+			if f.verbose {
+				f.logf("golo:  replacing the declaration, as the range to fix is %d bytes on one line", end-start)
+			}
+			f.update(filename, content[:s], []byte(replaceDecl(file, content, s, e, offset, f.panicMsg(msg))), content[e:])
+			return Deferred, Safe
+		}
+	}
+
+	if _, block, _ := f.findEnclosing(file, file.FileStart+token.Pos(offset)); block == nil {
+		// TODO: type errors in top-level declarations
+		if pkg != nil {
+			if f.verbose {
+				f.logf("golo:  type error outside of function declaration: %s", msg)
+			}
+			return "", 0
+		}
+		f.update(filename, content[:start], []byte(replaceDecl(file, content, start, end, offset, f.panicMsg(msg))), content[end:])
+		return Deferred, Safe
 	}
 
 	if start > offset || end < offset {
 		if f.verbose {
-			fmt.Println("golo: range doesn't include error:", start, offset, end)
+			f.logf("golo: range doesn't include error: %d %d %d", start, offset, end)
 		}
-		return false
+		return "", 0
 	}
 
 	newlinesBefore := newLinesInRange(content[start:offset])
 	newlinesAfter := newLinesInRange(content[offset:end])
-	newCode := newlinesBefore + "panic(" + fmt.Sprintf("%#v", msg) + ")" + newlinesAfter
+	fn := enclosingFunc(file, file.FileStart+token.Pos(offset))
+	newCode := newlinesBefore + f.deferStmt(file, content, fn, f.panicMsg(msg)) + newlinesAfter
+
+	f.update(filename, content[0:start], []byte(newCode), tail, content[end:])
+	return Deferred, Safe
+}
 
-	return f.update(filename, content[0:start], []byte(newCode), tail, content[end:])
+// panicMsg returns the message for a panic that replaces the error being fixed. It includes
+// where the error was, so that it is clear which use of a name (for example) was hit.
+// Stubs that are called from more than one place (like missing functions) use msg as is.
+func (f *Fixer) panicMsg(msg string) string {
+	return f.where + msg
+}
+
+// confidenceOf returns the confidence of a strategy that either defers the error
+// (which is always Safe) or fixes it with the given confidence.
+func confidenceOf(kind FixKind, fixed Confidence) Confidence {
+	if kind == Deferred {
+		return Safe
+	}
+	return fixed
 }
 
 func (f *Fixer) update(filename string, content ...[]byte) bool {
+	if f.touched != nil {
+		f.touched[filename] = true
+	}
 	f.Fixed[filename] = bytes.Join(content, nil)
+	delete(f.spilled, filename)
 	return true
 }
 
+// replaceNode replaces the source of n with code, preserving any newlines so that
+// line numbers in the rest of the file are unchanged.
+func (f *Fixer) replaceNode(file *ast.File, filename string, content []byte, n ast.Node, code string) bool {
+	start := int(n.Pos() - file.FileStart)
+	end := int(n.End() - file.FileStart)
+	return f.update(filename, content[:start], []byte(code+newLinesInRange(content[start:end])), content[end:])
+}
+
 func (f *Fixer) fixUnusedImport(file *ast.File, filename string, content []byte, offset int) bool {
 	pos := file.FileStart + token.Pos(offset)
 
@@ -272,8 +918,68 @@ func (f *Fixer) fixUnusedVar(file *ast.File, filename string, content []byte, of
 		return false
 	}
 
-	insertPos := int(ident.Pos() - file.FileStart)
-	return f.update(filename, content[:insertPos], []byte("_"), content[insertPos+int(ident.End()-ident.Pos()):])
+	edits := []edit{nodeEdit(file, ident, "_")}
+	path, _ := astutil.PathEnclosingInterval(file, ident.Pos(), ident.End())
+	if len(path) > 3 {
+		switch n := path[1].(type) {
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE || !onlyBlank(n.Lhs, ident) {
+				break
+			}
+			// x := 5 is removed, and x := f() becomes _ = f() rather than _ := f() (which doesn't compile)
+			if inStmtList(path[2]) && onlyLiterals(n.Rhs) {
+				edits = []edit{removeEdit(file, content, n)}
+			} else {
+				edits = append(edits, posEdit(file, n.TokPos, n.TokPos+2, "="))
+			}
+		case *ast.ValueSpec:
+			names := make([]ast.Expr, len(n.Names))
+			for i, name := range n.Names {
+				names[i] = name
+			}
+			// var x int is removed (but var x = f() becomes var _ = f())
+			if decl, ok := path[3].(*ast.DeclStmt); ok && len(path[2].(*ast.GenDecl).Specs) == 1 &&
+				onlyBlank(names, ident) && onlyLiterals(n.Values) {
+				edits = []edit{removeEdit(file, content, decl)}
+			}
+		}
+	}
+	return f.update(filename, applyEdits(content, edits))
+}
+
+// onlyBlank returns true if every expression in exprs is either _ or ident (which is about to become _).
+func onlyBlank(exprs []ast.Expr, ident *ast.Ident) bool {
+	for _, e := range exprs {
+		if id, ok := e.(*ast.Ident); !ok || (id != ident && id.Name != "_") {
+			return false
+		}
+	}
+	return true
+}
+
+// onlyLiterals returns true if every expression in exprs is a literal (so can be removed without
+// changing what the program does).
+func onlyLiterals(exprs []ast.Expr) bool {
+	for _, e := range exprs {
+		if _, ok := astutil.Unparen(e).(*ast.BasicLit); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// inStmtList returns true if n contains a list of statements (from which one can be removed).
+func inStmtList(n ast.Node) bool {
+	switch n.(type) {
+	case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+		return true
+	}
+	return false
+}
+
+// removeEdit returns an edit that removes n, keeping its newlines so that line numbers don't change.
+func removeEdit(file *ast.File, content []byte, n ast.Node) edit {
+	return nodeEdit(file, n, newLinesInRange(content[n.Pos()-file.FileStart:n.End()-file.FileStart]))
 }
 
 func (f *Fixer) fixUselessAssignment(file *ast.File, filename string, content []byte, offset int) bool {
@@ -309,14 +1015,25 @@ func (f *Fixer) findRangeToFix(file *ast.File, content []byte, offset int) (int,
 		return offsetOf(statement.Pos()), offsetOf(block.End()) - 1, nil
 	}
 
-	// TODO: support syntax errors outside of function bodys
+	// Outside of any block the error is in the declaration itself (see replaceDecl)
+	if block == nil {
+		start, end := findDeclToFix(file, content, offset)
+		return start, end, nil
+	}
+
 	if fnBody == nil || statement == nil {
 		return 0, 0, nil
 	}
 
-	// TODO: push syntax errors down into nested blocks when the rest of the
-	// declaration is well formed
+	// If the braces around a nested block match up, the code around it is fine
+	// and only the rest of the block needs replacing.
 	if block != fnBody {
+		end := closingBrace(content, offsetOf(block.Lbrace))
+		fnEnd := closingBrace(content, offsetOf(fnBody.Lbrace))
+		if end >= offset && fnEnd > end {
+			return offsetOf(statement.Pos()), end, nil
+		}
+
 		for _, stmt := range fnBody.List {
 			if stmt.Pos() < pos {
 				statement = stmt
@@ -365,6 +1082,77 @@ func (f *Fixer) findRangeToFix(file *ast.File, content []byte, offset int) (int,
 	return start, start + end, []byte{'}'}
 }
 
+// closingBrace returns the offset of the } that matches the { at lbrace, or -1 if there isn't one.
+// Braces in strings and comments are counted too, which is usually fine in code with syntax errors.
+func closingBrace(content []byte, lbrace int) int {
+	depth := 0
+	for i := lbrace; i < len(content); i++ {
+		switch content[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// findStatementToFix returns the range of the statement containing offset, so that just that
+// statement can be replaced. It returns false if the statement declares something that is
+// used later in the block, in which case the rest of the block should be replaced too.
+func (f *Fixer) findStatementToFix(file *ast.File, offset int) (int, int, bool) {
+	pos := file.FileStart + token.Pos(offset)
+	statement, block, _ := f.findEnclosing(file, pos)
+	if statement == nil || block == nil || !block.Rbrace.IsValid() || statement.Pos() > pos || statement.End() < pos {
+		return 0, 0, false
+	}
+
+	declared := map[string]bool{}
+	switch s := statement.(type) {
+	case *ast.AssignStmt:
+		if s.Tok == token.DEFINE {
+			for _, lhs := range s.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					declared[id.Name] = true
+				}
+			}
+		}
+	case *ast.DeclStmt:
+		for _, spec := range s.Decl.(*ast.GenDecl).Specs {
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					declared[name.Name] = true
+				}
+			case *ast.TypeSpec:
+				declared[spec.Name.Name] = true
+			}
+		}
+	case *ast.LabeledStmt:
+		return 0, 0, false
+	}
+
+	used := false
+	for _, later := range block.List {
+		if later.Pos() <= statement.Pos() {
+			continue
+		}
+		ast.Inspect(later, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && declared[id.Name] {
+				used = true
+			}
+			return !used
+		})
+	}
+	if used {
+		return 0, 0, false
+	}
+	return int(statement.Pos() - file.FileStart), int(statement.End() - file.FileStart), true
+}
+
 func (f *Fixer) findEnclosing(file *ast.File, pos token.Pos) (stmt ast.Stmt, block *ast.BlockStmt, fnBody *ast.BlockStmt) {
 	astutil.Apply(file, func(c *astutil.Cursor) bool {
 		if c.Node() == nil {
@@ -394,16 +1182,71 @@ func (f *Fixer) findEnclosing(file *ast.File, pos token.Pos) (stmt ast.Stmt, blo
 	return
 }
 
-var once sync.Once
-var _goCache string
+// cgoPosition returns the position of pos in the source file.
+// For cgo packages the syntax tree is for the file that cgo generates (in the go build cache),
+// and so the position is translated back to the original file using its //line directives.
+func (f *Fixer) cgoPosition(fi *token.File, pos token.Pos) (token.Position, bool, error) {
+	position := fi.PositionFor(pos, false)
+	cache, err := f.goCache()
+	if err != nil {
+		return position, false, err
+	}
+	if cache == "" || !strings.HasPrefix(position.Filename, cache) {
+		return position, false, nil
+	}
+	return fi.PositionFor(pos, true), true, nil
+}
 
-func goCache() string {
-	once.Do(func() {
-		out, err := exec.Command("go", "env", "GOCACHE").CombinedOutput()
+// goCache returns the go build cache directory, as configured by the environment
+// that packages are loaded with.
+func (f *Fixer) goCache() (string, error) {
+	if f.parent != nil {
+		return f.parent.goCache()
+	}
+	f.goCacheOnce.Do(func() {
+		cmd := f.goCommand("env", "GOCACHE")
+		out, err := cmd.CombinedOutput()
 		if err != nil {
-			panic(err)
+			f.goCacheErr = fmt.Errorf("go env GOCACHE failed: %w\n%s", err, out)
+			return
+		}
+		f.goCacheDir = strings.TrimSpace(string(out))
+	})
+	return f.goCacheDir, f.goCacheErr
+}
+
+// goVersion returns the version of the go command that packages are loaded with (like
+// "go1.19.13"), or "" if go env can't say.
+func (f *Fixer) goVersion() string {
+	if f.parent != nil {
+		return f.parent.goVersion()
+	}
+	f.goVersionOnce.Do(func() {
+		if out, err := f.goCommand("env", "GOVERSION").Output(); err == nil {
+			f.goVersionName = strings.TrimSpace(string(out))
 		}
-		_goCache = strings.TrimSpace(string(out))
 	})
-	return _goCache
+	return f.goVersionName
+}
+
+// goBefore returns true if the go command is older than go1.minor. Development versions of go
+// (and go commands that don't say what version they are) are assumed to be newer.
+func (f *Fixer) goBefore(minor int) bool {
+	v, ok := strings.CutPrefix(f.goVersion(), "go")
+	return ok && versionBefore(v, minor)
+}
+
+// versionBefore returns true if v (a go version without the "go", like "1.19.13" or "1.21rc2")
+// is older than 1.minor.
+func versionBefore(v string, minor int) bool {
+	v, ok := strings.CutPrefix(v, "1.")
+	if !ok {
+		return false
+	}
+	end := 0
+	for end < len(v) && v[end] >= '0' && v[end] <= '9' {
+		end++
+	}
+	n, err := strconv.Atoi(v[:end])
+	return err == nil && n < minor
 }
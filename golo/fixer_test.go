@@ -2,15 +2,25 @@ package golo
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"go/parser"
 	"go/scanner"
 	"go/token"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/tools/go/packages"
 )
 
 func TestFixer_FindRangeToFix(t *testing.T) {
@@ -57,23 +67,59 @@ func main() {
 	#i dont know why I bother...
 #}
 `,
-		// to improve...
 		"syntax_in_if.go": `package main
 
 func main() {
-	#if true {
-		oh very no
-	}
-#}
+	if true {
+		#oh very no
+	#}
+}
+`,
+		"syntax_in_for.go": `package main
+
+func main() {
+	x := 1
+	for i := 0; i < 3; i++ {
+		#fmt.Println(i
+	#}
+	fmt.Println(x)
+}
+`,
+		"unclosed_string_in_if.go": `package main
+
+func main() {
+	if true {
+		#fmt.Println("oops)
+	#}
+	fmt.Println(2)
+}
 `,
-		// not yet supported cases...
-		"tld.go": `##package main
-hah what're you going to do?
+		"tld.go": `package main
+#hah what're you going to do?#
 func main() { }`,
-		"arg_err.go": `##package main
-func main(t r y) { }`,
-		"name_err.go": `##package main
-func () { }`,
+		"arg_err.go": `package main
+#func main(t r y) { }#`,
+		"name_err.go": `package main
+#func () { }#`,
+		"broken_field.go": `package main
+
+#type T struct {
+	x int
+	y string string
+}#
+
+func main() {}`,
+		"unclosed_struct.go": `package main
+
+#type T struct {
+	x int#
+
+func main() {}`,
+		"missing_value.go": `package main
+
+#var x =#
+
+func main() {}`,
 	}
 
 	for name, eg := range examples {
@@ -126,36 +172,137 @@ func TestFixer_FixError(t *testing.T) {
 	}
 
 	for _, example := range examples {
+		example := example
 		t.Run(example.Name(), func(t *testing.T) {
+			t.Parallel()
 			testExample(t, example.Name())
 		})
 	}
 }
 
+// BenchmarkExamples reports how long it takes to fix each example.
+// Run it with: go test ./golo -run '^$' -bench Examples
+func BenchmarkExamples(b *testing.B) {
+	examples, err := os.ReadDir("../examples")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, example := range examples {
+		b.Run(example.Name(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				f, dir := exampleFixer(b, example.Name())
+				f.out = io.Discard
+				if err := f.Fix(examplePattern(b, dir)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// exampleFixer returns a Fixer configured for the example (using its golo.json if it has one),
+// and the example's directory. Each example is loaded independently, so they can be fixed in parallel.
+func exampleFixer(tb testing.TB, example string) (*Fixer, string) {
+	dir, err := filepath.Abs(filepath.Join("..", "examples", example))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	f := &Fixer{mode: "run", verbose: false, Fixed: map[string][]byte{}, config: &packages.Config{Dir: dir}}
+	// examples can enable options with a golo.json
+	if content, err := os.ReadFile(filepath.Join(dir, "golo.json")); err == nil {
+		if err := json.Unmarshal(content, &f.options); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return f, dir
+}
+
+// exampleTest is the part of an example's golo.json that says how the tests fix it
+// (the rest is the Options it is fixed with).
+type exampleTest struct {
+	// Pattern is the pattern to fix, relative to the example's directory (like ./..., for an
+	// example whose packages are fixed together). By default only the example's directory is.
+	Pattern string
+	// HideMessages replaces the errors' messages in the panics they are deferred to with "...",
+	// both in the .golo files and in what they are compared with, for examples whose errors
+	// are worded differently by different versions of Go.
+	HideMessages bool
+}
+
+// readExampleTest returns how the example in dir is tested.
+func readExampleTest(tb testing.TB, dir string) exampleTest {
+	var test exampleTest
+	if content, err := os.ReadFile(filepath.Join(dir, "golo.json")); err == nil {
+		if err := json.Unmarshal(content, &test); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return test
+}
+
+// examplePattern returns the pattern that the example in dir is fixed with.
+func examplePattern(tb testing.TB, dir string) string {
+	if test := readExampleTest(tb, dir); test.Pattern != "" {
+		return filepath.Join(dir, test.Pattern)
+	}
+	return dir
+}
+
+// reVersionedGolden matches the name of a .golo file (without the .golo) that is only used with
+// one version of Go.
+var reVersionedGolden = regexp.MustCompile(`^(.*\.go)\.(go1\.\d+)$`)
+
+// toolchainVersion returns the version of Go that the tests are run with, like "go1.20".
+func toolchainVersion() string {
+	v := runtime.Version()
+	if m := regexp.MustCompile(`^go1\.\d+`).FindString(v); m != "" {
+		return m
+	}
+	return v
+}
+
+// reDeferredMessage matches the message in the panic that an error is deferred to.
+var reDeferredMessage = regexp.MustCompile(`panic\("([^"\\]+\.go:\d+): (?:[^"\\]|\\.)*"\)`)
+
 func testExample(t *testing.T, example string) {
+	f, dir := exampleFixer(t, example)
+	f.out = testWriter{t}
+
 	expected := map[string][]byte{}
-	filepath.WalkDir("../examples/"+example, func(path string, d fs.DirEntry, err error) error {
-		fmt.Println(path)
-		if strings.HasSuffix(path, ".golo") {
-			content, err := os.ReadFile(path)
-			if err != nil {
-				return err
-			}
-			abs, err := filepath.Abs(strings.TrimSuffix(path, ".golo"))
-			if err != nil {
-				return err
+	// files that this toolchain fixes differently have their own .golo (like main.go.go1.20.golo)
+	goldens := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !strings.HasSuffix(path, ".golo") {
+			return nil
+		}
+		name := strings.TrimSuffix(path, ".golo")
+		if m := reVersionedGolden.FindStringSubmatch(name); m != nil {
+			if m[2] != toolchainVersion() {
+				return nil
 			}
-			expected[abs] = content
-			fmt.Println("expected[" + abs + "] = !")
+			name = m[1]
+		} else if goldens[name] != "" {
+			return nil
 		}
-
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		expected[name], goldens[name] = content, path
 		return nil
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	f := &Fixer{mode: "run", verbose: false, Fixed: map[string][]byte{}}
-	if err := f.Fix("../examples/" + example); err != nil {
+	if err := f.Fix(examplePattern(t, dir)); err != nil {
 		t.Fatal(err)
 	}
+	hide := readExampleTest(t, dir).HideMessages
 
 	for k := range expected {
 		if _, ok := f.Fixed[k]; !ok {
@@ -164,26 +311,504 @@ func testExample(t *testing.T, example string) {
 	}
 
 	for k, content := range f.Fixed {
+		if hide {
+			content = reDeferredMessage.ReplaceAll(content, []byte(`panic("$1: ...")`))
+		}
 		exp, ok := expected[k]
 		if !ok {
-			fmt.Println("expected not to have fixed: " + k + " but did")
+			t.Log("expected not to have fixed: " + k + " but did")
 		} else if !bytes.Equal(content, exp) {
-			fmt.Println("got wrong fix for " + k)
-			fmt.Println("## expected ##")
-			os.Stdout.Write(exp)
-			fmt.Println("## actual ##")
-			os.Stdout.Write(content)
+			t.Logf("got wrong fix for %s\n## expected ##\n%s\n## actual ##\n%s", k, exp, content)
 		} else {
 			continue
 		}
 
 		if os.Getenv("GOLO_FIX_TESTS") != "" {
-			if err := os.WriteFile(k+".golo", content, 0o666); err != nil {
+			golden := goldens[k]
+			if golden == "" {
+				golden = k + ".golo"
+			}
+			if err := os.WriteFile(golden, content, 0o666); err != nil {
 				t.Fatal(err)
 			}
 		} else {
 			t.Error()
 		}
 	}
+}
+
+// testWriter writes a Fixer's output to the test log.
+type testWriter struct {
+	t *testing.T
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+func TestFixer_CgoPosition(t *testing.T) {
+	content := []byte("package main\n\n//line /src/main.go:10:1\nfunc boop() {}\n")
+
+	examples := []struct {
+		name     string
+		cache    string
+		filename string
+		expected string
+		isCgo    bool
+	}{
+		{"in cache", "/fake/cache", "/fake/cache/ab/main.cgo1.go", "/src/main.go:10:1", true},
+		{"in other cache", "/other/cache", "/fake/cache/ab/main.cgo1.go", "/fake/cache/ab/main.cgo1.go:4:1", false},
+		{"no cache", "", "/fake/cache/ab/main.cgo1.go", "/fake/cache/ab/main.cgo1.go:4:1", false},
+	}
+
+	for _, eg := range examples {
+		t.Run(eg.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, eg.filename, content, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := &Fixer{}
+			f.goCacheOnce.Do(func() { f.goCacheDir = eg.cache })
+
+			pos := file.Decls[0].Pos()
+			position, isCgo, err := f.cgoPosition(fset.File(pos), pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if position.String() != eg.expected || isCgo != eg.isCgo {
+				t.Errorf("expected %s (%v), got %s (%v)", eg.expected, eg.isCgo, position, isCgo)
+			}
+		})
+	}
+}
+
+func TestFixer_MinConfidence(t *testing.T) {
+	for _, eg := range []struct {
+		min        Confidence
+		kind       FixKind
+		confidence Confidence
+	}{
+		{0, Reordered, Guessing},
+		{Preserving, Deferred, Safe},
+		{Safe, Deferred, Safe},
+	} {
+		t.Run(fmt.Sprint(eg.min), func(t *testing.T) {
+			f := &Fixer{mode: "run", Fixed: map[string][]byte{}, out: io.Discard}
+			f.options.MinConfidence = eg.min
+			if err := f.Fix("../examples/reorder-arguments"); err != nil {
+				t.Fatal(err)
+			}
+			if len(f.Applied) == 0 {
+				t.Fatal("expected a fix")
+			}
+			if fix := f.Applied[0]; fix.Kind != eg.kind || fix.Confidence != eg.confidence {
+				t.Errorf("expected %s (%s), got %s (%s)", eg.kind, eg.confidence, fix.Kind, fix.Confidence)
+			}
+		})
+	}
+
+	var options Options
+	if err := json.Unmarshal([]byte(`{"MinConfidence": "preserving"}`), &options); err != nil || options.MinConfidence != Preserving {
+		t.Errorf("expected preserving, got %s (%v)", options.MinConfidence, err)
+	}
+	if _, err := ParseConfidence("sure"); err == nil {
+		t.Error("expected an invalid confidence to be rejected")
+	}
+}
+
+func TestFixer_FixesEachFileInTheSameIteration(t *testing.T) {
+	f := &Fixer{mode: "run", Fixed: map[string][]byte{}, out: io.Discard}
+	iterations := []int{}
+	f.checkpoint = func() error {
+		iterations = append(iterations, len(f.Applied))
+		return nil
+	}
+	if err := f.Fix("../examples/multi-file"); err != nil {
+		t.Fatal(err)
+	}
+	if len(iterations) == 0 || iterations[0] != 2 {
+		t.Errorf("expected both files to be fixed in the first iteration, got %v", iterations)
+	}
+}
+
+func TestFixer_Lookalikes(t *testing.T) {
+	f, dir := exampleFixer(t, "lookalike-characters")
+	out := &bytes.Buffer{}
+	f.out = out
+	if err := f.Fix(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Applied) != 1 || f.Applied[0].Kind != Rewrite {
+		t.Errorf("expected every lookalike to be replaced at once, got %v", f.Applied)
+	}
+	if !strings.Contains(out.String(), "replaced 17 lookalike characters") {
+		t.Errorf("expected the characters to be counted, got %q", out.String())
+	}
+}
+
+func TestFixer_LongLine(t *testing.T) {
+	// a generated file that is all on one line, with syntax errors part way through
+	decls := []string{"package main"}
+	for i := 0; i < 16000; i++ {
+		decls = append(decls, fmt.Sprintf("func f%d() string { s := %q; return s }", i, strings.Repeat("x", 80)))
+	}
+	decls[8000] = `func broken() string { s := ; return "b" }`
+	decls[12000] = `func broken2() { if { println("c") } }`
+	decls = append(decls, `func main() { println(f1(), f15999()) }`)
+	dir := writeModule(t, map[string]string{"main.go": strings.Join(decls, ";")})
+	main := filepath.Join(dir, "main.go")
+
+	f := &Fixer{mode: "run", Fixed: map[string][]byte{}, out: io.Discard, config: &packages.Config{Dir: dir}}
+	start := time.Now()
+	if err := f.Fix(dir); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Second {
+		t.Errorf("expected a long line to be fixed quickly, took %v", elapsed)
+	}
+	if len(f.Applied) != 3 {
+		t.Fatalf("expected 3 deferrals, got %v", f.Applied)
+	}
+	content, err := f.readFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the broken declarations are replaced, not the rest of the line
+	file, err := parser.ParseFile(token.NewFileSet(), main, content, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Decls) != len(decls)-1 {
+		t.Errorf("expected %d declarations, got %d", len(decls)-1, len(file.Decls))
+	}
+}
+
+func TestFixer_MaxFixes(t *testing.T) {
+	// a file with more independent syntax errors than the default limit
+	decls := []string{"package main\n"}
+	for i := 0; i < 15; i++ {
+		decls = append(decls, fmt.Sprintf("func f%d() { s := ; println(s) }\n", i))
+	}
+	decls = append(decls, "func main() { f0() }\n")
+	dir := writeModule(t, map[string]string{"main.go": strings.Join(decls, "")})
+
+	for _, max := range []int{3, 0} {
+		f := NewFixer("run", false, nil)
+		f.out = io.Discard
+		f.config = &packages.Config{Dir: dir}
+		f.options.MaxFixes = max
+		if err := f.Fix(dir); err != nil {
+			t.Fatal(err)
+		}
+		if max == 0 && (f.limited || len(f.Applied) != 15) {
+			t.Errorf("expected every error to be fixed without a limit, got %d fixes", len(f.Applied))
+		}
+		if max > 0 && (!f.limited || len(f.Applied) >= 15) {
+			t.Errorf("expected to stop at -max-fixes %d, got %d fixes", max, len(f.Applied))
+		}
+	}
+}
+
+func TestFixer_ErrorfWrapBeforeGo120(t *testing.T) {
+	main := "package main\n\nimport (\n\t\"errors\"\n\t\"fmt\"\n)\n\nfunc main() {\n\ta, b := errors.New(\"a\"), errors.New(\"b\")\n" +
+		"\tprintln(fmt.Errorf(\"%w: %w (%w)\", a, b, b).Error())\n}\n"
+	for _, version := range []string{"go1.19.13", "go1.20"} {
+		dir := writeModule(t, map[string]string{"main.go": main})
+		f := NewFixer("run", false, nil)
+		f.out = io.Discard
+		f.config = &packages.Config{Dir: dir}
+		f.options.FixFormat = true
+		f.goVersionOnce.Do(func() { f.goVersionName = version })
+		if err := f.Fix(dir); err != nil {
+			t.Fatal(err)
+		}
+
+		// only the first %w wraps its error before go1.20
+		if version == "go1.20" {
+			if len(f.Applied) > 0 {
+				t.Errorf("%s: expected nothing to be fixed, got %v", version, f.Applied)
+			}
+			continue
+		}
+		if len(f.Applied) != 2 || f.Applied[0].Note == "" {
+			t.Errorf("%s: expected two fixes with a note, got %v", version, f.Applied)
+		}
+		for _, content := range f.Fixed {
+			if !strings.Contains(string(content), `"%w: %v (%v)"`) {
+				t.Errorf("%s: expected only the first %%w to be kept, got:\n%s", version, content)
+			}
+		}
+	}
+}
+
+func TestFixer_LoopClosure(t *testing.T) {
+	main := `package main
+
+func main() {
+	done := make(chan bool)
+	for _, v := range []string{"a", "b"} {
+		go func() {
+			println(v)
+			done <- true
+		}()
+	}
+	for _, s := range []string{"c"} { defer func() { println(s) }() }
+	<-done
+	<-done
+}
+`
+	fixed := strings.NewReplacer("\t\tgo func", "\t\tv := v\n\t\tgo func", "{ defer", "{ s := s; defer").Replace(main)
+
+	for _, c := range []struct {
+		goMod, version string
+		fixed          bool
+	}{
+		{"1.20", "go1.22.0", true},
+		{"1.22", "go1.22.0", false},
+		{"1.22", "go1.21.5", true},
+	} {
+		dir := writeModule(t, map[string]string{"main.go": main})
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n\ngo "+c.goMod+"\n"), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		f := NewFixer("run", false, nil)
+		f.out = io.Discard
+		f.config = &packages.Config{Dir: dir}
+		f.options.FixLoopClosure = true
+		f.goVersionOnce.Do(func() { f.goVersionName = c.version })
+		if err := f.Fix(dir); err != nil {
+			t.Fatal(err)
+		}
+
+		got := main
+		for _, content := range f.Fixed {
+			got = string(content)
+		}
+		if want := map[bool]string{true: fixed, false: main}[c.fixed]; got != want {
+			t.Errorf("go %s with %s: expected\n%s\ngot\n%s", c.goMod, c.version, want, got)
+		}
+		if c.fixed && (len(f.Applied) != 2 || f.Applied[0].Note == "") {
+			t.Errorf("go %s with %s: expected two fixes with a note, got %v", c.goMod, c.version, f.Applied)
+		}
+	}
+}
+
+func TestFixer_TestHelper(t *testing.T) {
+	f, dir := exampleFixer(t, "test-helper")
+	out := &bytes.Buffer{}
+	f.out = out
+	if err := f.Fix(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Fixed) != 0 || len(f.unfixed) == 0 {
+		t.Errorf("expected the error to be left unfixed, got %d fixed files and %v", len(f.Fixed), f.unfixed)
+	}
+	if !strings.Contains(out.String(), "shout is defined in main_test.go; move it to a non-test file") {
+		t.Errorf("expected a note about main_test.go, got %q", out.String())
+	}
+}
+
+func TestFixer_NoProgress(t *testing.T) {
+	f, dir := exampleFixer(t, "no-progress")
+	out := &bytes.Buffer{}
+	f.out = out
+	if err := f.Fix(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.unfixed) == 0 {
+		t.Errorf("expected the error to be left unfixed")
+	}
+	if n := strings.Count(out.String(), "unable to make progress on "+filepath.Join(dir, "main.go")+":9:5: no new variables"); n != 1 {
+		t.Errorf("expected to be told once that golo couldn't make progress, got:\n%s", out)
+	}
+}
+
+func TestFixer_OnFix(t *testing.T) {
+	f := &Fixer{mode: "run", Fixed: map[string][]byte{}, out: io.Discard, workers: 4}
+	fixes := []Fix{}
+	f.onFix = func(fix Fix) { fixes = append(fixes, fix) }
+	if err := f.Fix("../examples/unused-import"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fixes, f.Applied) || len(fixes) != 2 {
+		t.Fatalf("expected each fix to be reported once, got %v", fixes)
+	}
+
+	original, err := os.ReadFile("../examples/unused-import/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the range is as small as it can be, so it can leave out the end of the call
+	if r := fixes[0].Replaced; fixes[0].Kind != Deferred || r == nil || r.Start == r.End || !strings.HasPrefix(`fmt.Println(1 + "oops")`, string(original[r.Start:r.End])) {
+		t.Errorf("expected the call to be replaced, got %v", r)
+	}
+	if r := fixes[1].Replaced; fixes[1].Kind != UnusedImport || r == nil || r.Start != r.End {
+		t.Errorf("expected _ to be inserted, got %v", r)
+	}
+}
+
+func TestFixer_StubSignature(t *testing.T) {
+	f := &Fixer{mode: "run", Fixed: map[string][]byte{}, out: io.Discard}
+	if err := f.Fix("../examples/stub-signature"); err != nil {
+		t.Fatal(err)
+	}
+	notes := []string{}
+	for _, fix := range f.Applied {
+		notes = append(notes, fix.Note)
+	}
+	sort.Strings(notes)
+	expected := []string{"Circle.Area should be Area() float64", "golo updated its stub for Square.WriteTo"}
+	if !slices.Equal(notes, expected) {
+		t.Errorf("expected notes %q, got %q", expected, notes)
+	}
+}
+
+func TestFixer_Concurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("fixes every example twice")
+	}
+	for _, mode := range []string{"run", "test"} {
+		t.Run(mode, func(t *testing.T) {
+			// go/packages parses files concurrently, so syntax errors are fixed in any order
+			fix := func(workers int) (*Fixer, []string, []string) {
+				out := &bytes.Buffer{}
+				f := &Fixer{mode: mode, Fixed: map[string][]byte{}, out: out, workers: workers}
+				if err := f.Fix("../examples/..."); err != nil {
+					t.Fatal(err)
+				}
+				applied := []string{}
+				for _, fix := range f.Applied {
+					applied = append(applied, fmt.Sprint(fix))
+				}
+				lines := strings.Split(out.String(), "\n")
+				sort.Strings(applied)
+				sort.Strings(lines)
+				return f, applied, lines
+			}
+			serial, serialApplied, serialOut := fix(1)
+			concurrent, concurrentApplied, concurrentOut := fix(4)
+
+			if len(serial.Fixed) == 0 {
+				t.Fatal("expected the examples to be fixed")
+			}
+			if len(serial.Fixed) != len(concurrent.Fixed) {
+				t.Errorf("expected %d fixed files, got %d", len(serial.Fixed), len(concurrent.Fixed))
+			}
+			for k, content := range serial.Fixed {
+				if !bytes.Equal(content, concurrent.Fixed[k]) {
+					t.Errorf("got a different fix for %s\n## serial ##\n%s\n## concurrent ##\n%s", k, content, concurrent.Fixed[k])
+				}
+			}
+			if !slices.Equal(serialApplied, concurrentApplied) {
+				t.Errorf("expected the same fixes\n## serial ##\n%v\n## concurrent ##\n%v", serialApplied, concurrentApplied)
+			}
+			// if any lines were interleaved they would be different
+			if !slices.Equal(serialOut, concurrentOut) {
+				t.Errorf("expected the same output\n## serial ##\n%s\n## concurrent ##\n%s", serialOut, concurrentOut)
+			}
+		})
+	}
+}
+
+func TestFixer_RunGenerators(t *testing.T) {
+	// the generator rewrites color_string.go, so it is run on a copy of the example
+	dir := t.TempDir()
+	files := map[string]string{"go.mod": "module example.com/generated\n\ngo 1.20\n"}
+	for _, name := range []string{"main.go", "color_string.go", "gen.sh"} {
+		content, err := os.ReadFile(filepath.Join("..", "examples", "generated", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = string(content)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
 
+	f := &Fixer{mode: "run", Fixed: map[string][]byte{}, out: testWriter{t}, config: &packages.Config{Dir: dir}}
+	f.options.RunGenerators = true
+	if err := f.Fix("."); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Applied) != 1 || f.Applied[0].Kind != Regenerated {
+		t.Fatalf("expected color_string.go to be regenerated, got %v", f.Applied)
+	}
+	if len(f.Fixed) != 0 {
+		t.Errorf("expected no files to be fixed, got %d", len(f.Fixed))
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "color_string.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(content, []byte("RedGreenBlue")) {
+		t.Errorf("expected color_string.go to have been regenerated, got:\n%s", content)
+	}
+}
+
+// The messages that golo fixes are worded differently by older versions of Go.
+func TestFixer_MessagesFromOlderGo(t *testing.T) {
+	for _, msg := range []string{
+		"name shout not exported by package other",
+		"shout not exported by package other",
+	} {
+		if m := reUnexported.FindStringSubmatch(msg); m == nil || m[1] != "shout" {
+			t.Errorf("expected %q to be matched as an unexported name, got %q", msg, m)
+		}
+	}
+
+	for _, out := range []string{
+		"package m/a\n\timports m/a from a.go: import cycle not allowed\n",
+		"package m/a\n\timports m/a: import cycle not allowed\n",
+	} {
+		if pkgs := selfImports([]byte(out)); !reflect.DeepEqual(pkgs, []string{"m/a"}) {
+			t.Errorf("expected m/a to import itself in %q, got %q", out, pkgs)
+		}
+	}
+
+	for _, msg := range []string{
+		"could not import example.com/m/greet (import cycle: [example.com/m/greet])",
+		"could not import example.com/m/greet (import cycle: [example.com/m/greet [example.com/m/greet.test]])",
+	} {
+		if m := reSelfImport.FindStringSubmatch(msg); m == nil || m[1] != m[2] {
+			t.Errorf("expected %q to be matched as a self-import, got %q", msg, m)
+		}
+	}
+}
+
+func TestNewFixer(t *testing.T) {
+	main := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(main, []byte("package main\n\nfunc main() { broken }\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	fixed := map[string][]byte{main: []byte("package main\n\nfunc main() {}\n")}
+
+	f := NewFixer("test", true, fixed)
+	if f.mode != "test" || !f.verbose {
+		t.Errorf("expected the mode and verbosity to be set, got %q %v", f.mode, f.verbose)
+	}
+	content, err := f.readFile(main)
+	if err != nil || !bytes.Equal(content, fixed[main]) {
+		t.Errorf("expected the fixed content to be read instead of the file, got %q (%v)", content, err)
+	}
+
+	if f := NewFixer("build", false, nil); f.Fixed == nil {
+		t.Error("expected Fixed to be initialized")
+	}
+}
+
+// The files a Fixer fixed are in Fixed, which can be written to an overlay for go build.
+func ExampleNewFixer() {
+	f := NewFixer("build", false, nil)
+	if err := f.Fix("./..."); err != nil {
+		fmt.Println(err)
+		return
+	}
+	for filename, content := range f.Fixed {
+		fmt.Printf("%s: %d bytes\n", filename, len(content))
+	}
 }
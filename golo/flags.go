@@ -0,0 +1,167 @@
+package golo
+
+import "strings"
+
+// buildFlagsWithValue are the flags shared by go build, go run and go test that
+// take a value as a separate argument (e.g. -tags integration).
+var buildFlagsWithValue = map[string]bool{
+	"C": true, "asmflags": true, "buildmode": true, "compiler": true, "coverpkg": true,
+	"covermode": true, "exec": true, "gccgoflags": true, "gcflags": true, "installsuffix": true,
+	"ldflags": true, "mod": true, "modfile": true, "o": true, "overlay": true, "p": true,
+	"pgo": true, "pkgdir": true, "tags": true, "toolexec": true,
+}
+
+// splitFlags splits args into the leading flags (as understood by go build) and the rest.
+func splitFlags(args []string) ([]string, []string) {
+	i := 0
+	for i < len(args) && strings.HasPrefix(args[i], "-") {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !hasValue && buildFlagsWithValue[name] {
+			i++
+		}
+		i++
+	}
+	if i > len(args) {
+		i = len(args)
+	}
+	return args[:i], args[i:]
+}
+
+// outputFlag returns the value of the last -o flag in args, if there is one.
+func outputFlag(args []string) string {
+	flags, _ := splitFlags(args)
+	o := ""
+	for i := 0; i < len(flags); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(flags[i], "-"), "=")
+		if name == "o" && hasValue {
+			o = value
+		} else if name == "o" && i+1 < len(flags) {
+			o = flags[i+1]
+		}
+	}
+	return o
+}
+
+// withoutOutputFlag returns flags without any -o flags (which go list doesn't accept).
+func withoutOutputFlag(flags []string) []string {
+	ret := []string{}
+	for i := 0; i < len(flags); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(flags[i], "-"), "=")
+		if name == "o" {
+			if !hasValue {
+				i++
+			}
+			continue
+		}
+		ret = append(ret, flags[i])
+	}
+	return ret
+}
+
+// testFlagsWithValue are the flags that go test passes to the test binary (rather than using
+// them to build it) that take a value, which may be a separate argument (e.g. -run TestFoo).
+var testFlagsWithValue = map[string]bool{
+	"bench": true, "benchtime": true, "blockprofile": true, "blockprofilerate": true, "count": true,
+	"coverprofile": true, "cpu": true, "cpuprofile": true, "fuzz": true, "fuzzminimizetime": true,
+	"fuzztime": true, "list": true, "memprofile": true, "memprofilerate": true, "mutexprofile": true,
+	"mutexprofilefraction": true, "outputdir": true, "parallel": true, "run": true, "shuffle": true,
+	"skip": true, "timeout": true, "trace": true,
+}
+
+// testBoolFlags are the flags that go test passes to the test binary that don't take a value.
+var testBoolFlags = map[string]bool{
+	"benchmem": true, "failfast": true, "fullpath": true, "json": true, "short": true, "v": true,
+}
+
+// splitTestFlags splits the args to go test into the flags that change how the tests are
+// built (followed by the packages), and the flags that change how they are run (followed by
+// -args and the rest of the arguments, if there are any). Like go test, flags can be before
+// or after the packages.
+func splitTestFlags(args []string) ([]string, []string) {
+	build, pkgs, test := []string{}, []string{}, []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-args" || arg == "--args" {
+			test = append(test, args[i:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			pkgs = append(pkgs, arg)
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch {
+		case testFlagsWithValue[name] || testBoolFlags[name]:
+			test = append(test, arg)
+			if !hasValue && testFlagsWithValue[name] && i+1 < len(args) {
+				test = append(test, args[i+1])
+				i++
+			}
+		default:
+			build = append(build, arg)
+			if !hasValue && buildFlagsWithValue[name] && i+1 < len(args) {
+				build = append(build, args[i+1])
+				i++
+			}
+		}
+	}
+	return append(build, pkgs...), test
+}
+
+// testBinaryFlags translates flags for go test (as returned by splitTestFlags) into the flags
+// that go test would pass to the test binary (e.g. -run TestFoo becomes -test.run=TestFoo).
+// It returns false if go test does more than pass the flag on, as -json does.
+func testBinaryFlags(flags []string) ([]string, bool) {
+	ret := []string{}
+	timeout := false
+	for i := 0; i < len(flags); i++ {
+		if flags[i] == "-args" || flags[i] == "--args" {
+			ret = append(ret, flags[i+1:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(flags[i], "-"), "=")
+		if name == "json" {
+			return nil, false
+		}
+		if !hasValue && testFlagsWithValue[name] && i+1 < len(flags) {
+			value, hasValue = flags[i+1], true
+			i++
+		}
+		timeout = timeout || name == "timeout"
+		if hasValue {
+			ret = append(ret, "-test."+name+"="+value)
+		} else {
+			ret = append(ret, "-test."+name)
+		}
+	}
+	// go test's defaults, which the test binary doesn't have by itself
+	if !timeout {
+		ret = append([]string{"-test.timeout=10m0s"}, ret...)
+	}
+	return append([]string{"-test.paniconexit0"}, ret...), true
+}
+
+// loadFlags returns the build flags that packages.Load should pass to go list, so that the
+// Fixer loads the same files that go build compiles (e.g. those for -tags). go list doesn't
+// accept -o, and -C must come before go list's own flags, so the directory is returned instead.
+func loadFlags(flags []string) ([]string, string) {
+	ret, dir := []string{}, ""
+	for i := 0; i < len(flags); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(flags[i], "-"), "=")
+		if !hasValue && buildFlagsWithValue[name] && i+1 < len(flags) {
+			value = flags[i+1]
+			i++
+		}
+		switch name {
+		case "o":
+		case "C":
+			dir = value
+		default:
+			ret = append(ret, "-"+name)
+			if hasValue || buildFlagsWithValue[name] {
+				ret[len(ret)-1] += "=" + value
+			}
+		}
+	}
+	return ret, dir
+}
@@ -0,0 +1,104 @@
+package golo
+
+import (
+	"context"
+	"go/build"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// goEnv returns the environment to run the go command with.
+// The user's environment is passed through unchanged (so GOPRIVATE, GONOSUMDB, GOPROXY,
+// GOFLAGS, and the HOME that .netrc is read from all work as they do for go itself),
+// followed by options.Env, except that if options.Offline is set, module downloads are
+// disabled so that anything that needs the network fails straight away (with a message
+// from go) instead of hanging.
+func goEnv(options Options) []string {
+	env := append(os.Environ(), options.Env...)
+	if !options.Offline {
+		return env
+	}
+	ret := []string{}
+	for _, kv := range env {
+		switch {
+		case strings.HasPrefix(kv, "GOPROXY="):
+		case strings.HasPrefix(kv, "GOFLAGS="):
+			if flags := offlineGoFlags(strings.TrimPrefix(kv, "GOFLAGS=")); flags != "" {
+				ret = append(ret, "GOFLAGS="+flags)
+			}
+		default:
+			ret = append(ret, kv)
+		}
+	}
+	return append(ret, "GOPROXY=off")
+}
+
+// offlineGoFlags returns flags without -mod=mod, which lets go download modules
+// that are missing from go.mod.
+func offlineGoFlags(flags string) string {
+	kept := []string{}
+	for _, flag := range strings.Fields(flags) {
+		if flag != "-mod=mod" && flag != "--mod=mod" {
+			kept = append(kept, flag)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// goCommand returns a command that runs go with args, in the environment from goEnv.
+// It is killed if ctx is done before it exits.
+func goCommand(ctx context.Context, options Options, args ...string) *exec.Cmd {
+	return goCommandEnv(ctx, goEnv(options), args...)
+}
+
+// goCommandEnv returns a command that runs go with args in env.
+func goCommandEnv(ctx context.Context, env []string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Env = env
+	// go is killed when ctx is done, but the programs it runs (like a -toolexec) may not be,
+	// so their output is closed after a second instead of waiting for them to exit
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// goCommand returns a command that runs go with args, in the environment of the build.
+func (r *Runner) goCommand(args ...string) *exec.Cmd {
+	if r.build == nil {
+		return goCommand(r.context(), r.Options, args...)
+	}
+	return r.build.command(r.context(), args...)
+}
+
+// buildContext returns the build.Context that files are matched with when they are loaded.
+func (f *Fixer) buildContext() build.Context {
+	if f.build == nil {
+		return build.Default
+	}
+	return f.build.buildContext()
+}
+
+// context returns the context that packages are loaded (and go commands are run) with.
+func (f *Fixer) context() context.Context {
+	if f.ctx != nil {
+		return f.ctx
+	}
+	if f.config != nil && f.config.Context != nil {
+		return f.config.Context
+	}
+	return context.Background()
+}
+
+// goCommand returns a command that runs go with args, in the environment that packages
+// are loaded with.
+func (f *Fixer) goCommand(args ...string) *exec.Cmd {
+	cmd := goCommand(f.context(), f.options, args...)
+	if f.config != nil {
+		if f.config.Env != nil {
+			cmd.Env = f.config.Env
+		}
+		cmd.Dir = f.config.Dir
+	}
+	return cmd
+}
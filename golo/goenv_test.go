@@ -0,0 +1,73 @@
+package golo
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestGoEnv(t *testing.T) {
+	t.Setenv("GOPRIVATE", "example.com/private")
+	t.Setenv("GONOSUMDB", "example.com/nosum")
+	t.Setenv("GOPROXY", "https://proxy.example.com")
+	t.Setenv("GOFLAGS", "-mod=mod -trimpath")
+
+	lookup := func(env []string, key string) []string {
+		values := []string{}
+		for _, kv := range env {
+			if len(kv) > len(key) && kv[:len(key)+1] == key+"=" {
+				values = append(values, kv[len(key)+1:])
+			}
+		}
+		return values
+	}
+
+	for _, eg := range []struct {
+		offline bool
+		proxy   string
+		flags   string
+	}{
+		{false, "https://proxy.example.com", "-mod=mod -trimpath"},
+		{true, "off", "-trimpath"},
+	} {
+		cmds := map[string][]string{
+			"goEnv":            goEnv(Options{Offline: eg.offline}),
+			"goCommand":        goCommand(context.Background(), Options{Offline: eg.offline}, "build").Env,
+			"Fixer.goCommand":  (&Fixer{options: Options{Offline: eg.offline}}).goCommand("env").Env,
+			"Runner.goCommand": (&Runner{build: newBuildConfig(Options{Offline: eg.offline}, nil)}).goCommand("build").Env,
+			"Fixer.loadConfig": nil,
+		}
+		build := newBuildConfig(Options{Offline: eg.offline}, []string{"-tags", "integration"})
+		config, err := (&Fixer{options: Options{Offline: eg.offline}, build: build}).loadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmds["Fixer.loadConfig"] = config.Env
+		if !slices.Equal(config.BuildFlags, []string{"-tags=integration"}) {
+			t.Errorf("expected the build's flags to be used, got %v", config.BuildFlags)
+		}
+
+		for name, env := range cmds {
+			if got := lookup(env, "GOPRIVATE"); !slices.Equal(got, []string{"example.com/private"}) {
+				t.Errorf("%s (offline=%v): expected GOPRIVATE to be passed through, got %v", name, eg.offline, got)
+			}
+			if got := lookup(env, "GONOSUMDB"); !slices.Equal(got, []string{"example.com/nosum"}) {
+				t.Errorf("%s (offline=%v): expected GONOSUMDB to be passed through, got %v", name, eg.offline, got)
+			}
+			if got := lookup(env, "GOPROXY"); !slices.Equal(got, []string{eg.proxy}) {
+				t.Errorf("%s (offline=%v): expected GOPROXY=%s, got %v", name, eg.offline, eg.proxy, got)
+			}
+			if got := lookup(env, "GOFLAGS"); !slices.Equal(got, []string{eg.flags}) {
+				t.Errorf("%s (offline=%v): expected GOFLAGS=%s, got %v", name, eg.offline, eg.flags, got)
+			}
+		}
+	}
+
+	// an environment given explicitly is used as is
+	f := &Fixer{options: Options{Offline: true}, config: &packages.Config{Env: []string{"GOPROXY=direct"}}}
+	if env := f.goCommand("env").Env; !slices.Equal(env, []string{"GOPROXY=direct"}) {
+		t.Errorf("expected the configured environment, got %v", env)
+	}
+}
@@ -0,0 +1,271 @@
+// Package golotest helps you test how golo fixes your code, for example to check that an error
+// that your team often makes can still be deferred. Each test writes a module to a temporary
+// directory with Module, runs golo on it with Run, and then checks what golo did:
+//
+//	func TestDeferrable(t *testing.T) {
+//		dir := golotest.Module(t, map[string]string{
+//			"main.go": "package main\n\nfunc main() {\n\tprintln(\"hi\")\n\tundefined()\n}\n",
+//		})
+//		res := golotest.Run(t, dir, golotest.Config{Mode: "build"})
+//		res.ExpectDeferrals(t, 1)
+//		res.ExpectFixed(t, "main.go")
+//	}
+//
+// Everything golo writes goes in the test's temporary directories, which are removed when the
+// test finishes. As golo runs the go command in the current directory, Run changes into the
+// module while golo runs, so tests that use it can't be run in parallel.
+package golotest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ConradIrwin/golo/golo"
+)
+
+// Module writes files (a map from slash-separated filenames to their content) to a new
+// temporary directory, and returns its path. Unless files includes a go.mod, the directory
+// is a module called example.com/m.
+func Module(tb testing.TB, files map[string]string) string {
+	tb.Helper()
+	dir := tb.TempDir()
+	if _, ok := files["go.mod"]; !ok {
+		write(tb, filepath.Join(dir, "go.mod"), "module example.com/m\n\ngo 1.20\n")
+	}
+	for name, content := range files {
+		write(tb, filepath.Join(dir, filepath.FromSlash(name)), content)
+	}
+	return dir
+}
+
+func write(tb testing.TB, name string, content string) {
+	tb.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o777); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o666); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// Config is how Run runs golo.
+type Config struct {
+	// Mode is the golo command: "run" (the default), "build", "test", "fix" or "ci".
+	Mode string
+	// Args are the arguments to the command, as they'd be passed to golo (or go).
+	// If there are none, the package in the module's directory is used.
+	Args []string
+	// Options configure golo, as the command-line flags would (though unlike -max-new-deferrals,
+	// MaxNewDeferrals is zero unless it is set, so with a Baseline any new deferral is an error).
+	// MaxFixes is DefaultMaxFixes unless it is set; set it to -1 for no limit.
+	Options golo.Options
+
+	// Stdin is read by the program (or tests) that golo runs. If nil, it reads nothing.
+	Stdin io.Reader
+	// Stdout and Stderr, if set, are also sent what golo (and what it runs) writes,
+	// which is always kept in the Result.
+	Stdout, Stderr io.Writer
+	// Context, if set, stops golo (and what it runs) once it is done. Either way, golo is
+	// stopped shortly before the test's deadline (see go test -timeout), so that a test that
+	// hangs fails with an error instead of panicking.
+	Context context.Context
+}
+
+// Result is what happened when golo was run.
+type Result struct {
+	// Status is the exit status that golo would have exited with.
+	Status int
+	// Err is the error that golo failed with, if it did.
+	Err error
+	// Stdout and Stderr are what golo (and what it ran) wrote.
+	Stdout, Stderr string
+	// Outcome is what golo fixed. It is nil if golo failed before fixing anything.
+	Outcome *golo.Outcome
+}
+
+// deadlineMargin is how long before the test's deadline Run stops golo, which leaves time for
+// the test to report the failure.
+const deadlineMargin = 5 * time.Second
+
+// Run runs golo in dir (as Module returns) and returns what happened. The go commands
+// that golo runs ignore any go.work file, and the GOFLAGS that the tests were run with.
+func Run(tb testing.TB, dir string, c Config) *Result {
+	tb.Helper()
+	if c.Mode == "" {
+		c.Mode = "run"
+	}
+	if len(c.Args) == 0 {
+		c.Args = []string{"."}
+	}
+	// golo's temporary files are created in TMPDIR, and removed with the test's
+	tb.Setenv("TMPDIR", tb.TempDir())
+	wd, err := os.Getwd()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		tb.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	r := golo.New(c.Mode, false, c.Args)
+	r.Options = c.Options
+	if r.Options.MaxFixes == 0 {
+		r.Options.MaxFixes = golo.DefaultMaxFixes
+	}
+	r.Options.Env = append([]string{"GOWORK=off", "GOFLAGS="}, c.Options.Env...)
+	r.Stdin = c.Stdin
+	if r.Stdin == nil {
+		r.Stdin = strings.NewReader("")
+	}
+	r.Stdout, r.Stderr = tee(stdout, c.Stdout), tee(stderr, c.Stderr)
+	res := &Result{}
+	r.AddSink(sinkFunc(func(o *golo.Outcome) error {
+		res.Outcome = o
+		return nil
+	}))
+
+	ctx := c.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if t, ok := tb.(interface{ Deadline() (time.Time, bool) }); ok {
+		if deadline, ok := t.Deadline(); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline.Add(-deadlineMargin))
+			defer cancel()
+		}
+	}
+
+	if res.Err = r.PrepareContext(ctx); res.Err != nil {
+		res.Status = 1
+	} else if res.Status, res.Err = r.RunContext(ctx); res.Err != nil {
+		res.Status = 1
+	}
+	res.Stdout, res.Stderr = stdout.String(), stderr.String()
+	return res
+}
+
+func tee(buf *bytes.Buffer, w io.Writer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(buf, w)
+}
+
+type sinkFunc func(o *golo.Outcome) error
+
+func (s sinkFunc) Report(o *golo.Outcome) error {
+	return s(o)
+}
+
+// Deferrals returns the errors that golo deferred until runtime.
+func (r *Result) Deferrals() []golo.Fix {
+	if r.Outcome == nil {
+		return nil
+	}
+	return r.Outcome.Report.Deferrals()
+}
+
+// Unfixed returns the errors that golo could neither fix nor defer, as "file:line:col: message".
+func (r *Result) Unfixed() []string {
+	if r.Outcome == nil {
+		return nil
+	}
+	errs := []string{}
+	for _, e := range r.Outcome.Unfixed {
+		errs = append(errs, e.Error())
+	}
+	return errs
+}
+
+// FixedFiles returns the files that golo fixed (or deferred errors in), relative to the module
+// and sorted. Files changed by golo fix have the fixes written to them, the rest are unchanged.
+func (r *Result) FixedFiles() []string {
+	if r.Outcome == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	files := []string{}
+	for _, fix := range r.Outcome.Report.Fixes {
+		if !seen[fix.Pos.Filename] {
+			seen[fix.Pos.Filename] = true
+			files = append(files, fix.Pos.Filename)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// ExpectDeferrals fails the test unless golo deferred exactly n errors.
+func (r *Result) ExpectDeferrals(tb testing.TB, n int) {
+	tb.Helper()
+	if got := r.Deferrals(); len(got) != n {
+		tb.Errorf("expected %d deferrals, got %d:%s%s", n, len(got), fixList(got), r.output())
+	}
+}
+
+// ExpectUnfixed fails the test unless each of the errors that golo could neither fix nor defer
+// contains the corresponding message, in order.
+func (r *Result) ExpectUnfixed(tb testing.TB, msgs ...string) {
+	tb.Helper()
+	got := r.Unfixed()
+	if len(got) != len(msgs) {
+		tb.Errorf("expected %d unfixed errors, got %d: %q%s", len(msgs), len(got), got, r.output())
+		return
+	}
+	for i, msg := range msgs {
+		if !strings.Contains(got[i], msg) {
+			tb.Errorf("expected unfixed error %d to contain %q, got %q", i, msg, got[i])
+		}
+	}
+}
+
+// ExpectFixed fails the test unless golo fixed exactly the given files (relative to the module,
+// with forward slashes).
+func (r *Result) ExpectFixed(tb testing.TB, files ...string) {
+	tb.Helper()
+	want := append([]string{}, files...)
+	sort.Strings(want)
+	if got := r.FixedFiles(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		tb.Errorf("expected fixes in %q, got %q:%s%s", want, got, fixList(r.fixes()), r.output())
+	}
+}
+
+func (r *Result) fixes() []golo.Fix {
+	if r.Outcome == nil {
+		return nil
+	}
+	return r.Outcome.Report.Fixes
+}
+
+// output returns what golo wrote, to explain why an expectation failed.
+func (r *Result) output() string {
+	s := ""
+	if r.Err != nil {
+		s += "\ngolo failed: " + r.Err.Error()
+	}
+	if r.Stdout != "" {
+		s += "\nstdout:\n" + r.Stdout
+	}
+	if r.Stderr != "" {
+		s += "\nstderr:\n" + r.Stderr
+	}
+	return s
+}
+
+func fixList(fixes []golo.Fix) string {
+	s := ""
+	for _, fix := range fixes {
+		s += "\n\t" + fix.Pos.String() + ": " + fix.Msg + " (" + string(fix.Kind) + ")"
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package golotest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ConradIrwin/golo/golo"
+)
+
+func TestModule(t *testing.T) {
+	dir := Module(t, map[string]string{"main.go": "package main\n", "sub/sub.go": "package sub\n"})
+	for name, want := range map[string]string{
+		"go.mod":     "module example.com/m\n\ngo 1.20\n",
+		"main.go":    "package main\n",
+		"sub/sub.go": "package sub\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", name, want, got, err)
+		}
+	}
+
+	dir = Module(t, map[string]string{"go.mod": "module example.com/other\n"})
+	if got, _ := os.ReadFile(filepath.Join(dir, "go.mod")); string(got) != "module example.com/other\n" {
+		t.Errorf("expected the go.mod that was given, got %q", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := Module(t, map[string]string{
+		"main.go": "package main\n\nfunc main() {\n\tprintln(\"before\")\n\tundefined()\n}\n",
+		"ok.go":   "package main\n\nfunc ok() string {\n\treturn \"ok\"\n}\n",
+	})
+	wd, _ := os.Getwd()
+
+	res := Run(t, dir, Config{})
+	if res.Status == 0 || res.Err != nil {
+		t.Errorf("expected the deferred error to panic, got %d %v", res.Status, res.Err)
+	}
+	if !strings.Contains(res.Stderr, "before") || !strings.Contains(res.Stderr, "undefined: undefined") {
+		t.Errorf("expected the program's output, got:\n%s", res.Stderr)
+	}
+	res.ExpectDeferrals(t, 1)
+	res.ExpectUnfixed(t)
+	res.ExpectFixed(t, "main.go")
+
+	if got, _ := os.Getwd(); got != wd {
+		t.Errorf("expected to be back in %s, got %s", wd, got)
+	}
+	if original, _ := os.ReadFile(filepath.Join(dir, "main.go")); !strings.Contains(string(original), "\tundefined()\n") {
+		t.Errorf("golo run changed main.go:\n%s", original)
+	}
+}
+
+func TestRun_Unfixed(t *testing.T) {
+	dir := Module(t, map[string]string{
+		"main.go": "package main\n\ntype T struct{ name Undefined }\n\nfunc main() {\n\tprintln(T{})\n}\n",
+	})
+
+	res := Run(t, dir, Config{Mode: "build"})
+	if res.Status == 0 {
+		t.Errorf("expected golo build to fail, got %d %v", res.Status, res.Err)
+	}
+	res.ExpectDeferrals(t, 0)
+	res.ExpectUnfixed(t, "undefined: Undefined")
+
+	// the expectations fail with what went wrong
+	fake := &testing.T{}
+	res.ExpectUnfixed(fake)
+	res.ExpectDeferrals(fake, 1)
+	res.ExpectFixed(fake, "main.go")
+	if !fake.Failed() {
+		t.Errorf("expected the expectations to fail")
+	}
+}
+
+func TestRun_MaxFixes(t *testing.T) {
+	// more independent syntax errors than the default limit: once golo stops fixing them one at a
+	// time, the next one is deferred with its function, which leaves its s unused
+	decls := []string{"package main\n"}
+	for i := 0; i < 15; i++ {
+		decls = append(decls, fmt.Sprintf("func f%d() { s := ; println(s) }\n", i))
+	}
+	decls = append(decls, "func main() { f0() }\n")
+	dir := Module(t, map[string]string{"main.go": strings.Join(decls, "")})
+
+	for _, c := range []struct {
+		max, line int
+	}{
+		{0, golo.DefaultMaxFixes + 2},
+		{3, 5},
+		{-1, 0},
+	} {
+		res := Run(t, dir, Config{Mode: "build", Options: golo.Options{MaxFixes: c.max}})
+		line := 0
+		for _, fix := range res.fixes() {
+			if fix.Kind == "unused-var" {
+				line = fix.Pos.Line
+				break
+			}
+		}
+		if line != c.line {
+			t.Errorf("MaxFixes %d: expected golo to stop fixing syntax errors before line %d, got %d", c.max, c.line, line)
+		}
+	}
+}
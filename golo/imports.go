@@ -0,0 +1,44 @@
+package golo
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+	"path"
+	"strconv"
+)
+
+// addImport returns content with an import of importPath added to file, along with
+// the name that the package can be referred to by. If the file already imports the
+// package, content is returned unchanged.
+//
+// The import is added without any new lines so that line numbers in the file stay the same.
+func addImport(file *ast.File, content []byte, importPath string) ([]byte, string) {
+	name := path.Base(importPath)
+	for _, spec := range file.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err != nil || p != importPath {
+			continue
+		}
+		if spec.Name == nil {
+			return content, name
+		}
+		if spec.Name.Name != "_" && spec.Name.Name != "." {
+			return content, spec.Name.Name
+		}
+	}
+
+	insert := func(pos token.Pos, code string) []byte {
+		offset := int(pos - file.FileStart)
+		return bytes.Join([][]byte{content[:offset], []byte(code), content[offset:]}, nil)
+	}
+
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT && d.Lparen.IsValid() {
+			return insert(d.Lparen+1, strconv.Quote(importPath)+";"), name
+		}
+	}
+	if len(file.Imports) > 0 {
+		return insert(file.Imports[len(file.Imports)-1].End(), "; import "+strconv.Quote(importPath)), name
+	}
+	return insert(file.Name.End(), "; import "+strconv.Quote(importPath)), name
+}
@@ -0,0 +1,69 @@
+package golo
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// LoadLenient loads the packages matching patterns in the same way as packages.Load,
+// but first fixes any syntax or type errors in them using the same process as `golo run`.
+//
+// The returned packages are loaded with cfg.Mode plus whatever golo needs, with the
+// fixed files supplied as overlays, and the returned fixes list the errors that were
+// deferred to runtime. The go build command is never run.
+//
+// cfg.ParseFile is ignored, and cfg.Overlay is used as the starting point for the fixes.
+//
+// If fixing stops before every error is fixed (because of Options.MaxFixes, or because it
+// stopped making progress), the packages are still returned, but so is an error saying how
+// many errors are left (which are in the packages' Errors, as packages.PrintErrors shows).
+func LoadLenient(ctx context.Context, cfg *packages.Config, patterns ...string) ([]*packages.Package, []Fix, error) {
+	config := &packages.Config{}
+	if cfg != nil {
+		*config = *cfg
+	}
+	config.Context = ctx
+
+	fixed := map[string][]byte{}
+	for k, v := range config.Overlay {
+		fixed[k] = v
+	}
+
+	mode := "build"
+	if config.Tests {
+		mode = "test"
+	}
+	f := NewFixer(mode, false, fixed)
+	f.config = config
+	f.out = io.Discard
+	if err := f.FixContext(ctx, patterns...); err != nil {
+		return nil, f.Applied, err
+	}
+
+	config, err := f.loadConfig()
+	if err != nil {
+		return nil, f.Applied, err
+	}
+	pkgs, err := packages.Load(config, patterns...)
+	if err != nil {
+		return nil, f.Applied, fmt.Errorf("packages.Load failed: %w", err)
+	}
+
+	var first error
+	n := 0
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			if first == nil {
+				first = err
+			}
+			n++
+		}
+	})
+	if n > 0 {
+		return pkgs, f.Applied, fmt.Errorf("could not fix %d %s (the first is %v)", n, plural(n, "error", "errors"), first)
+	}
+	return pkgs, f.Applied, nil
+}
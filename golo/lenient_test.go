@@ -0,0 +1,58 @@
+package golo
+
+import (
+	"context"
+	"go/ast"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestLoadLenient(t *testing.T) {
+	cfg := &packages.Config{Mode: packages.NeedName | packages.NeedTypesInfo}
+	pkgs, fixes, err := LoadLenient(context.Background(), cfg, "../examples/unused-var")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fixes) == 0 {
+		t.Error("expected some fixes to be applied")
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(pkgs))
+	}
+
+	pkg := pkgs[0]
+	if len(pkg.TypeErrors) != 0 || len(pkg.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v %v", pkg.TypeErrors, pkg.Errors)
+	}
+	if pkg.TypesInfo == nil {
+		t.Fatal("expected TypesInfo to be populated")
+	}
+
+	found := false
+	for _, file := range pkg.Syntax {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "c" {
+					found = pkg.TypesInfo.Uses[id] != nil
+				}
+			}
+			return true
+		})
+	}
+	if !found {
+		t.Error("expected TypesInfo to resolve the call to c()")
+	}
+}
+
+func TestLoadLenient_Unfixed(t *testing.T) {
+	cfg := &packages.Config{Mode: packages.NeedName | packages.NeedTypesInfo}
+	pkgs, _, err := LoadLenient(context.Background(), cfg, "../examples/no-progress")
+	if err == nil {
+		t.Fatal("expected an error for the error that golo couldn't fix")
+	}
+	if len(pkgs) != 1 || len(pkgs[0].Errors) == 0 {
+		t.Fatalf("expected the package to be returned with its errors, got %v", pkgs)
+	}
+}
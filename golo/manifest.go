@@ -0,0 +1,87 @@
+package golo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Manifest describes a package that is built by something other than go (like bazel or please),
+// as read from Options.FilesFrom. Relative paths are relative to the directory of the manifest.
+type Manifest struct {
+	// ImportPath is the import path of the package.
+	ImportPath string
+	// Files are the package's .go files (which may be in different directories).
+	Files []string
+	// GOPATH lists GOPATH-shaped directories (containing src/<import path>) that have the
+	// source of the packages that the package imports, other than the standard library.
+	GOPATH []string `json:",omitempty"`
+}
+
+// ReadManifest reads a manifest written as JSON.
+func ReadManifest(filename string) (*Manifest, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", filename, err)
+	}
+	if m.ImportPath == "" || len(m.Files) == 0 {
+		return nil, fmt.Errorf("invalid manifest %s: it must have an ImportPath and Files", filename)
+	}
+	dir := filepath.Dir(filename)
+	abs := func(path string) (string, error) {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		return filepath.Abs(path)
+	}
+	for i := range m.Files {
+		if m.Files[i], err = abs(m.Files[i]); err != nil {
+			return nil, err
+		}
+	}
+	for i := range m.GOPATH {
+		if m.GOPATH[i], err = abs(m.GOPATH[i]); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// useManifest builds the package in Options.FilesFrom instead of the packages in r.buildArgs.
+// Its files are linked into a GOPATH in r.tempDir (so that go sees exactly those files, and
+// golo fix writes through the links to the originals), and every go command golo runs uses
+// that GOPATH (followed by the manifest's) in GOPATH mode, so no go.mod is needed.
+func (r *Runner) useManifest() error {
+	m, err := ReadManifest(r.Options.FilesFrom)
+	if err != nil {
+		return err
+	}
+	gopath := filepath.Join(r.tempDir, "gopath")
+	dir := filepath.Join(gopath, "src", filepath.FromSlash(m.ImportPath))
+	if err := r.sandbox.mkdirAll(dir, 0o777); err != nil {
+		return err
+	}
+	for _, file := range m.Files {
+		if err := r.sandbox.symlink(file, filepath.Join(dir, filepath.Base(file))); err != nil {
+			return err
+		}
+	}
+	r.Options.Env = append(r.Options.Env, "GO111MODULE=off",
+		"GOPATH="+strings.Join(append([]string{gopath}, m.GOPATH...), string(filepath.ListSeparator)))
+
+	flags, pkgs := splitFlags(r.buildArgs)
+	if r.mode == "run" {
+		// there is no package argument, so everything is for the program
+		r.runArgs = append(pkgs, r.runArgs...)
+	} else if len(pkgs) > 0 {
+		return fmt.Errorf("packages can't be given with -files-from (got %s)", pkgs[0])
+	}
+	r.buildArgs = append(flags[:len(flags):len(flags)], m.ImportPath)
+	return nil
+}
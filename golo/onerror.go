@@ -0,0 +1,63 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// deferStmt returns the code that replaces statements containing an error that is deferred
+// until runtime. By default it panics with msg, but with Options.OnError set to "log" it
+// prints msg to stderr and returns from fn with the zero value of each result (or carries on,
+// if fn has none), so that one broken function doesn't stop a whole test binary.
+func (f *Fixer) deferStmt(file *ast.File, content []byte, fn *ast.FuncType, msg string) string {
+	code := fmt.Sprintf("panic(%#v)", msg)
+	if f.options.OnError != "log" || fn == nil {
+		return code
+	}
+	ret, ok := zeroReturn(file, content, fn)
+	if !ok {
+		return code
+	}
+	code = fmt.Sprintf("println(%#v)", "golo: "+msg)
+	if ret != "" {
+		code += "; " + ret
+	}
+	return code
+}
+
+// zeroReturn returns a return statement that returns the zero value of each of fn's results,
+// or "" if it has none. Named results are returned explicitly too, as they may be shadowed.
+func zeroReturn(file *ast.File, content []byte, fn *ast.FuncType) (string, bool) {
+	if fn.Results == nil || len(fn.Results.List) == 0 {
+		return "", true
+	}
+	values := []string{}
+	for _, field := range fn.Results.List {
+		t := exprString(content, file, field.Type)
+		if strings.ContainsAny(t, "\r\n") {
+			return "", false
+		}
+		for i := 0; i < len(field.Names) || i == 0; i++ {
+			values = append(values, "*new("+t+")")
+		}
+	}
+	return "return " + strings.Join(values, ", "), true
+}
+
+// enclosingFunc returns the type of the innermost function (or function literal) containing pos.
+func enclosingFunc(file *ast.File, pos token.Pos) *ast.FuncType {
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return n.Type
+		case *ast.FuncDecl:
+			return n.Type
+		}
+	}
+	return nil
+}
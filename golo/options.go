@@ -0,0 +1,124 @@
+package golo
+
+// DefaultMaxFixes is the default for Options.MaxFixes.
+const DefaultMaxFixes = 10
+
+// Options configures the optional behaviour of a Runner, and the Fixer it uses.
+type Options struct {
+	// ResumeDir is a directory in which progress is saved after each iteration of fixing.
+	// If it already contains progress for the same command, and none of the source files
+	// have changed, Prepare continues from there instead of starting again.
+	ResumeDir string
+	// NoCache stops golo reusing the fixes it made in an earlier run with the same command and
+	// the same errors (if none of the files they were made in have changed), and from caching
+	// the fixes it makes for the next run.
+	NoCache bool
+
+	// ReportFile, if set, is where a JSON report of the fixes is written.
+	ReportFile string
+	// GitHubReport, if set, is where GitHub Actions annotations for the fixes are written
+	// (as in ci mode). If it is "-" they are written to stdout.
+	GitHubReport string
+	// Baseline, if set, is a report from a previous run to compare this run's fixes to.
+	Baseline string
+	// MaxNewDeferrals is the number of deferrals that may be added compared to Baseline
+	// before Prepare fails. It is ignored if negative.
+	MaxNewDeferrals int
+
+	// FuzzyRename fixes references to undefined names by using a similarly named
+	// declaration from the same package, if there is exactly one.
+	FuzzyRename bool
+
+	// GuessMain makes golo run call the only exported function without parameters (if there is
+	// exactly one) from the func main it adds to a main package that doesn't have one.
+	GuessMain bool
+
+	// CheckDiscards fixes an unused variable that holds the error or bool result of a function
+	// whose name starts with Validate, Check, Verify or Must by panicking if the check fails,
+	// instead of renaming it to _ (which would skip the check the author meant to make).
+	CheckDiscards bool
+
+	// MoveTestHelpers fixes references from non-test files to declarations in the package's
+	// _test.go files by copying the declaration into the file that uses it. Otherwise these
+	// errors are not fixed, as deferring them would hide where the problem is.
+	MoveTestHelpers bool
+
+	// ExportShims fixes calls to unexported functions in other packages in the main module
+	// by adding an exported function that calls them to the other package (in the overlay
+	// only, so golo fix never writes it), and calling that instead.
+	ExportShims bool
+
+	// RunGenerators fixes errors in generated files by running the //go:generate directive
+	// that generates them (if the package has one), instead of deferring the error.
+	// The generator writes outside golo's sandbox (usually over the generated file), so this
+	// is off unless the user asks for it.
+	RunGenerators bool
+
+	// FixFormat fixes printf-style calls with a literal format string that doesn't match
+	// their arguments, by using %v for arguments of the wrong type and dropping extra arguments.
+	// This is only done in packages that golo is fixing.
+	FixFormat bool
+
+	// FixTags fixes struct tags that repeat a key (by dropping the later ones), and exported
+	// fields of a struct whose JSON names only differ in case (by giving the later one a json
+	// tag with a name of its own). This is only done in packages that golo is fixing.
+	FixTags bool
+
+	// FixLoopClosure fixes the loop variables that vet's loopclosure check reports as captured by
+	// a func literal (in a go or defer statement, or a parallel subtest), by copying them at the
+	// start of the loop body, if the loops share their variables between iterations (as they
+	// did before Go 1.22). This is only done in packages that golo is fixing.
+	FixLoopClosure bool
+
+	// Strict lists package patterns (like internal/payments/..., see matchPattern) that must
+	// build without golo's help: if any of the packages they match have errors, they are not
+	// fixed, and Prepare fails before anything is run. Tests of these packages are also strict.
+	Strict []string
+
+	// MaxFixes limits how many times golo goes round each of its fix loops: how many syntax
+	// errors it fixes in a file before giving up on it, and how many times it reloads the
+	// packages to fix the errors that are left. If zero, the loops go on until they stop making
+	// progress. New (and NewFixer) set it to DefaultMaxFixes.
+	MaxFixes int
+
+	// MinConfidence is the least confident kind of fix that may be used. Errors that would
+	// be fixed less confidently than this are deferred instead. All fixes are allowed if zero.
+	MinConfidence Confidence
+
+	// MaxMemory is an advisory limit (in bytes) on the memory golo uses. When it is
+	// exceeded every fixed file is moved to disk, not just the large ones.
+	// It is ignored if zero.
+	MaxMemory int64
+
+	// Offline stops the go commands that golo runs from downloading modules
+	// (by setting GOPROXY=off, and removing -mod=mod from GOFLAGS).
+	Offline bool
+	// Env is added to the environment of the go commands that golo runs (and that
+	// packages are loaded with), in the form "KEY=value".
+	Env []string
+
+	// FilesFrom, if set, is a manifest (see Manifest) of the package to fix and run, for trees
+	// that are built by something other than go (like bazel). The package is built in GOPATH
+	// mode instead of as part of a module, so no packages should be passed to the Runner.
+	FilesFrom string
+
+	// OnError is what code with an error that is deferred until runtime does when it is run:
+	// "panic" (the default), or "log" to print the error to stderr and return from the function.
+	OnError string
+
+	// Diff makes golo print a diff of the fixes instead of running the program (or tests),
+	// or in fix mode instead of writing them to the source files.
+	Diff bool
+	// AllowPanics lets fix mode write files in which errors were deferred (i.e. replaced
+	// with code that panics). Otherwise only files that were fixed without deferring are written.
+	AllowPanics bool
+	// JSON makes golo write each fix to stderr as a JSON object (a Fix) as it is made, for
+	// editors to read. The usual output is unchanged.
+	JSON bool
+
+	// ArtifactDir, if set, is a directory to copy the built binary (or test binary) to.
+	ArtifactDir string
+	// Keep leaves golo's temporary directory (with the binary in it) in place when the Runner is
+	// closed, so that it can be inspected, and says where the overlay is.
+	Keep bool
+}
@@ -0,0 +1,114 @@
+package golo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// install moves the binary that was built while fixing to where go build would have written it
+// (the -o flag, or a file named after the package in the current directory), so that build mode
+// doesn't build it again. Nothing is written for packages that aren't commands (unless -o is given),
+// as go build only checks that they compile.
+func (r *Runner) install() error {
+	dst := outputFlag(r.buildArgs)
+	if dst == "" || strings.HasSuffix(dst, "/") || isDir(dst) {
+		name, err := r.outputName()
+		if err != nil || name == "" {
+			return err
+		}
+		dst = filepath.Join(dst, name)
+	}
+	r.sandbox.allow(dst)
+	// go build creates the directory if it doesn't exist yet
+	if dir := filepath.Dir(dst); !isDir(dir) {
+		r.sandbox.allow(dir)
+		if err := r.sandbox.mkdirAll(dir, 0o777); err != nil {
+			return err
+		}
+	}
+	// the temporary directory may be on a different device to the output
+	if err := r.sandbox.rename(r.exeFile, dst); err != nil {
+		if err := r.copyBinary(dst); err != nil {
+			return err
+		}
+	}
+	// the same permissions go build gives binaries (the copy is created with the umask applied)
+	return r.sandbox.chmod(dst, 0o755)
+}
+
+// copyBinary copies r.exeFile to dst.
+func (r *Runner) copyBinary(dst string) error {
+	in, err := os.Open(r.exeFile)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := r.sandbox.openFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o777)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// outputName returns the name that go build gives the binary it builds for r.buildArgs when
+// there is no -o flag, or "" if it wouldn't write one (because the package isn't a command).
+func (r *Runner) outputName() (string, error) {
+	flags, pkgs := splitFlags(r.buildArgs)
+	args := append(append([]string{"list", "-f", "{{.Name}} {{.ImportPath}}"}, withoutOutputFlag(flags)...), pkgs...)
+	out, err := r.goCommand(args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("go list failed: %w", err)
+	}
+	// like go build, nothing is written when building more than one package
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 || fields[0] != "main" {
+		return "", nil
+	}
+	env, err := r.goCommand("env", "GOEXE").Output()
+	if err != nil {
+		return "", fmt.Errorf("go env failed: %w", err)
+	}
+
+	// go build names the binary after the first file (for go build main.go), or the last element of
+	// the import path (or the one before it, if that is a major version like v2)
+	name := path.Base(fields[1])
+	if len(pkgs) > 0 && isGoFile(pkgs[0]) {
+		name = filepath.Base(pkgs[0])
+		name = name[:len(name)-len(".go")]
+	} else if isMajorVersion(name) && path.Dir(fields[1]) != "." {
+		name = path.Base(path.Dir(fields[1]))
+	}
+	return name + strings.TrimSpace(string(env)), nil
+}
+
+// isMajorVersion returns true for the major version suffix of a module path, like v2.
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' || s[1] == '0' || s == "v1" {
+		return false
+	}
+	for _, c := range s[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isGoFile returns true if arg names a Go file rather than a package. The .go suffix is matched
+// case-insensitively, as file names are on Windows and macOS.
+func isGoFile(arg string) bool {
+	return len(arg) > len(".go") && strings.EqualFold(arg[len(arg)-len(".go"):], ".go")
+}
+
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
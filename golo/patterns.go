@@ -0,0 +1,118 @@
+package golo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PatternError is a package pattern that golo was given, but that didn't match any packages.
+type PatternError struct {
+	Pattern string
+	Err     string
+}
+
+func (e PatternError) Error() string {
+	return e.Pattern + ": " + e.Err
+}
+
+// listFlags are the build flags that change which packages a pattern matches.
+var listFlags = map[string]bool{"C": true, "mod": true, "modfile": true, "tags": true}
+
+// expandPatterns checks each package pattern in r.buildArgs with go list, and removes the
+// ones that don't match any packages (so that they don't stop golo from fixing the others).
+// The patterns that were removed are kept in r.unmatched, and reported as errors.
+func (r *Runner) expandPatterns() error {
+	flags, pkgs := splitFlags(r.buildArgs)
+	// go test accepts test flags after the packages
+	rest := []string{}
+	for i, arg := range pkgs {
+		if strings.HasPrefix(arg, "-") {
+			pkgs, rest = pkgs[:i], pkgs[i:]
+			break
+		}
+	}
+	// files are checked by the go command itself
+	if len(pkgs) == 0 || isGoFile(pkgs[0]) {
+		return nil
+	}
+
+	matched := []string{}
+	for _, pattern := range pkgs {
+		if err := r.listPattern(flags, pattern); err != nil {
+			r.unmatched = append(r.unmatched, *err)
+			continue
+		}
+		matched = append(matched, pattern)
+	}
+	if len(matched) == 0 {
+		return r.unmatched[0]
+	}
+	if len(r.unmatched) == 0 {
+		return nil
+	}
+	for _, e := range r.unmatched {
+		fmt.Fprintf(r.log(r.stdout()), "golo: skipping %v\n", e)
+	}
+	r.buildArgs = append(append(flags[:len(flags):len(flags)], matched...), rest...)
+	return nil
+}
+
+// listPattern returns an error if pattern doesn't match any packages with Go files.
+// Packages that are broken are still matched, as golo may be able to fix them.
+func (r *Runner) listPattern(flags []string, pattern string) *PatternError {
+	args := []string{"list", "-e", "-json"}
+	for i := 0; i < len(flags); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(flags[i], "-"), "=")
+		if !listFlags[name] {
+			if !hasValue && buildFlagsWithValue[name] {
+				i++
+			}
+			continue
+		}
+		args = append(args, flags[i])
+		if !hasValue && i+1 < len(flags) {
+			args = append(args, flags[i+1])
+			i++
+		}
+	}
+	cmd := goCommand(r.context(), r.Options, append(args, pattern)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimPrefix(strings.TrimSpace(stderr.String()), "go: ")
+		if msg == "" {
+			msg = err.Error()
+		}
+		return &PatternError{Pattern: pattern, Err: msg}
+	}
+
+	var first *PatternError
+	d := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var pkg struct {
+			GoFiles, CgoFiles, TestGoFiles, XTestGoFiles, IgnoredGoFiles, InvalidGoFiles []string
+			Error                                                                        *struct{ Err string }
+		}
+		if err := d.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return &PatternError{Pattern: pattern, Err: err.Error()}
+		}
+		// files excluded by build constraints still count, as go build explains those better
+		if len(pkg.GoFiles)+len(pkg.CgoFiles)+len(pkg.TestGoFiles)+len(pkg.XTestGoFiles)+
+			len(pkg.IgnoredGoFiles)+len(pkg.InvalidGoFiles) > 0 {
+			return nil
+		}
+		if first == nil && pkg.Error != nil {
+			first = &PatternError{Pattern: pattern, Err: pkg.Error.Err}
+		}
+	}
+	if first == nil {
+		first = &PatternError{Pattern: pattern, Err: "matched no packages"}
+	}
+	return first
+}
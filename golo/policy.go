@@ -0,0 +1,138 @@
+package golo
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Policy is how golo treats the errors in a package.
+type Policy string
+
+const (
+	// Lenient packages have their errors fixed (or deferred) as usual.
+	Lenient Policy = "lenient"
+	// Strict packages (those that match Options.Strict) must build without golo's help:
+	// an error in one is never fixed, and fails Prepare before anything is run.
+	Strict Policy = "strict"
+)
+
+// StrictError is an error in a package that matches Options.Strict.
+type StrictError struct {
+	// Package is the import path of the package (or the package under test, for its tests).
+	Package string
+	Pos     string
+	Msg     string
+	Policy  Policy
+}
+
+func (e StrictError) Error() string {
+	if e.Pos == "" || e.Pos == "-" {
+		return e.Msg
+	}
+	return e.Pos + ": " + e.Msg
+}
+
+// StrictPackagesError is returned by Prepare when packages that match Options.Strict have errors.
+type StrictPackagesError struct {
+	Errors []StrictError
+}
+
+func (e *StrictPackagesError) Error() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "not fixing %d %s in strict packages:", len(e.Errors), plural(len(e.Errors), "error", "errors"))
+	for _, err := range e.Errors {
+		fmt.Fprintf(b, "\n%s (in %s)", err.Error(), err.Package)
+	}
+	return b.String()
+}
+
+// policy returns the policy for the package with the given import path.
+func (f *Fixer) policy(path string) Policy {
+	for _, pattern := range f.options.Strict {
+		if matchPattern(pattern, path) {
+			return Strict
+		}
+	}
+	return Lenient
+}
+
+// checkStrict returns a *StrictPackagesError if any of pkgs that match Options.Strict have
+// errors (or had syntax errors fixed while loading them, which happens before this is called).
+// applied is the number of fixes that had been made before pkgs were loaded.
+func (f *Fixer) checkStrict(pkgs []*packages.Package, applied int) error {
+	if len(f.options.Strict) == 0 {
+		return nil
+	}
+	errs := []StrictError{}
+	seen := map[StrictError]bool{}
+	add := func(e StrictError) {
+		// the tests of a package repeat its errors
+		if !seen[e] {
+			seen[e] = true
+			errs = append(errs, e)
+		}
+	}
+	for _, pkg := range pkgs {
+		path := importPath(pkg)
+		if f.policy(path) != Strict {
+			continue
+		}
+		files := map[string]bool{}
+		for _, file := range pkg.CompiledGoFiles {
+			files[file] = true
+		}
+		for _, fix := range f.Applied[applied:] {
+			if files[fix.Pos.Filename] {
+				add(StrictError{Package: path, Pos: relativePos(fix.Pos.String()), Msg: fix.Msg, Policy: Strict})
+			}
+		}
+		for _, e := range uniqueErrors(pkg.Errors) {
+			add(StrictError{Package: path, Pos: relativePos(e.Pos), Msg: e.Msg, Policy: Strict})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &StrictPackagesError{Errors: errs}
+}
+
+// relativePos returns pos (file:line:col) with the file relative to the current directory.
+func relativePos(pos string) string {
+	file, line, col := splitPos(pos)
+	if file == "" || file == "-" || line == 0 {
+		return pos
+	}
+	return fmt.Sprintf("%s:%d:%d", filepath.ToSlash(relativePath(file)), line, col)
+}
+
+// importPath returns the import path of pkg, or for a test variant the import path of the
+// package under test (so the tests of a strict package are also strict). The Fixer doesn't
+// load the packages' names, so it is found from their IDs.
+func importPath(pkg *packages.Package) string {
+	// the IDs of a's test variants are "a [a.test]" and "a_test [a.test]" (for its external
+	// tests), and the test binary's is "a.test"
+	if _, test, ok := strings.Cut(pkg.ID, " ["); ok {
+		return strings.TrimSuffix(strings.TrimSuffix(test, "]"), ".test")
+	}
+	return strings.TrimSuffix(pkg.ID, ".test")
+}
+
+// matchPattern returns true if path matches pattern, in which "..." matches any string (as
+// in go list) and "*" matches any string without a "/". Like go list, "a/..." also matches a.
+// The pattern can match the whole import path, or just the last elements of it (so that
+// internal/payments/... matches example.com/m/internal/payments).
+func matchPattern(pattern, path string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	re := regexp.QuoteMeta(pattern)
+	re = strings.ReplaceAll(re, `\.\.\.`, `.*`)
+	re = strings.ReplaceAll(re, `\*`, `[^/]*`)
+	if strings.HasSuffix(re, `/.*`) {
+		re = strings.TrimSuffix(re, `/.*`) + `(/.*)?`
+	}
+	ok, err := regexp.MatchString(`^(.*/)?`+re+`$`, path)
+	return ok && err == nil
+}
@@ -0,0 +1,41 @@
+package golo
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestMatchPattern(t *testing.T) {
+	for _, eg := range []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"internal/payments/...", "example.com/m/internal/payments", true},
+		{"internal/payments/...", "example.com/m/internal/payments/stripe", true},
+		{"./internal/payments/...", "example.com/m/internal/payments", true},
+		{"internal/payments/...", "example.com/m/internal/paymentsv2", false},
+		{"internal/payments", "example.com/m/internal/payments/stripe", false},
+		{"payments", "example.com/m/internal/xpayments", false},
+		{"example.com/m/...", "example.com/m/internal/payments", true},
+		{"internal/*/stripe", "example.com/m/internal/payments/stripe", true},
+		{"internal/*", "example.com/m/internal/payments/stripe", false},
+	} {
+		if got := matchPattern(eg.pattern, eg.path); got != eg.match {
+			t.Errorf("matchPattern(%q, %q): expected %v, got %v", eg.pattern, eg.path, eg.match, got)
+		}
+	}
+}
+
+func TestImportPath(t *testing.T) {
+	for id, expected := range map[string]string{
+		"example.com/m/pay":                               "example.com/m/pay",
+		"example.com/m/pay [example.com/m/pay.test]":      "example.com/m/pay",
+		"example.com/m/pay_test [example.com/m/pay.test]": "example.com/m/pay",
+		"example.com/m/pay.test":                          "example.com/m/pay",
+	} {
+		if got := importPath(&packages.Package{ID: id}); got != expected {
+			t.Errorf("importPath(%q): expected %q, got %q", id, expected, got)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package golo
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// ReportVersion is the version of the Report format written by this version of golo.
+// It is incremented whenever a change is made that older versions could not read.
+const ReportVersion = 1
+
+// Report lists the fixes that golo made, so that they can be compared across runs.
+// Filenames are relative to the directory golo was run in.
+type Report struct {
+	Version int
+	Fixes   []Fix
+	// Artifact is the binary that was built, if Options.ArtifactDir was set.
+	Artifact *Artifact `json:",omitempty"`
+	// Unmatched lists the package patterns that didn't match any packages.
+	Unmatched []PatternError `json:",omitempty"`
+	// Strict lists the errors in packages that match Options.Strict, which golo didn't fix.
+	Strict []StrictError `json:",omitempty"`
+	// Excluded lists the packages whose tests weren't run (in test mode), because golo could
+	// not fix or defer their errors.
+	Excluded []string `json:",omitempty"`
+}
+
+// newReport returns a report of the given fixes.
+func newReport(fixes []Fix) *Report {
+	wd, _ := os.Getwd()
+	report := &Report{Version: ReportVersion, Fixes: []Fix{}}
+	for _, fix := range fixes {
+		if rel, err := filepath.Rel(wd, fix.Pos.Filename); err == nil && wd != "" {
+			fix.Pos.Filename = filepath.ToSlash(rel)
+		}
+		if len(fix.DependsOn) > 0 {
+			deps := make([]token.Position, len(fix.DependsOn))
+			for i, dep := range fix.DependsOn {
+				deps[i] = dep
+				deps[i].Filename = fix.Pos.Filename
+			}
+			fix.DependsOn = deps
+		}
+		report.Fixes = append(report.Fixes, fix)
+	}
+	return report
+}
+
+// Deferrals returns the fixes that defer errors until runtime.
+func (r *Report) Deferrals() []Fix {
+	deferrals := []Fix{}
+	for _, fix := range r.Fixes {
+		if fix.Kind == Deferred {
+			deferrals = append(deferrals, fix)
+		}
+	}
+	return deferrals
+}
+
+// ReadReport reads a report written by WriteReport.
+func ReadReport(filename string) (*Report, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{}
+	if err := json.Unmarshal(content, r); err != nil {
+		return nil, fmt.Errorf("invalid report %s: %w", filename, err)
+	}
+	if r.Version > ReportVersion {
+		return nil, fmt.Errorf("report %s was written by a newer version of golo (version %d)", filename, r.Version)
+	}
+	return r, nil
+}
+
+// WriteReport writes the report to filename as JSON.
+func (r *Report) WriteReport(filename string) error {
+	content, err := r.marshal()
+	if err != nil {
+		return err
+	}
+	return newSandbox(filename).writeFile(filename, content, 0o666)
+}
+
+func (r *Report) marshal() ([]byte, error) {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+// ReportDiff is the difference between the deferrals in two reports, as used both to compare
+// a run with Options.Baseline, and (by DeltaSink) to compare each run with the last.
+type ReportDiff struct {
+	Added   []Fix
+	Removed []Fix
+	// Moved contains deferrals (from the newer report) whose line changed.
+	Moved []Fix
+}
+
+var rePosition = regexp.MustCompile(`\.go:\d+(:\d+)?`)
+
+// deferralKey identifies a deferral independently of its line number
+func deferralKey(fix Fix) string {
+	msg := strings.Join(strings.Fields(fix.Msg), " ")
+	return filepath.ToSlash(fix.Pos.Filename) + "\x00" + rePosition.ReplaceAllString(msg, ".go")
+}
+
+// DiffReports compares the deferrals in two reports.
+// Deferrals are matched by filename and message (ignoring any positions in the message), so
+// that edits elsewhere in the file which move the error to a different line don't count as a
+// change. If the same error is deferred more than once in a file, they are matched in order.
+func DiffReports(old, new *Report) ReportDiff {
+	byKey := func(fixes []Fix) map[string][]Fix {
+		m := map[string][]Fix{}
+		for _, fix := range fixes {
+			m[deferralKey(fix)] = append(m[deferralKey(fix)], fix)
+		}
+		for _, fixes := range m {
+			slices.SortFunc(fixes, func(a, b Fix) bool { return a.Pos.Line < b.Pos.Line })
+		}
+		return m
+	}
+
+	diff := ReportDiff{}
+	before := byKey(old.Deferrals())
+	after := byKey(new.Deferrals())
+
+	for _, fix := range new.Deferrals() {
+		key := deferralKey(fix)
+		if after[key] == nil {
+			continue
+		}
+		olds, news := before[key], after[key]
+		for i, fix := range news {
+			if i >= len(olds) {
+				diff.Added = append(diff.Added, fix)
+			} else if olds[i].Pos.Line != fix.Pos.Line {
+				diff.Moved = append(diff.Moved, fix)
+			}
+		}
+		if len(olds) > len(news) {
+			diff.Removed = append(diff.Removed, olds[len(news):]...)
+		}
+		after[key] = nil
+	}
+
+	for _, fix := range old.Deferrals() {
+		key := deferralKey(fix)
+		if _, ok := after[key]; !ok {
+			diff.Removed = append(diff.Removed, fix)
+		}
+	}
+	return diff
+}
+
+// summary returns a one-line summary of the fixes in the report, compared to baseline if given.
+func (r *Report) summary(filesChanged int, baseline *Report) string {
+	s := fmt.Sprintf("golo: %d %s changed, %d %s", filesChanged, plural(filesChanged, "file", "files"),
+		len(r.Deferrals()), plural(len(r.Deferrals()), "deferral", "deferrals"))
+	if baseline != nil {
+		diff := DiffReports(baseline, r)
+		s += fmt.Sprintf(" (+%d -%d since baseline)", len(diff.Added), len(diff.Removed))
+	}
+	return s
+}
+
+// String summarizes the diff on one line, e.g. "1 new deferral (server.go:88), 2 resolved".
+func (d ReportDiff) String() string {
+	if len(d.Added)+len(d.Removed) == 0 {
+		return "no new or resolved deferrals"
+	}
+	parts := []string{}
+	if len(d.Added) > 0 {
+		positions := make([]string, len(d.Added))
+		for i, fix := range d.Added {
+			positions[i] = fmt.Sprintf("%s:%d", fix.Pos.Filename, fix.Pos.Line)
+		}
+		parts = append(parts, fmt.Sprintf("%d new %s (%s)", len(d.Added), plural(len(d.Added), "deferral", "deferrals"), strings.Join(positions, ", ")))
+	}
+	if len(d.Removed) > 0 {
+		parts = append(parts, fmt.Sprintf("%d resolved", len(d.Removed)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,107 @@
+package golo
+
+import (
+	"bytes"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffReports(t *testing.T) {
+	fix := func(file string, line int, msg string) Fix {
+		return Fix{Pos: token.Position{Filename: file, Line: line}, Msg: msg, Kind: Deferred}
+	}
+
+	old := &Report{Fixes: []Fix{
+		fix("a.go", 10, "undefined: foo"),
+		fix("a.go", 20, "undefined: bar"),
+		fix("b.go", 5, "undefined: baz"),
+		{Pos: token.Position{Filename: "a.go", Line: 3}, Msg: `"fmt" imported and not used`, Kind: UnusedImport},
+	}}
+	new := &Report{Fixes: []Fix{
+		// moved down by an edit earlier in the file
+		fix("a.go", 14, "undefined: foo"),
+		fix("a.go", 24, "undefined: bar"),
+		// a second occurrence of the same error is new
+		fix("a.go", 30, "undefined:  bar"),
+		fix("c.go", 7, "undefined: qux"),
+	}}
+
+	diff := DiffReports(old, new)
+
+	if !reflect.DeepEqual(diff.Added, new.Fixes[2:4]) {
+		t.Errorf("unexpected added: %v", diff.Added)
+	}
+	if !reflect.DeepEqual(diff.Removed, old.Fixes[2:3]) {
+		t.Errorf("unexpected removed: %v", diff.Removed)
+	}
+	if !reflect.DeepEqual(diff.Moved, new.Fixes[0:2]) {
+		t.Errorf("unexpected moved: %v", diff.Moved)
+	}
+
+	if s := new.summary(2, old); s != "golo: 2 files changed, 4 deferrals (+2 -1 since baseline)" {
+		t.Errorf("unexpected summary: %s", s)
+	}
+}
+
+func TestDiffReports_Positions(t *testing.T) {
+	fix := func(line int, msg string) Fix {
+		return Fix{Pos: token.Position{Filename: "a.go", Line: line}, Msg: msg, Kind: Deferred}
+	}
+	// the message mentions another position, which moved along with the error
+	old := &Report{Fixes: []Fix{fix(12, "x redeclared in this block\n\tother declaration of x at a.go:10:2")}}
+	new := &Report{Fixes: []Fix{fix(15, "x redeclared in this block\n\tother declaration of x at a.go:13:2")}}
+
+	diff := DiffReports(old, new)
+	if len(diff.Added)+len(diff.Removed) != 0 || len(diff.Moved) != 1 {
+		t.Errorf("expected the deferral to have moved, got %+v", diff)
+	}
+	if s := diff.String(); s != "no new or resolved deferrals" {
+		t.Errorf("unexpected summary: %s", s)
+	}
+}
+
+func TestDeltaSink(t *testing.T) {
+	fix := func(file string, line int, msg string) Fix {
+		return Fix{Pos: token.Position{Filename: file, Line: line}, Msg: msg, Kind: Deferred}
+	}
+	out := &bytes.Buffer{}
+	s := NewDeltaSink(out)
+	r := New("run", false, nil)
+
+	// the first run is reported as usual
+	s.Attach(r)
+	first := &Report{Fixes: []Fix{fix("a.go", 10, "undefined: foo"), fix("a.go", 20, "undefined: bar"), fix("b.go", 5, "undefined: baz")}}
+	if err := s.Report(&Outcome{Report: first, FilesChanged: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 || r.FixLog != nil {
+		t.Errorf("expected nothing extra to be reported for the first run, got %q", out)
+	}
+
+	// later runs only list the changes
+	r = New("run", false, nil)
+	s.Attach(r)
+	second := &Report{Fixes: []Fix{fix("a.go", 12, "undefined: foo"), fix("server.go", 88, "undefined: qux")}}
+	if err := s.Report(&Outcome{Report: second, FilesChanged: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if r.FixLog == nil {
+		t.Errorf("expected the fixes not to be logged after the first run")
+	}
+	if got := strings.Split(strings.TrimSpace(out.String()), "\n"); !reflect.DeepEqual(got, []string{
+		"golo: new deferral: server.go:88: undefined: qux",
+		"golo: resolved: a.go:20: undefined: bar",
+		"golo: resolved: b.go:5: undefined: baz",
+		"golo: 1 new deferral (server.go:88), 2 resolved",
+	}) {
+		t.Errorf("unexpected delta:\n%s", out)
+	}
+
+	out.Reset()
+	s.WriteList(out)
+	if want := "golo: a.go:12: undefined: foo\ngolo: server.go:88: undefined: qux\ngolo: 2 files changed, 2 deferrals\n"; out.String() != want {
+		t.Errorf("unexpected list:\n%s", out)
+	}
+}
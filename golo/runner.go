@@ -2,36 +2,97 @@ package golo
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
+	"golang.org/x/exp/slices"
 	"golang.org/x/tools/go/packages"
 )
 
 // Runner runs go run/go build or go test with syntax errors and type errors
 // deferred until runtime.
 type Runner struct {
+	// Options can be set before calling Prepare to configure optional behaviour.
+	Options Options
+
+	// Stdin, Stdout and Stderr are used by the program (or tests) that Run runs, and by golo
+	// for its own output. If nil, golo's own stdin, stdout and stderr are used.
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	// Log, if set, is where golo writes its own messages (what it fixed, and the errors it
+	// couldn't) instead of Stdout and Stderr.
+	Log io.Writer
+	// FixLog, if set, is where golo writes each fix (and the notes about them) as it makes them,
+	// instead of Log, for callers that report the fixes another way (see DeltaSink).
+	FixLog io.Writer
+
 	mode    string
 	verbose bool
 
 	buildArgs []string
-	runArgs   []string
+	build     *buildConfig // the configuration buildArgs are built with, once Prepare has started
+	// runArgs are passed to the program in run mode, and are the flags for the test binary in test mode.
+	runArgs []string
 
 	built       bool
 	fixed       map[string][]byte
+	spilled     map[string]spilledFile
+	tempDir     string
+	sandbox     *sandbox
 	overlays    packages.OverlayJSON
+	overlaid    map[string][sha256.Size]byte // the hash of what was last written to each overlay file
 	overlayFile string
+	overlayDir  string // where the overlay files are written (see cacheDir)
+	fixCache    string // where the fixes are cached between runs (see fixCacheFile), if anywhere
 	exeFile     string
 	cleanup     []string
+	kept        bool // Close has said where the temporary files were kept
+	applied     []Fix
+	artifact    *Artifact
+	unfixed     []packages.Error
+	unmatched   []PatternError
+	strict      []StrictError
+	tests       []string // the packages being tested, in test mode
+	brokenTests []string // the packages whose tests didn't build in the last probe (if tests has more than one)
+	excluded    []string // the packages whose tests aren't run, as golo couldn't fix them
+	stuck       []string // the packages that golo gave up on
+	limited     bool     // golo gave up because it reached Options.MaxFixes
+	noBuildJSON bool     // the go command is older than go1.24, so doesn't support go build -json
+	probed      []byte   // what go printed in the last probe (see fixCacheFile)
+	mains       []string // the packages being run, if they aren't main packages (see fixMain)
+	originals   map[string]string
+	added       []Fix
+	sinks       []Sink
+
+	// onCheckpoint is called after progress is saved (used to simulate interruptions in tests)
+	onCheckpoint func() error
+	// ctx is the context passed to PrepareContext or RunContext (see context)
+	ctx context.Context
+
+	// running is the command that Run is running (if any), and exited is closed once it exits
+	mu      sync.Mutex
+	running *exec.Cmd
+	exited  chan struct{}
 }
 
 // New returns a runner with the given args.
 // These args should be what you might pass to a go subcommand of the same name as "mode"
-// Valid modes are "run", "build" and "test".
+// Valid modes are "run", "build" and "test", "ci" (which takes package patterns and
+// reports what golo would fix as GitHub annotations instead of running anything), and
+// "fix" (which writes the fixes to the source files instead of building them).
 // If verbose, more output will be generated (mostly useful for debugging golo itself)
 func New(mode string, verbose bool, args []string) *Runner {
 	r := &Runner{
@@ -39,26 +100,34 @@ func New(mode string, verbose bool, args []string) *Runner {
 		verbose:   verbose,
 		buildArgs: args,
 		fixed:     map[string][]byte{},
+		spilled:   map[string]spilledFile{},
+		overlaid:  map[string][sha256.Size]byte{},
 
 		overlays: packages.OverlayJSON{Replace: map[string]string{}},
 		built:    false,
 	}
+	r.Options.MaxNewDeferrals = -1
+	r.Options.MaxFixes = DefaultMaxFixes
 
 	if mode == "run" {
-		if strings.HasSuffix(args[0], ".go") {
-			i := 0
+		flags, args := splitFlags(args)
+		i := 0
+		if len(args) > 0 && isGoFile(args[0]) {
 			for i < len(args) {
-				if !strings.HasSuffix(args[i], ".go") {
+				if !isGoFile(args[i]) {
 					break
 				}
 				i++
 			}
-			r.buildArgs = args[0:i]
-			r.runArgs = args[i:]
-		} else {
-			r.buildArgs = args[0:1]
-			r.runArgs = args[1:]
+		} else if len(args) > 0 {
+			i = 1
 		}
+		r.buildArgs = append(flags[:len(flags):len(flags)], args[0:i]...)
+		r.runArgs = args[i:]
+	}
+	// test flags (like -run) are only passed to go test when it runs the tests
+	if mode == "test" {
+		r.buildArgs, r.runArgs = splitTestFlags(args)
 	}
 
 	return r
@@ -66,10 +135,143 @@ func New(mode string, verbose bool, args []string) *Runner {
 
 // Prepare attempts the build, and (best-effort) fixes any build errors
 func (r *Runner) Prepare() error {
+	return r.PrepareContext(context.Background())
+}
+
+// PrepareContext is like Prepare, but stops once ctx is done: the go commands it is running
+// are killed, golo's temporary files are removed, and it returns ctx.Err().
+func (r *Runner) PrepareContext(ctx context.Context) (err error) {
+	r.ctx = ctx
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			r.removeTemp()
+			err = ctx.Err()
+		}
+	}()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// everything golo writes (other than the outputs the user asks for) goes in tempDir
+	if r.tempDir == "" {
+		dir, err := MkdirTemp("golo-*")
+		if err != nil {
+			return err
+		}
+		r.tempDir = dir
+		r.cleanup = append(r.cleanup, dir)
+		github := r.Options.GitHubReport
+		if github == "-" {
+			github = ""
+		}
+		// overlays outlive the run (unless there is nowhere to cache them), so that the go command
+		// sees the same files when the same fixes are made again
+		r.overlayDir = cacheDir("overlays")
+		if r.overlayDir == "" {
+			r.overlayDir = dir
+		}
+		// golo fix writes the fixes to the source files, so there is nothing to reuse, and a
+		// checkpoint is already reused by -resume
+		if !r.Options.NoCache && r.mode != "fix" && r.Options.ResumeDir == "" {
+			r.fixCache = cacheDir("fixes")
+		}
+		r.sandbox = newSandbox(dir, r.overlayDir, r.fixCache, r.Options.ResumeDir, r.Options.ReportFile, github, r.Options.ArtifactDir)
+		if r.sandbox.mkdirAll(r.overlayDir, 0o777) != nil {
+			r.overlayDir = dir
+		}
+		if r.fixCache != "" && r.sandbox.mkdirAll(r.fixCache, 0o777) != nil {
+			r.fixCache = ""
+		}
+		if err := r.sandbox.mkdirAll(filepath.Join(dir, "spill"), 0o777); err != nil {
+			return err
+		}
+		if r.Options.FilesFrom != "" {
+			if err := r.useManifest(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := r.expandPatterns(); err != nil {
+		return err
+	}
+	fixer := NewFixer(r.mode, r.verbose, r.fixed)
+	fixer.options = r.Options
+	flags, _ := splitFlags(r.buildArgs)
+	r.build = newBuildConfig(r.Options, flags)
+	fixer.build = r.build
+	fixer.out = r.log(r.stdout())
+	if r.FixLog != nil {
+		fixer.out = r.FixLog
+	}
+	fixer.sandbox = r.sandbox
+	fixer.spillDir = filepath.Join(r.tempDir, "spill")
+	fixer.spilled = r.spilled
+	if r.mode == "fix" {
+		fixer.originals = map[string]string{}
+	}
+	if r.Options.JSON {
+		e := json.NewEncoder(r.stderr())
+		fixer.onFix = func(fix Fix) { e.Encode(fix) }
+	}
+	// so that the diff can be piped to git apply or patch
+	if r.Options.Diff && r.FixLog == nil {
+		fixer.out = r.log(r.stderr())
+	}
+	if r.mode == "ci" {
+		err = r.analyze(fixer)
+	} else {
+		err = r.prepare(fixer)
+	}
+	r.applied = fixer.Applied
+	r.limited = fixer.limited
+	r.originals = fixer.originals
+	// the errors in strict packages are reported, but nothing is run
+	var strict *StrictPackagesError
+	if errors.As(err, &strict) {
+		r.strict = strict.Errors
+		if err := r.summarize(); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if r.artifact, err = r.saveArtifact(); err != nil {
+		return err
+	}
+	return r.summarize()
+}
+
+func (r *Runner) prepare(fixer *Fixer) error {
 	fixed := map[string]bool{}
+	cached := "" // the file in the fix cache for this run, once the errors are known
+
+	if dir := r.Options.ResumeDir; dir != "" {
+		cp, err := r.loadCheckpoint(dir)
+		if err != nil {
+			return err
+		}
+		if cp != nil {
+			fmt.Fprintf(r.log(r.stdout()), "golo: resuming from %s (%d errors already fixed)\n", dir, len(cp.Applied))
+			if err := r.restoreCheckpoint(filepath.Join(dir, checkpointFile), cp, fixer); err != nil {
+				return err
+			}
+			fixer.Applied = cp.Applied
+		}
+		fixer.checkpoint = func() error {
+			if err := r.saveCheckpoint(dir, fixer); err != nil {
+				return err
+			}
+			if r.onCheckpoint != nil {
+				return r.onCheckpoint()
+			}
+			return nil
+		}
+	}
 
-	fixer := &Fixer{mode: r.mode, verbose: r.verbose, Fixed: r.fixed}
 	for {
+		if err := r.context().Err(); err != nil {
+			return err
+		}
 		toFix, err := r.getBrokenPackages()
 		if err != nil {
 			return err
@@ -77,44 +279,156 @@ func (r *Runner) Prepare() error {
 
 		if len(toFix) == 0 {
 			r.built = true
+			if cached != "" {
+				r.saveFixCache(cached, fixer)
+			}
 			return nil
 		}
+		// the cache is keyed by the errors, so it is only checked once go has reported them
+		if r.fixCache != "" && cached == "" {
+			cached = r.fixCacheFile()
+			loaded, err := r.loadFixCache(cached, fixer)
+			if err != nil {
+				return err
+			}
+			if loaded {
+				continue
+			}
+		}
 
+		// packages that golo has already tried to fix are left as they are, so that one package
+		// golo can't fix doesn't stop it fixing the others
+		stuck := slices.Clone(toFix)
 		clidx := -1
-
-		for i, pkg := range toFix {
+		untried := toFix[:0]
+		for _, pkg := range toFix {
 			if fixed[pkg] {
-				return nil
+				continue
 			}
 			if pkg == "command-line-arguments" {
-				clidx = i
+				clidx = len(untried)
 			}
 			fixed[pkg] = true
+			untried = append(untried, pkg)
+		}
+		toFix = untried
+		if len(toFix) == 0 {
+			r.stuck = stuck
+			// as a last resort, the tests that still don't build are skipped so the others can run
+			if r.mode == "test" && r.excludeBrokenTests() {
+				continue
+			}
+			return nil
+		}
+		// each package is only tried once, so the errors that are left after each attempt
+		// are all shown if golo gives up
+		for _, pkg := range r.mains {
+			fixer.mains[pkg] = true
 		}
 		if clidx > -1 {
 			toFix = append(toFix[0:clidx:clidx], toFix[clidx+1:]...)
-			if err := fixer.Fix(r.buildArgs...); err != nil {
+			_, files := splitFlags(r.buildArgs)
+			if err := fixer.FixContext(r.context(), files...); err != nil {
 				return err
 			}
+			r.unfixed = append(r.unfixed, fixer.unfixed...)
 		}
-		if err := fixer.Fix(toFix...); err != nil {
+		if err := fixer.FixContext(r.context(), toFix...); err != nil {
 			return err
 		}
+		r.unfixed = append(r.unfixed, fixer.unfixed...)
 	}
 }
 
+// AddSink adds a sink that is told what was fixed when Prepare finishes, in addition to
+// the summary that is always printed, and the reports asked for by the Options.
+func (r *Runner) AddSink(s Sink) {
+	r.sinks = append(r.sinks, s)
+}
+
+// summarize reports the fixes to each sink, and compares them with the baseline.
+func (r *Runner) summarize() error {
+	o, err := r.outcome()
+	if err != nil {
+		return err
+	}
+	r.added = o.Added
+
+	summary := r.log(r.stdout())
+	if r.Options.Diff {
+		summary = r.log(r.stderr())
+	}
+	sinks := []Sink{NewSummarySink(summary)}
+	if r.Options.ReportFile != "" {
+		sinks = append(sinks, fileSink{r.Options.ReportFile, r.sandbox, NewJSONSink})
+	}
+	// in ci mode the annotations are written to stdout by Run anyway
+	if r.Options.GitHubReport == "-" && r.mode != "ci" {
+		sinks = append(sinks, NewGitHubSink(r.stdout()))
+	} else if r.Options.GitHubReport != "" && r.Options.GitHubReport != "-" {
+		sinks = append(sinks, fileSink{r.Options.GitHubReport, r.sandbox, NewGitHubSink})
+	}
+	for _, s := range append(sinks, r.sinks...) {
+		if err := s.Report(o); err != nil {
+			return err
+		}
+	}
+	// in ci mode the new deferrals are reported as annotations by Run.
+	if r.mode != "ci" && o.Baseline != nil && r.Options.MaxNewDeferrals >= 0 {
+		added := r.added
+		if len(added) > r.Options.MaxNewDeferrals {
+			for _, fix := range added {
+				fmt.Fprintf(r.log(r.stdout()), "golo: new deferral: %s: %s\n", fix.Pos, fix.Msg)
+			}
+			return fmt.Errorf("%d new %s since %s, but -max-new-deferrals is %d",
+				len(added), plural(len(added), "deferral", "deferrals"), r.Options.Baseline, r.Options.MaxNewDeferrals)
+		}
+	}
+	return nil
+}
+
+// outcome returns what happened in Prepare.
+func (r *Runner) outcome() (*Outcome, error) {
+	o := &Outcome{
+		Report:       newReport(r.applied),
+		FilesChanged: len(r.fixed) + len(r.spilled),
+		Unfixed:      uniqueErrors(r.unfixed),
+	}
+	o.Report.Artifact = r.artifact
+	o.Report.Unmatched = r.unmatched
+	o.Report.Strict = r.strict
+	o.Report.Excluded = r.excluded
+
+	if r.Options.Baseline != "" {
+		var err error
+		if o.Baseline, err = ReadReport(r.Options.Baseline); err != nil {
+			return nil, err
+		}
+		o.Added = DiffReports(o.Baseline, o.Report).Added
+	}
+	return o, nil
+}
+
 var rePackage = regexp.MustCompile(`^# ([^\s]*)( \[.*\])?$`)
 
 func (r *Runner) getBrokenPackages() ([]string, error) {
+	r.probed = r.probed[:0]
 	if r.exeFile == "" {
-		exe, err := os.CreateTemp("", "golo-*")
+		// Windows only runs binaries whose name ends in .exe, and has no executable bit
+		pattern := "golo-*"
+		if runtime.GOOS == "windows" {
+			pattern += ".exe"
+		}
+		exe, err := r.sandbox.createTemp(r.tempDir, pattern)
 		if err != nil {
 			return nil, err
 		}
+		exe.Close()
 		r.exeFile = exe.Name()
-		r.cleanup = append(r.cleanup, r.exeFile)
-		if err := os.Chmod(r.exeFile, 0o777); err != nil {
-			return nil, err
+		if runtime.GOOS != "windows" {
+			if err := r.sandbox.chmod(r.exeFile, 0o777); err != nil {
+				return nil, err
+			}
 		}
 	}
 	subCmd := []string{"build"}
@@ -122,119 +436,534 @@ func (r *Runner) getBrokenPackages() ([]string, error) {
 		subCmd = []string{"test", "-vet=off", "-c"}
 	}
 
-	if len(r.fixed) != 0 {
+	r.dropUnchanged()
+	// once written, the overlay must be kept up to date even if nothing is fixed any more
+	if len(r.fixed)+len(r.spilled) != 0 || r.overlayFile != "" {
 		if err := r.updateOverlays(); err != nil {
 			return nil, err
 		}
 		subCmd = append(subCmd, "-overlay", r.overlayFile)
 	}
-	args := append(append(subCmd, "-o", r.exeFile), r.buildArgs...)
-	if r.verbose {
-		fmt.Println("# running: go ", strings.Join(args, " "))
+	// the binary is moved to the -o the user asked for once it has been built (see install)
+	flags, pkgs := splitFlags(r.buildArgs)
+	args := append(append(append(subCmd, "-o", r.exeFile), withoutOutputFlag(flags)...), pkgs...)
+	if r.mode == "test" {
+		tests, err := r.testPackages()
+		if err != nil {
+			return nil, err
+		}
+		// go test -c can only build one package's tests at a time
+		if len(tests) > 1 {
+			return r.getBrokenTests(append(subCmd, withoutOutputFlag(flags)...), tests)
+		}
 	}
-	cmd := exec.Command("go", args...)
-	out, err := cmd.CombinedOutput()
-	if err == nil {
+	toFix, err := r.probe(args)
+	if err == nil && toFix == nil && r.mode == "run" {
+		toFix, err = r.notMainPackages(flags, pkgs)
+	}
+	if err == nil && toFix == nil {
 		r.built = true
+	}
+	return toFix, err
+}
+
+// notMainPackages returns the packages being run if they aren't main packages. go build
+// doesn't fail for those (it writes an archive instead of a binary), but go run would.
+func (r *Runner) notMainPackages(flags, pkgs []string) ([]string, error) {
+	exe, err := os.Open(r.exeFile)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len("!<arch>\n"))
+	_, err = io.ReadFull(exe, header)
+	exe.Close()
+	if err != nil || string(header) != "!<arch>\n" {
 		return nil, nil
 	}
+	out, err := r.goCommand(append(append([]string{"list", "-e", "-f", "{{.ImportPath}}"}, withoutOutputFlag(flags)...), pkgs...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+	r.mains = strings.Fields(string(out))
+	return r.mains, nil
+}
 
+// getBrokenTests builds the tests of each package separately (with go test -c, followed by
+// args), and returns all the packages that failed to build. Each package's test binary is
+// thrown away, as Run runs go test to build them all again and run them.
+func (r *Runner) getBrokenTests(args []string, pkgs []string) ([]string, error) {
 	toFix := []string{}
+	r.brokenTests = nil
+	var toolchainErr error
+	for i, pkg := range pkgs {
+		exe := filepath.Join(r.tempDir, fmt.Sprintf("golo-%d.test", i))
+		broken, err := r.probe(append(args[:len(args):len(args)], "-o", exe, pkg))
+		var te *ToolchainError
+		if errors.As(err, &te) {
+			toolchainErr = err
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if len(broken) > 0 {
+			r.brokenTests = append(r.brokenTests, pkg)
+		}
+		for _, p := range broken {
+			if !slices.Contains(toFix, p) {
+				toFix = append(toFix, p)
+			}
+		}
+	}
+	// errors that golo can't fix only matter once it has fixed everything it can
+	if len(toFix) == 0 && toolchainErr != nil {
+		return nil, toolchainErr
+	}
+	if len(toFix) == 0 {
+		r.built = true
+		return nil, nil
+	}
+	return toFix, nil
+}
 
-	for _, line := range bytes.Split(out, []byte("\n")) {
-		if matches := rePackage.FindSubmatch(line); matches != nil {
-			toFix = append(toFix, string(matches[1]))
+// excludeBrokenTests stops the tests whose packages still don't build from being built or run
+// (by removing them from r.buildArgs), as long as some of the others do build. It returns true
+// if it excluded any.
+func (r *Runner) excludeBrokenTests() bool {
+	if len(r.brokenTests) == 0 || len(r.brokenTests) == len(r.tests) {
+		return false
+	}
+	remaining := []string{}
+	for _, pkg := range r.tests {
+		if !slices.Contains(r.brokenTests, pkg) {
+			remaining = append(remaining, pkg)
+			continue
 		}
+		r.excluded = append(r.excluded, pkg)
+		fmt.Fprintf(r.log(r.stdout()), "golo: skipping the tests in %s, as golo could not fix or defer its errors\n", pkg)
 	}
+	r.tests, r.brokenTests = remaining, nil
+	flags, _ := splitFlags(r.buildArgs)
+	r.buildArgs = append(flags[:len(flags):len(flags)], remaining...)
+	return true
+}
 
-	return toFix, nil
+// testPackages returns the packages whose tests are being built, as listed by go list.
+func (r *Runner) testPackages() ([]string, error) {
+	if r.tests != nil {
+		return r.tests, nil
+	}
+	flags, pkgs := splitFlags(r.buildArgs)
+	// files are always in one package
+	if len(pkgs) == 0 || isGoFile(pkgs[0]) {
+		r.tests = []string{"command-line-arguments"}
+		return r.tests, nil
+	}
+	cmd := r.goCommand(append(append([]string{"list", "-e", "-f", "{{.ImportPath}}"}, withoutOutputFlag(flags)...), pkgs...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+	r.tests = strings.Fields(string(out))
+	return r.tests, nil
 }
 
-func (r *Runner) updateOverlays() error {
-	if r.overlayFile == "" {
-		overlay, err := os.CreateTemp("", "golo-*.json")
-		if err != nil {
-			return err
+// probe runs the go command with args, and returns the packages that failed to build (or nil
+// if they all built).
+func (r *Runner) probe(args []string) ([]string, error) {
+	// go build -json says which package each error is in, so it is used where it is supported
+	// (and where it isn't, the errors are found in the output instead)
+	useJSON := !r.noBuildJSON
+	if useJSON {
+		args = append([]string{args[0], "-json"}, args[1:]...)
+	}
+	if r.verbose {
+		fmt.Fprintln(r.log(r.stdout()), "# running: go ", strings.Join(args, " "))
+	}
+	cmd := r.goCommand(args...)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if !useJSON {
+		cmd.Stderr = stdout
+	}
+	err := cmd.Run()
+	if err == nil {
+		return nil, nil
+	}
+	if cmd.ProcessState == nil {
+		return nil, err
+	}
+	if useJSON && bytes.Contains(stderr.Bytes(), []byte("flag provided but not defined: -json")) {
+		r.noBuildJSON = true
+		return r.probe(append([]string{args[0]}, args[2:]...))
+	}
+
+	toFix := []string{}
+	out := stdout.Bytes()
+	if useJSON {
+		toFix, out = decodeBuildEvents(out)
+		out = append(out, stderr.Bytes()...)
+		if r.verbose {
+			r.log(r.stdout()).Write(out)
 		}
-		r.overlayFile = overlay.Name()
-		r.cleanup = append(r.cleanup, r.overlayFile)
-		overlay.Close()
 	}
-	for f := range r.fixed {
-		if r.overlays.Replace[f] == "" {
-			newF, err := os.CreateTemp("", "golo-*.go")
-			if err != nil {
-				return err
+	r.probed = append(r.probed, out...)
+	// go test -c accepts -json before Go 1.24 without printing any build events, and
+	// some errors aren't reported as build events, so the output is read as go prints it
+	if len(toFix) == 0 {
+		for _, line := range bytes.Split(out, []byte("\n")) {
+			if matches := rePackage.FindSubmatch(line); matches != nil {
+				toFix = append(toFix, string(matches[1]))
 			}
-			r.overlays.Replace[f] = newF.Name()
-			r.cleanup = append(r.cleanup, newF.Name())
-			newF.Close()
-			if err := os.WriteFile(newF.Name(), r.fixed[f], 0o666); err != nil {
-				return err
+			// this error is reported without a package header, so the directory is fixed instead
+			if dir, ok := packageClashDir(string(line)); ok {
+				toFix = append(toFix, dir)
+			}
+		}
+		toFix = append(toFix, selfImports(out)...)
+	}
+	// go failed for some other reason, which golo can't fix (so shouldn't claim to have)
+	if len(toFix) == 0 {
+		return nil, &ToolchainError{ExitStatus: cmd.ProcessState.ExitCode(), Output: string(out)}
+	}
+
+	return toFix, nil
+}
+
+// buildEvent is one of the events that go build -json prints (see go help buildjson).
+type buildEvent struct {
+	ImportPath string
+	Action     string
+	Output     string
+}
+
+// decodeBuildEvents returns the packages that failed to build (without the " [pkg.test]" that
+// test variants have), and what go build would have printed without -json.
+func decodeBuildEvents(out []byte) ([]string, []byte) {
+	failed := []string{}
+	text := []byte{}
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		e := buildEvent{}
+		if err := json.Unmarshal(line, &e); err != nil {
+			// test binaries (and older go commands) may print other things too
+			if len(bytes.TrimSpace(line)) > 0 {
+				text = append(append(text, line...), '\n')
+			}
+			continue
+		}
+		switch e.Action {
+		case "build-output":
+			text = append(text, e.Output...)
+		case "build-fail":
+			pkg, _, _ := strings.Cut(e.ImportPath, " ")
+			if !slices.Contains(failed, pkg) {
+				failed = append(failed, pkg)
 			}
+		}
+	}
+	return failed, text
+}
+
+// dropUnchanged removes fixed files that are the same as the file on disk (for example
+// because the user fixed them before resuming from a checkpoint), so that the overlay only
+// replaces files that golo actually changed, and a program that needed no fixes is built
+// exactly as go would build it.
+func (r *Runner) dropUnchanged() {
+	for f, content := range r.fixed {
+		if original, err := os.ReadFile(f); err == nil && bytes.Equal(stripBOM(original), content) {
 			if r.verbose {
-				fmt.Println("#", f, newF.Name())
-				os.Stdout.Write(r.fixed[f])
+				fmt.Fprintln(r.log(r.stdout()), "# unchanged", f)
 			}
+			delete(r.fixed, f)
 		}
 	}
-	overlay, err := os.Create(r.overlayFile)
+}
+
+func (r *Runner) updateOverlays() error {
+	// files that are no longer fixed (because they were regenerated) are read from disk again
+	for f := range r.overlays.Replace {
+		_, fixed := r.fixed[f]
+		_, spilled := r.spilled[f]
+		if !fixed && !spilled {
+			delete(r.overlaid, r.overlays.Replace[f])
+			delete(r.overlays.Replace, f)
+		}
+	}
+	// spilled files are already on disk
+	for f, s := range r.spilled {
+		r.overlays.Replace[f] = s.Path
+	}
+	for f := range r.fixed {
+		// a file may be fixed again after its overlay was first written, and each fix has its own
+		// overlay (which may have been written by an earlier run)
+		hash := sha256.Sum256(r.fixed[f])
+		path := r.overlayPath(f, hash)
+		if r.overlays.Replace[f] == path && r.overlaid[path] == hash {
+			continue
+		}
+		if err := r.writeOverlay(path, r.fixed[f]); err != nil {
+			return err
+		}
+		r.overlays.Replace[f] = path
+		r.overlaid[path] = hash
+		if r.verbose {
+			fmt.Fprintln(r.log(r.stdout()), "#", f, path)
+			r.log(r.stdout()).Write(r.fixed[f])
+		}
+	}
+
+	content, err := json.Marshal(nativeOverlay(r.overlays))
 	if err != nil {
 		return err
 	}
-
+	// the overlay is named after its content too, so that runs making the same fixes share it
+	// (and concurrent runs making different ones don't)
+	r.overlayFile = r.overlayPath("overlay.json", sha256.Sum256(content))
 	if r.verbose {
-		fmt.Println("# overlay.json", r.overlayFile)
-		e := json.NewEncoder(os.Stdout)
+		fmt.Fprintln(r.log(r.stdout()), "# overlay.json", r.overlayFile)
+		e := json.NewEncoder(r.log(r.stdout()))
 		e.SetIndent("", "  ")
-		e.Encode(r.overlays)
+		e.Encode(nativeOverlay(r.overlays))
 	}
-	if err := json.NewEncoder(overlay).Encode(r.overlays); err != nil {
+	return r.writeOverlay(r.overlayFile, content)
+}
+
+// overlayPath returns where the overlay of filename is written when its content has hash.
+func (r *Runner) overlayPath(filename string, hash [sha256.Size]byte) string {
+	sum := sha256.Sum256([]byte(filename))
+	return filepath.Join(r.overlayDir, hex.EncodeToString(sum[:8])+"-"+hex.EncodeToString(hash[:8])+"-"+filepath.Base(filename))
+}
+
+// writeOverlay writes content to path, unless it is already there. Other runs of golo may be
+// reading the file, so it is written to a temporary file that is renamed into place.
+func (r *Runner) writeOverlay(path string, content []byte) error {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, content) {
+		// (so that golo clean knows it is still being used)
+		return r.sandbox.chtimes(path, time.Now())
+	}
+	tmp, err := r.sandbox.createTemp(filepath.Dir(path), "golo-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
 		return err
 	}
-	return overlay.Close()
+	return r.sandbox.rename(tmp.Name(), path)
+}
+
+// nativeOverlay returns o with its paths written with the operating system's separators, as
+// the go command looks up the files it builds by their native paths.
+func nativeOverlay(o packages.OverlayJSON) packages.OverlayJSON {
+	native := packages.OverlayJSON{Replace: make(map[string]string, len(o.Replace))}
+	for file, overlay := range o.Replace {
+		native.Replace[filepath.FromSlash(file)] = filepath.FromSlash(overlay)
+	}
+	return native
 }
 
 // Run does what the user asked. Call .Prepare() first
+// If any of the package patterns didn't match (or any tests were skipped because golo
+// couldn't fix them), the exit status is 1 even if everything else succeeded.
 func (r *Runner) Run() (int, error) {
-	// we failed to fix it, run the compiler again so the user can see the problems
-	if !r.built {
+	return r.RunContext(context.Background())
+}
+
+// RunContext is like Run, but stops the command it runs once ctx is done (interrupting it, and
+// killing it if it hasn't exited after stopTimeout).
+func (r *Runner) RunContext(ctx context.Context) (int, error) {
+	r.ctx = ctx
+	status, err := r.run()
+	if err == nil && status == 0 && len(r.unmatched)+len(r.excluded) > 0 {
+		status = 1
+	}
+	return status, err
+}
+
+func (r *Runner) run() (int, error) {
+	if r.Options.Diff && r.mode != "ci" && r.mode != "fix" {
+		status, err := r.printDiff(r.stdout())
+		r.removeTemp()
+		return status, err
+	}
+	// we failed to fix it, show the user the problems (or run the compiler again so it can)
+	if !r.built && r.mode != "ci" && r.mode != "fix" {
+		if len(r.stuck) > 0 {
+			fmt.Fprintf(r.log(r.stderr()), "golo: could not fix or defer the errors in %s\n", strings.Join(r.stuck, ", "))
+		}
+		if r.limited {
+			fmt.Fprintf(r.log(r.stderr()), "golo: gave up after fixing %d errors, as it reached -max-fixes %d\n", len(r.applied), r.Options.MaxFixes)
+		} else if len(r.applied) > 0 {
+			fmt.Fprintf(r.log(r.stderr()), "golo: gave up after fixing %d errors\n", len(r.applied))
+		}
+		if len(r.unfixed) > 0 && !r.verbose {
+			writeExcerpts(r.log(r.stderr()), r.unfixed, r.readFixed, terminalWidth())
+			r.removeTemp()
+			return 1, nil
+		}
 		if r.verbose {
-			fmt.Println("golo: failed to build, running with no overlay")
+			fmt.Fprintln(r.log(r.stdout()), "golo: failed to build, running with no overlay")
 		}
-		return r.exec(exec.Command("go", append(append([]string{r.mode}, r.buildArgs...), r.runArgs...)...))
+		return r.exec(r.goCommand(append(append([]string{r.mode}, r.buildArgs...), r.runArgs...)...))
 	}
 
+	res, err := r.Build(r.context())
+	if err != nil {
+		r.removeTemp()
+		return 1, err
+	}
 	switch r.mode {
+	case "ci":
+		return r.annotate(r.stdout()), nil
+	case "fix":
+		status, err := r.writeFixes(r.stdout())
+		r.removeTemp()
+		return status, err
 	case "run":
-		return r.exec(exec.Command(r.exeFile, r.runArgs...))
+		return r.exec(exec.CommandContext(r.context(), res.Binary, r.runArgs...))
 	case "test":
-		args := r.buildArgs
-		if r.overlayFile != "" {
-			args = append([]string{"-vet=off", "-overlay=" + r.overlayFile}, r.buildArgs...)
+		if cmd := r.testBinary(res.Binary); cmd != nil {
+			return r.exec(cmd)
 		}
-		return r.exec(exec.Command("go", append([]string{"test"}, args...)...))
+		args := append(r.buildArgs[:len(r.buildArgs):len(r.buildArgs)], r.runArgs...)
+		if res.Overlay != "" {
+			args = append([]string{"-vet=off", "-overlay=" + res.Overlay}, args...)
+		}
+		return r.exec(r.goCommand(append([]string{"test"}, args...)...))
 	case "build":
-		// TODO: copy the binary we just built to the right place?
-		args := r.buildArgs
-		if r.overlayFile != "" {
-			args = append([]string{"-overlay=" + r.overlayFile}, r.buildArgs...)
+		err := r.install()
+		r.removeTemp()
+		if err != nil {
+			return 1, err
 		}
-		return r.exec(exec.Command("go", append([]string{"build"}, args...)...))
+		return 0, nil
 	default:
 		return 0, fmt.Errorf("%v is not supported yet", r.mode)
 	}
 }
 
+// testBinary returns a command that runs the test binary built while fixing, so that go test
+// doesn't build the tests again. It returns nil if go test is needed to run the tests: when
+// testing more than one package, or when go test would do more than run the binary.
+func (r *Runner) testBinary(binary string) *exec.Cmd {
+	args, ok := testBinaryFlags(r.runArgs)
+	if !ok || binary == "" {
+		return nil
+	}
+	flags, pkgs := splitFlags(r.buildArgs)
+	out, err := r.goCommand(append(append([]string{"list", "-f", "{{.Dir}}"}, withoutOutputFlag(flags)...), pkgs...)...).Output()
+	if err != nil {
+		return nil
+	}
+	dirs := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(dirs) != 1 || dirs[0] == "" {
+		return nil
+	}
+	// like go test, the tests run in the package's directory so that they can find their testdata
+	cmd := exec.CommandContext(r.context(), binary, args...)
+	cmd.Dir = dirs[0]
+	cmd.Env = append(goEnv(r.Options), "PWD="+dirs[0])
+	return cmd
+}
+
+func (r *Runner) stdout() io.Writer {
+	if r.Stdout == nil {
+		return os.Stdout
+	}
+	return r.Stdout
+}
+
+func (r *Runner) stderr() io.Writer {
+	if r.Stderr == nil {
+		return os.Stderr
+	}
+	return r.Stderr
+}
+
+// context returns the context that the go commands (and the program) run with.
+func (r *Runner) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// log returns where golo writes its own messages: r.Log if it is set, otherwise w (which is
+// r.stdout(), or r.stderr() for messages that aren't part of golo's usual output).
+func (r *Runner) log(w io.Writer) io.Writer {
+	if r.Log == nil {
+		return w
+	}
+	return r.Log
+}
+
+// removeTemp removes the files golo created (unless Options.Keep is set, so that they can be
+// inspected).
+func (r *Runner) removeTemp() error {
+	if r.Options.Keep {
+		return nil
+	}
+	var err error
+	for _, file := range r.cleanup {
+		if e := os.RemoveAll(file); e != nil && err == nil {
+			err = e
+		}
+	}
+	r.cleanup = nil
+	return err
+}
+
+// Close removes golo's temporary directory, unless Options.Keep is set (in which case it says
+// where the directory is). Run removes it once it is done with it, but Close also removes it
+// if Prepare or Run fail (or are never called). It can be called more than once.
+func (r *Runner) Close() error {
+	if r.Options.Keep && r.tempDir != "" && !r.kept {
+		r.kept = true
+		fmt.Fprintf(r.log(r.stderr()), "golo: kept temporary files in %s\n", r.tempDir)
+		if r.overlayFile != "" {
+			fmt.Fprintf(r.log(r.stderr()), "golo: the overlay of the fixed files is %s\n", r.overlayFile)
+		}
+	}
+	return r.removeTemp()
+}
+
+// exec runs cmd with golo's stdin, stdout and stderr, and removes golo's temporary files once
+// it has exited. Signals sent to golo while it runs are forwarded to cmd, so that golo can still
+// clean up when it is interrupted.
 func (r *Runner) exec(cmd *exec.Cmd) (int, error) {
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
-	if !r.verbose {
-		for _, file := range r.cleanup {
-			os.Remove(file)
+	cmd.Stdin = r.Stdin
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stdout = r.stdout()
+	cmd.Stderr = r.stderr()
+	// when the context is done the command is stopped in the same way as by Stop
+	if cmd.Cancel != nil {
+		cmd.Cancel = func() error {
+			signalProcess(cmd, os.Interrupt)
+			return nil
 		}
+		cmd.WaitDelay = stopTimeout
+	}
+	stop, err := forwardSignals(cmd)
+	if err != nil {
+		r.removeTemp()
+		return 0, err
 	}
+	exited := make(chan struct{})
+	r.mu.Lock()
+	r.running, r.exited = cmd, exited
+	r.mu.Unlock()
+	err = cmd.Wait()
+	stop()
+	r.mu.Lock()
+	r.running = nil
+	r.mu.Unlock()
+	close(exited)
+	r.removeTemp()
 	if cmd.ProcessState == nil {
 		return 0, err
 	}
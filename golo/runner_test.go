@@ -0,0 +1,1606 @@
+package golo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestMain(m *testing.M) {
+	// golo's caches are kept out of the user's cache directory, so that the tests don't fill it
+	// (or reuse what an earlier run left in it)
+	dir, err := os.MkdirTemp("", "golotest-cache-*")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	userCacheDir = func() (string, error) { return dir, nil }
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func writeModule(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	files["go.mod"] = "module example.com/m\n\ngo 1.20\n"
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func cleanup(r *Runner) {
+	for _, file := range r.cleanup {
+		os.RemoveAll(file)
+	}
+}
+
+func TestRunner_Resume(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": `package main
+
+func main() {
+	a, b := c()
+
+	a.d(b)
+}
+
+func c() (int, int) {
+	return 1, 2
+}
+`})
+	resume := t.TempDir()
+	chdir(t, dir)
+
+	interrupted := errors.New("interrupted")
+	r := New("run", false, []string{"."})
+	r.Options.ResumeDir = resume
+	r.onCheckpoint = func() error { return interrupted }
+	defer cleanup(r)
+	if err := r.Prepare(); err != interrupted {
+		t.Fatalf("expected to be interrupted, got %v", err)
+	}
+
+	first, err := r.loadCheckpoint(resume)
+	if err != nil || first == nil {
+		t.Fatalf("expected a checkpoint, got %v", err)
+	}
+	if len(first.Applied) != 1 {
+		t.Fatalf("expected 1 fix before the interruption, got %v", first.Applied)
+	}
+
+	r2 := New("run", false, []string{"."})
+	r2.Options.ResumeDir = resume
+	var resumed []Fix
+	r2.onCheckpoint = func() error {
+		cp, err := r2.loadCheckpoint(resume)
+		if err == nil {
+			resumed = cp.Applied
+		}
+		return err
+	}
+	defer cleanup(r2)
+	if err := r2.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if !r2.built {
+		t.Fatal("expected the resumed run to build")
+	}
+	if len(resumed) <= 1 || resumed[0].Pos != first.Applied[0].Pos {
+		t.Fatalf("expected the resumed run to continue after %v, got %v", first.Applied, resumed)
+	}
+	for _, fix := range resumed[1:] {
+		if fix.Pos == first.Applied[0].Pos {
+			t.Errorf("expected %v not to be fixed again", fix)
+		}
+	}
+
+	// once the source changes, the checkpoint is no longer used
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if cp, err := r2.loadCheckpoint(resume); err != nil || cp != nil {
+		t.Errorf("expected stale checkpoint to be ignored, got %v %v", cp, err)
+	}
+}
+
+func TestRunner_CI(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"a.go":          "package m\n\nfunc A() {\n\tfoo()\n}\n",
+		"b.go":          "package m\n\nfunc B() {\n\tbar()\n}\n",
+		"baseline.json": `{"Version": 1, "Fixes": [{"Pos": {"Filename": "a.go", "Line": 4, "Column": 2}, "Msg": "undefined: foo", "Kind": "deferred"}]}`,
+	})
+	chdir(t, dir)
+
+	r := New("ci", false, []string{"./..."})
+	r.Options.Baseline = "baseline.json"
+	r.Options.ReportFile = "report.json"
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	if status := r.annotate(out); status != 1 {
+		t.Errorf("expected a new deferral to fail the run, got status %d", status)
+	}
+	expected := "::warning file=a.go,line=4,col=2,title=golo deferred this error until runtime::undefined: foo\n" +
+		"::error file=b.go,line=4,col=2,title=golo deferred a new error until runtime::undefined: bar\n"
+	if out.String() != expected {
+		t.Errorf("unexpected annotations:\n%s", out)
+	}
+
+	report, err := ReadReport("report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Version != ReportVersion || len(report.Deferrals()) != 2 {
+		t.Errorf("unexpected report: %#v", report)
+	}
+
+	// with b.go's error in the baseline too, the run passes
+	if err := os.Rename("report.json", "baseline.json"); err != nil {
+		t.Fatal(err)
+	}
+	r = New("ci", false, []string{"./..."})
+	r.Options.Baseline = "baseline.json"
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if status := r.annotate(io.Discard); status != 0 {
+		t.Errorf("expected the run to pass, got status %d", status)
+	}
+}
+
+// largeModule generates a main package with n files of about size bytes, each of which
+// contains an error that golo fixes. The first file has more errors so it is fixed again
+// after it has been spilled.
+func largeModule(t *testing.T, n int, size int) string {
+	files := map[string]string{}
+	main := "package main\n\nfunc main() {\n"
+	padding := "// " + strings.Repeat("padding ", 10) + "\n"
+	for i := 0; i < n; i++ {
+		files[fmt.Sprintf("f%d.go", i)] = fmt.Sprintf("package main\n\nimport \"fmt\"\n\nfunc f%d() int {\n\treturn %d\n}\n", i, i) +
+			strings.Repeat(padding, size/len(padding))
+		main += fmt.Sprintf("\tf%d()\n", i)
+	}
+	files["main.go"] = main + "}\n"
+	files["f0.go"] = strings.Replace(files["f0.go"], "return", "unused := 1\n\treturn", 1)
+	return writeModule(t, files)
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func TestRunner_Spill(t *testing.T) {
+	const n, size = 5, 2 * spillThreshold
+	chdir(t, largeModule(t, n, size))
+
+	before := heapAlloc()
+	r := New("build", false, []string{"-o", os.DevNull, "."})
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.built {
+		t.Fatal("expected the fixed files to build")
+	}
+	if len(r.fixed) != 0 || len(r.spilled) != n {
+		t.Errorf("expected all %d files to be spilled, got %d in memory and %d on disk", n, len(r.fixed), len(r.spilled))
+	}
+	// without spilling, the fixed files alone would use n*size
+	if retained := int64(heapAlloc()) - int64(before); retained > n*size/4 {
+		t.Errorf("expected less than %d bytes to be retained, got %d", n*size/4, retained)
+	}
+
+	// the fixes are cached without reading the files back into memory, and stay spilled when
+	// they are reused
+	before = heapAlloc()
+	out := &bytes.Buffer{}
+	r = New("build", false, []string{"-o", os.DevNull, "."})
+	r.Stdout = out
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "from the cache") || len(r.fixed) != 0 || len(r.spilled) != n {
+		t.Errorf("expected all %d files to be spilled from the cache, got %d in memory and %d on disk:\n%s", n, len(r.fixed), len(r.spilled), out)
+	}
+	if retained := int64(heapAlloc()) - int64(before); retained > n*size/4 {
+		t.Errorf("expected less than %d bytes to be retained from the cache, got %d", n*size/4, retained)
+	}
+
+	// small files are spilled too if golo is using more than MaxMemory
+	chdir(t, largeModule(t, 1, 0))
+	r = New("build", false, []string{"-o", os.DevNull, "."})
+	r.Options.MaxMemory = 1
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.built || len(r.fixed) != 0 || len(r.spilled) != 1 {
+		t.Errorf("expected the file to be spilled and built, got %d in memory and %d on disk", len(r.fixed), len(r.spilled))
+	}
+}
+
+func TestRunner_Dependencies(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": `package main
+
+import "fmt"
+
+func main() {
+	x := 1
+	fmt.Println(x.undefined())
+}
+`})
+	resume := t.TempDir()
+	chdir(t, dir)
+
+	// the first run is interrupted after it has blanked the import
+	interrupted := errors.New("interrupted")
+	r := New("run", false, []string{"."})
+	r.Options.ResumeDir = resume
+	r.onCheckpoint = func() error {
+		if len(r.fixed) > 0 && bytes.Contains(r.fixed[filepath.Join(dir, "main.go")], []byte(`_ "fmt"`)) {
+			return interrupted
+		}
+		return nil
+	}
+	defer cleanup(r)
+	if err := r.Prepare(); err != interrupted {
+		t.Fatalf("expected to be interrupted, got %v", err)
+	}
+	cp, err := r.loadCheckpoint(resume)
+	if err != nil || cp == nil || len(cp.Applied) < 2 {
+		t.Fatalf("expected a checkpoint with the import fixed, got %v %v", cp, err)
+	}
+	last := cp.Applied[len(cp.Applied)-1]
+	if deps := last.DependsOn; last.Kind != UnusedImport || len(deps) != 1 || deps[0] != cp.Applied[0].Pos {
+		t.Errorf("expected the blanked import to depend on %v, got %v", cp.Applied[0].Pos, last)
+	}
+
+	// once the original error is fixed, the blanked import must not come back
+	if err := os.WriteFile("main.go", []byte("package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"ok\")\n}\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	r2 := New("run", false, []string{"."})
+	r2.Options.ResumeDir = resume
+	defer cleanup(r2)
+	if err := r2.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if !r2.built || len(r2.fixed) != 0 || len(r2.applied) != 0 {
+		t.Errorf("expected no fixes after the error was fixed, got %v", r2.applied)
+	}
+}
+
+func TestRunner_Artifact(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go":      "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(undefined)\n}\n",
+		"main_test.go": "package main\n\nimport \"testing\"\n\nfunc TestOK(t *testing.T) {}\n",
+	})
+	chdir(t, dir)
+	artifacts := t.TempDir()
+
+	for _, mode := range []string{"build", "test"} {
+		r := New(mode, false, []string{"."})
+		r.Options.ArtifactDir = artifacts
+		r.Options.ReportFile = "report.json"
+		defer cleanup(r)
+		if err := r.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := ReadReport("report.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Artifact == nil {
+			t.Fatalf("expected an artifact in the %s report", mode)
+		}
+		name := "example.com_m-" + runtime.GOOS + "-" + runtime.GOARCH
+		if mode == "test" {
+			name += ".test"
+		}
+		if report.Artifact.Path != filepath.Join(artifacts, name) {
+			t.Errorf("unexpected artifact path %s", report.Artifact.Path)
+		}
+
+		info, err := os.Stat(report.Artifact.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" && info.Mode()&0o111 == 0 {
+			t.Errorf("expected %s to be executable, got %v", report.Artifact.Path, info.Mode())
+		}
+		if hash, err := hashFile(report.Artifact.Path); err != nil || hash != report.Artifact.SHA256 {
+			t.Errorf("expected hash %s, got %s (%v)", report.Artifact.SHA256, hash, err)
+		}
+	}
+}
+
+func TestRunner_BuildsIdenticalBinary(t *testing.T) {
+	main := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n"
+	dir := writeModule(t, map[string]string{"main.go": main})
+	chdir(t, dir)
+	out := t.TempDir()
+	flags := []string{"-trimpath", "-buildvcs=false"}
+
+	cmd := goCommand(context.Background(), Options{}, append(append([]string{"build"}, flags...), "-o", filepath.Join(out, "go"), ".")...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %s\n%s", err, output)
+	}
+	want, err := hashFile(filepath.Join(out, "go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stale := range []bool{false, true} {
+		exe := filepath.Join(out, fmt.Sprintf("golo-%v", stale))
+		r := New("build", false, append(flags, "-o", exe, "."))
+		defer cleanup(r)
+		// e.g. a checkpoint of a fix that the user has since made themselves
+		if stale {
+			r.fixed[filepath.Join(dir, "main.go")] = []byte(main)
+		}
+		if err := r.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+		for file, content := range r.fixed {
+			if original, err := os.ReadFile(file); err == nil && bytes.Equal(original, content) {
+				t.Errorf("%s is in the overlay, but has not been changed", file)
+			}
+		}
+		if code, err := r.Run(); err != nil || code != 0 {
+			t.Fatalf("golo build failed: %d %v", code, err)
+		}
+
+		got, err := hashFile(exe)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("golo built a different binary to go (stale=%v): %s != %s", stale, got, want)
+		}
+	}
+}
+
+func TestRunner_BuildOutput(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go": "package main\n\nfunc main() {\n\tx := \"unused\"\n\tprintln(\"hello\")\n}\n",
+	})
+	chdir(t, dir)
+
+	for _, eg := range []struct {
+		args []string
+		file string
+	}{
+		{[]string{"-o", "bin/app", "."}, "bin/app"},
+		{[]string{"-o", "bin/", "."}, "bin/m"},
+		{[]string{"."}, "m"},
+	} {
+		r := New("build", false, eg.args)
+		defer cleanup(r)
+		if err := r.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+		if code, err := r.Run(); err != nil || code != 0 {
+			t.Fatalf("golo build %v failed: %d %v", eg.args, code, err)
+		}
+		info, err := os.Stat(filepath.Join(dir, eg.file))
+		if err != nil {
+			t.Fatalf("golo build %v: %v", eg.args, err)
+		}
+		if info.Mode().Perm()&0o111 == 0 {
+			t.Errorf("golo build %v: %s is not executable (%v)", eg.args, eg.file, info.Mode())
+		}
+		out, err := exec.Command(filepath.Join(dir, eg.file)).CombinedOutput()
+		if err != nil || string(out) != "hello\n" {
+			t.Errorf("golo build %v: %s printed %q (%v)", eg.args, eg.file, out, err)
+		}
+		if _, err := os.Stat(r.exeFile); !os.IsNotExist(err) {
+			t.Errorf("golo build %v: left the binary it built behind", eg.args)
+		}
+	}
+}
+
+func TestRunner_PanicPosition(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go": "package main\n\nimport \"runtime\"\n\nfunc main() {\n\t_, file, line, _ := runtime.Caller(0)\n\tprintln(file, line)\n\tundefined()\n}\n",
+	})
+	chdir(t, dir)
+	exe := filepath.Join(t.TempDir(), "main")
+
+	r := New("build", false, []string{"-o", exe, "."})
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := r.Run(); err != nil || code != 0 {
+		t.Fatalf("golo build failed: %d %v", code, err)
+	}
+
+	// the overlay is compiled as if it were the original file, so panics (and runtime.Caller)
+	// point at the code the user wrote, not at golo's temporary copy of it.
+	out, _ := exec.Command(exe).CombinedOutput()
+	main := filepath.Join(dir, "main.go")
+	for _, want := range []string{main + " 6\n", "main.main()\n\t" + main + ":8 "} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunner_Sinks(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go": "package main\n\nfunc main() {\n\tfoo()\n}\n",
+	})
+	chdir(t, dir)
+
+	r := New("build", false, []string{"."})
+	defer cleanup(r)
+	r.Options.ReportFile = "report.json"
+	r.Options.GitHubReport = "annotations.txt"
+	summary := &bytes.Buffer{}
+	r.AddSink(NewSummarySink(summary))
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := ReadReport("report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Deferrals()) != 1 || report.Deferrals()[0].Msg != "undefined: foo" {
+		t.Errorf("unexpected report: %#v", report)
+	}
+
+	annotations, err := os.ReadFile("annotations.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "::warning file=main.go,line=4,col=2,title=golo deferred this error until runtime::undefined: foo\n"; string(annotations) != expected {
+		t.Errorf("unexpected annotations:\n%s", annotations)
+	}
+
+	if expected := "golo: 1 file changed, 1 deferral\n"; summary.String() != expected {
+		t.Errorf("unexpected summary: %q", summary)
+	}
+}
+
+func TestRunner_Fix(t *testing.T) {
+	a := "package m\n\nimport \"os\"\n\nfunc A() {\n\tx := A\n}\n"
+	b := "package m\n\nfunc B() {\n\tundefined()\n}\n"
+	dir := writeModule(t, map[string]string{"a.go": a, "b.go": b})
+	chdir(t, dir)
+
+	fix := func(options Options) *Runner {
+		r := New("fix", false, []string{"."})
+		r.Options = options
+		if err := r.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { cleanup(r) })
+		return r
+	}
+	write := func(r *Runner) (string, int) {
+		out := &bytes.Buffer{}
+		status, err := r.writeFixes(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.String(), status
+	}
+	read := func(name string) string {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+
+	r := fix(Options{Diff: true})
+	out, status := write(r)
+	if !strings.Contains(out, "--- a/a.go\n+++ b/a.go\n@@ -1,7 +1,7 @@\n package m\n \n-import \"os\"\n+import _ \"os\"\n") ||
+		!strings.Contains(out, "golo: not fixing b.go, as 1 error would panic") || status != 1 {
+		t.Errorf("unexpected diff (%d):\n%s", status, out)
+	}
+	if read("a.go") != a {
+		t.Errorf("-diff changed a.go")
+	}
+
+	// a.go changes after golo has read it
+	r = fix(Options{})
+	if err := os.WriteFile("a.go", []byte(a+"\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if out, status := write(r); !strings.Contains(out, "golo: not fixing a.go, as it has changed since golo read it") || status != 1 {
+		t.Errorf("expected a.go to be left alone (%d):\n%s", status, out)
+	}
+	if err := os.WriteFile("a.go", []byte(a), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	r = fix(Options{})
+	if out, status := write(r); !strings.Contains(out, "golo: fixed a.go (unused-var, unused-import)") || status != 1 {
+		t.Errorf("unexpected output (%d):\n%s", status, out)
+	}
+	if expected := "package m\n\nimport _ \"os\"\n\nfunc A() {\n\t_ = A\n}\n"; read("a.go") != expected {
+		t.Errorf("unexpected a.go:\n%s", read("a.go"))
+	}
+	if read("b.go") != b {
+		t.Errorf("b.go was fixed with a panic")
+	}
+
+	r = fix(Options{AllowPanics: true})
+	if out, status := write(r); !strings.Contains(out, "golo: fixed b.go (deferred)") || status != 0 {
+		t.Errorf("unexpected output (%d):\n%s", status, out)
+	}
+	if !strings.Contains(read("b.go"), "panic(") {
+		t.Errorf("expected b.go to be fixed with a panic:\n%s", read("b.go"))
+	}
+}
+
+func TestRunner_Diff(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": "package main\n\nimport \"os\"\n\nfunc main() {\n}\n"})
+	chdir(t, dir)
+
+	r := New("build", false, []string{"."})
+	r.Options.Diff = true
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(r)
+	out := &bytes.Buffer{}
+	status, err := r.printDiff(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "--- a/main.go\n+++ b/main.go\n@@ -1,6 +1,6 @@\n package main\n \n-import \"os\"\n+import _ \"os\"\n \n func main() {\n }\n"
+	if out.String() != expected || status != 1 {
+		t.Errorf("expected status 1 and diff:\n%s\ngot %d:\n%s", expected, status, out.String())
+	}
+
+	// the diff applies to the original files
+	cmd := exec.Command("git", "apply", "--check", "-")
+	cmd.Stdin = out
+	if err := exec.Command("git", "init", "-q").Run(); err == nil {
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("git apply failed: %s\n%s", err, output)
+		}
+	}
+
+	if err := os.WriteFile("main.go", []byte("package main\n\nfunc main() {\n}\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	r = New("build", false, []string{"."})
+	r.Options.Diff = true
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(r)
+	out.Reset()
+	if status, err := r.printDiff(out); err != nil || status != 0 || out.Len() != 0 {
+		t.Errorf("expected no diff, got %d (%v):\n%s", status, err, out.String())
+	}
+}
+
+func TestRunner_ExportShims(t *testing.T) {
+	main := "package main\n\nimport \"example.com/m/other\"\n\nfunc main() {\n\tprintln(other.double(2))\n}\n"
+	dir := writeModule(t, map[string]string{
+		"main.go":        main,
+		"other/other.go": "package other\n\nfunc double(n int) int { return n * 2 }\n",
+	})
+	chdir(t, dir)
+	shims := filepath.Join(dir, "other", exportShimFile)
+
+	r := New("run", false, []string{"."})
+	r.Options.ExportShims = true
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(r)
+	if !r.built || !bytes.Contains(r.fixed[shims], []byte("func GoloExport_double(p0 int) int { return double(p0) }")) {
+		t.Errorf("expected the shim to be in the overlay, got %q", r.fixed[shims])
+	}
+	out, err := exec.Command(r.exeFile).CombinedOutput()
+	if err != nil || string(out) != "4\n" {
+		t.Errorf("expected the program to run, got %q (%v)", out, err)
+	}
+	if _, err := os.Stat(shims); !os.IsNotExist(err) {
+		t.Errorf("expected the shim not to be written to disk, got %v", err)
+	}
+
+	// golo fix never writes shims, so the call is deferred (and main.go is left alone)
+	r = New("fix", false, []string{"."})
+	r.Options.ExportShims = true
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(r)
+	if _, err := r.writeFixes(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(shims); !os.IsNotExist(err) {
+		t.Errorf("expected golo fix not to write the shim, got %v", err)
+	}
+	if content, err := os.ReadFile("main.go"); err != nil || string(content) != main {
+		t.Errorf("expected main.go to be unchanged, got %q (%v)", content, err)
+	}
+}
+
+func TestRunner_ForwardsSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows can't send SIGTERM")
+	}
+	dir := writeModule(t, map[string]string{"main.go": `package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTERM)
+	os.WriteFile(os.Args[1], nil, 0o666)
+	<-c
+	os.Exit(3)
+}
+`})
+	chdir(t, dir)
+	ready := filepath.Join(dir, "ready")
+
+	r := New("run", false, []string{".", ready})
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(r)
+
+	go func() {
+		for {
+			if _, err := os.Stat(ready); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		p, _ := os.FindProcess(os.Getpid())
+		p.Signal(syscall.SIGTERM)
+	}()
+	status, err := r.Run()
+	if err != nil || status != 3 {
+		t.Errorf("expected the program to get SIGTERM and exit 3, got %d (%v)", status, err)
+	}
+	if _, err := os.Stat(r.tempDir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", r.tempDir, err)
+	}
+}
+
+func TestRunner_Stdin(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": `package main
+
+import (
+	"io"
+	"os"
+)
+
+func main() {
+	in, _ := io.ReadAll(os.Stdin)
+	os.WriteFile(os.Args[1], in, 0o666)
+}
+`})
+	chdir(t, dir)
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("hello\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	os.Stdin = stdin
+
+	output := filepath.Join(dir, "output.txt")
+	r := New("run", false, []string{".", output})
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(r)
+	if status, err := r.Run(); err != nil || status != 0 {
+		t.Fatalf("expected the program to run, got %d (%v)", status, err)
+	}
+	if out, err := os.ReadFile(output); err != nil || string(out) != "hello\n" {
+		t.Errorf("expected the program to read stdin, got %q (%v)", out, err)
+	}
+}
+
+func TestRunner_FixKeepsBOM(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go": "\ufeffpackage main; import \"os\"\n\nfunc main() {}\n",
+		"u.go":    "\xff\xfep\x00a\x00",
+	})
+	chdir(t, dir)
+
+	// UTF-16 files are reported, not fixed
+	r := New("fix", false, []string{"u.go"})
+	err := r.Prepare()
+	defer cleanup(r)
+	if err == nil || !strings.Contains(err.Error(), "golo: u.go is encoded as UTF-16") {
+		t.Errorf("expected an error about UTF-16, got %v", err)
+	}
+	if err := os.Remove("u.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	r = New("fix", false, []string{"."})
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(r)
+	if status, err := r.writeFixes(io.Discard); err != nil || status != 0 {
+		t.Fatalf("expected main.go to be fixed, got %d (%v)", status, err)
+	}
+	want := "\ufeffpackage main\n\nimport _ \"os\"\n\nfunc main() {}\n"
+	if content, err := os.ReadFile("main.go"); err != nil || string(content) != want {
+		t.Errorf("expected the byte order mark to be kept, got %q (%v)", content, err)
+	}
+}
+
+func TestRunner_UpdateOverlays(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	chdir(t, dir)
+	main := filepath.Join(dir, "main.go")
+
+	r := New("build", false, []string{"."})
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(r)
+	overlay := func() string {
+		if err := r.updateOverlays(); err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(r.overlays.Replace[main])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+
+	// the file is fixed in one iteration, and then fixed again in the next
+	r.fixed[main] = []byte("package main\n\nfunc main() { panic(1) }\n")
+	if got := overlay(); got != string(r.fixed[main]) {
+		t.Errorf("expected the first fix in the overlay, got %q", got)
+	}
+	r.fixed[main] = []byte("package main\n\nfunc main() { panic(2) }\n")
+	if got := overlay(); got != string(r.fixed[main]) {
+		t.Errorf("expected the second fix in the overlay, got %q", got)
+	}
+
+	// overlays are only rewritten when the fixed content changes
+	if err := os.WriteFile(r.overlays.Replace[main], []byte("unchanged"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if got := overlay(); got != "unchanged" {
+		t.Errorf("expected the overlay not to be rewritten, got %q", got)
+	}
+}
+
+func TestRunner_FixedTwice(t *testing.T) {
+	// the call is panicked in the first pass, which leaves a and b unused for the second
+	dir := writeModule(t, map[string]string{"main.go": `package main
+
+func main() {
+	a, b := c()
+
+	a.d(b)
+}
+
+func c() (string, string) {
+	return "a", "b"
+}
+`})
+	chdir(t, dir)
+	main := filepath.Join(dir, "main.go")
+
+	r := New("build", false, []string{"."})
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.built {
+		t.Fatal("expected the fixed program to build")
+	}
+	content, err := os.ReadFile(r.overlays.Replace[main])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, r.fixed[main]) {
+		t.Errorf("expected the overlay to contain the last fix\n## overlay ##\n%s\n## fixed ##\n%s", content, r.fixed[main])
+	}
+}
+
+func TestRunner_SkipsStuckPackages(t *testing.T) {
+	dir, err := filepath.Abs("../examples/stuck-package")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+
+	// stuck is broken in every build, but user is only broken once base is fixed
+	r := New("build", false, []string{"."})
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if r.built {
+		t.Fatal("expected stuck not to build")
+	}
+	for _, name := range []string{"base/base.go", "user/user.go"} {
+		if _, ok := r.fixed[filepath.Join(dir, name)]; !ok {
+			t.Errorf("expected %s to be fixed", name)
+		}
+	}
+	if len(r.unfixed) == 0 || !strings.Contains(r.unfixed[0].Msg, "initialization cycle") {
+		t.Errorf("expected the initialization cycle to be reported, got %v", r.unfixed)
+	}
+}
+
+func TestRunner_UnmatchedPatterns(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go":    "package main\n\nfunc main() {\n\tprintln(greeting)\n}\n",
+		"docs/notes": "no go files here\n",
+	})
+	chdir(t, dir)
+	report := filepath.Join(t.TempDir(), "report.json")
+
+	r := New("build", false, []string{".", "./nonexistent", "./docs/..."})
+	r.Options.ReportFile = report
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.built {
+		t.Fatal("expected the package that exists to be fixed")
+	}
+	if len(r.unmatched) != 2 || r.unmatched[0].Pattern != "./nonexistent" || r.unmatched[1].Err != "matched no packages" {
+		t.Errorf("expected both bad patterns to be unmatched, got %v", r.unmatched)
+	}
+	if code, err := r.Run(); err != nil || code != 1 {
+		t.Errorf("expected exit status 1 once the build succeeded, got %d %v", code, err)
+	}
+	rep, err := ReadReport(report)
+	if err != nil || len(rep.Unmatched) != 2 {
+		t.Errorf("expected the unmatched patterns in the report, got %v %v", rep, err)
+	}
+
+	// if nothing matches there is nothing to do
+	r = New("build", false, []string{"./nonexistent"})
+	defer cleanup(r)
+	if err := r.Prepare(); err == nil || !strings.Contains(err.Error(), "./nonexistent") {
+		t.Errorf("expected an error for the pattern, got %v", err)
+	}
+}
+
+func TestRunner_TestFlags(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go": "package main\n\nfunc main() {}\n",
+		"main_test.go": `package main
+
+import "testing"
+
+func TestFoo(t *testing.T) {}
+
+func TestBar(t *testing.T) {
+	t.Fatal("TestBar should not have run")
+}
+`,
+	})
+	chdir(t, dir)
+
+	// flags can come before or after the packages, and everything after -args is for the tests
+	r := New("test", false, []string{"-run", "TestFoo", "-tags", "x", "./...", "-v", "-args", "-race"})
+	if want := []string{"-tags", "x", "./..."}; !slices.Equal(r.buildArgs, want) {
+		t.Errorf("expected build args %v, got %v", want, r.buildArgs)
+	}
+	if want := []string{"-run", "TestFoo", "-v", "-args", "-race"}; !slices.Equal(r.runArgs, want) {
+		t.Errorf("expected test args %v, got %v", want, r.runArgs)
+	}
+
+	r = New("test", false, []string{"-run", "TestFoo", "-count=1", "-race", "."})
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := r.Run(); err != nil || code != 0 {
+		t.Errorf("expected only TestFoo to run, got %d %v", code, err)
+	}
+}
+
+func TestRunner_RunsTestBinary(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"pkg/pkg.go":            "package pkg\n",
+		"pkg/testdata/name.txt": "golo\n",
+		"pkg/pkg_test.go": `package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBinary(t *testing.T) {
+	// go test builds its own binary, called pkg.test
+	if !strings.HasPrefix(filepath.Base(os.Args[0]), "golo-") {
+		t.Fatalf("expected to run the binary golo built, got %s", os.Args[0])
+	}
+	if _, err := os.ReadFile("testdata/name.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	t.Fatal("TestSkipped should not have run")
+}
+`,
+	})
+	chdir(t, dir)
+
+	r := New("test", false, []string{"-run", "TestBinary", "-count=1", "./pkg"})
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := r.Run(); err != nil || code != 0 {
+		t.Errorf("expected TestBinary to pass, got %d %v", code, err)
+	}
+
+	for _, eg := range []struct {
+		flags []string
+		want  []string
+	}{
+		{[]string{"-run", "TestFoo", "-v"}, []string{"-test.paniconexit0", "-test.timeout=10m0s", "-test.run=TestFoo", "-test.v"}},
+		{[]string{"-timeout=1s", "-args", "-v", "x"}, []string{"-test.paniconexit0", "-test.timeout=1s", "-v", "x"}},
+		{[]string{"-json"}, nil},
+	} {
+		got, _ := testBinaryFlags(eg.flags)
+		if !slices.Equal(got, eg.want) {
+			t.Errorf("testBinaryFlags(%v): expected %v, got %v", eg.flags, eg.want, got)
+		}
+	}
+}
+
+func TestRunner_TestsMultiplePackages(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"a/a.go": "package a\n\nfunc A() string {\n\treturn undefinedA\n}\n",
+		"b/b.go": "package b\n\nfunc B() string {\n\treturn undefinedB\n}\n",
+		"c/c.go": "package c\n",
+		"c/c_test.go": `package c
+
+import "testing"
+
+func TestC(t *testing.T) {}
+`,
+	})
+	chdir(t, dir)
+
+	r := New("test", false, []string{"./..."})
+	defer cleanup(r)
+	out := &bytes.Buffer{}
+	r.Stdout, r.Stderr = out, out
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	// both broken packages are fixed, not just the first one go test -c stopped at
+	files := map[string]bool{}
+	for _, fix := range r.applied {
+		files[filepath.Base(fix.Pos.Filename)] = true
+	}
+	if !files["a.go"] || !files["b.go"] {
+		t.Errorf("expected fixes in a.go and b.go, got %v", r.applied)
+	}
+
+	if code, err := r.Run(); err != nil || code != 0 {
+		t.Errorf("expected the tests to pass, got %d %v\n%s", code, err, out)
+	}
+	if !strings.Contains(out.String(), "ok  \texample.com/m/c") {
+		t.Errorf("expected go test's output, got:\n%s", out)
+	}
+}
+
+func TestRunner_SelfImport(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go": "package main\n\nimport \"example.com/m/a\"\n\nfunc main() {\n\tprintln(a.Hello())\n}\n",
+		"a/a.go":  "package a\n\nimport \"example.com/m/a\"\n\nfunc Hello() string {\n\treturn a.World()\n}\n\nfunc World() string {\n\treturn \"hello\"\n}\n",
+	})
+	chdir(t, dir)
+
+	// go build reports the cycle without a "# example.com/m/a" header
+	r := New("build", false, []string{"-o", "app", "."})
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := r.Run(); err != nil || code != 0 {
+		t.Fatalf("golo build failed: %d %v", code, err)
+	}
+	if len(r.applied) != 1 || r.applied[0].Kind != Rewrite {
+		t.Errorf("expected the import to be removed, got %v", r.applied)
+	}
+}
+
+func TestRunner_TestRefactors(t *testing.T) {
+	greet := "package greet\n\nfunc Hello() string {\n\treturn \"hello\"\n}\n"
+	for name, test := range map[string]string{
+		// an external test (package greet_test) moved into the package, keeping the import
+		"external": "package greet\n\nimport (\n\t\"testing\"\n\n\t\"example.com/m/greet\"\n)\n\nfunc TestHello(t *testing.T) {\n\tif greet.Hello() != \"hello\" {\n\t\tt.Error(\"wrong greeting\")\n\t}\n}\n",
+		// an internal test whose import was removed, but not the qualifiers
+		"internal": "package greet\n\nimport \"testing\"\n\nfunc TestHello(t *testing.T) {\n\tif greet.Hello() != \"hello\" {\n\t\tt.Error(\"wrong greeting\")\n\t}\n}\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := writeModule(t, map[string]string{"greet/greet.go": greet, "greet/greet_test.go": test})
+			chdir(t, dir)
+
+			r := New("test", false, []string{"./greet"})
+			defer cleanup(r)
+			out := &bytes.Buffer{}
+			r.Stdout, r.Stderr = out, out
+			if err := r.Prepare(); err != nil {
+				t.Fatal(err)
+			}
+			if len(r.applied) == 0 || r.applied[0].Kind != Rewrite {
+				t.Errorf("expected the qualifiers to be removed, got %v", r.applied)
+			}
+			if code, err := r.Run(); err != nil || code != 0 {
+				t.Errorf("expected the tests to pass, got %d %v\n%s", code, err, out)
+			}
+		})
+	}
+}
+
+func TestRunner_Strict(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"internal/experiment/x.go": "package experiment\n\nfunc X() string {\n\treturn undefinedX()\n}\n",
+		"internal/payments/pay.go": "package payments\n\nfunc Pay() string {\n\treturn undefinedPay()\n}\n",
+	})
+	chdir(t, dir)
+
+	r := New("test", false, []string{"./..."})
+	defer cleanup(r)
+	out := &bytes.Buffer{}
+	r.Stdout, r.Stderr = out, out
+	r.Options.Strict = []string{"internal/payments/..."}
+	r.Options.ReportFile = "report.json"
+	err := r.Prepare()
+	var strict *StrictPackagesError
+	if !errors.As(err, &strict) {
+		t.Fatalf("expected the error in the strict package to fail, got %v", err)
+	}
+	expected := []StrictError{{"example.com/m/internal/payments", "internal/payments/pay.go:4:9", "undefined: undefinedPay", Strict}}
+	if !slices.Equal(strict.Errors, expected) {
+		t.Errorf("expected %v, got %v", expected, strict.Errors)
+	}
+	if _, ok := r.fixed[filepath.Join(dir, "internal", "payments", "pay.go")]; ok {
+		t.Errorf("expected the strict package not to be fixed")
+	}
+
+	report, err := ReadReport("report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(report.Strict, expected) {
+		t.Errorf("expected the report to include the strict errors, got %v", report.Strict)
+	}
+	for _, fix := range report.Fixes {
+		if fix.Policy != Lenient {
+			t.Errorf("expected fixes to be labeled lenient, got %v", fix)
+		}
+	}
+
+	// once the strict package builds, the lenient one is fixed as usual
+	if err := os.WriteFile(filepath.Join(dir, "internal", "payments", "pay.go"), []byte("package payments\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	r = New("test", false, []string{"./..."})
+	defer cleanup(r)
+	r.Stdout, r.Stderr = out, out
+	r.Options.Strict = []string{"internal/payments/..."}
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.applied) == 0 {
+		t.Errorf("expected the lenient package to be fixed")
+	}
+	for _, fix := range r.applied {
+		if fix.Policy != Lenient {
+			t.Errorf("expected fixes to be labeled lenient, got %v", fix)
+		}
+	}
+	if code, err := r.Run(); err != nil || code != 0 {
+		t.Errorf("expected go test to pass, got %d %v\n%s", code, err, out)
+	}
+}
+
+func TestRunner_BuildTags(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go":        "package main\n\nfunc main() {\n\tprintln(name())\n}\n\nfunc other() string {\n\treturn \"other\"\n}\n",
+		"name.go":        "//go:build !integration\n\npackage main\n\nfunc name() string {\n\treturn \"unit\"\n}\n",
+		"integration.go": "//go:build integration\n\npackage main\n\nfunc name() string {\n\treturn undefined()\n}\n",
+	})
+	chdir(t, dir)
+
+	for _, eg := range []struct {
+		args  []string
+		fixed bool
+	}{
+		{[]string{"."}, false},
+		{[]string{"-tags", "integration", "."}, true},
+		{[]string{"-tags=integration", "."}, true},
+	} {
+		r := New("build", false, append([]string{"-o", "app"}, eg.args...))
+		defer cleanup(r)
+		if err := r.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+		if code, err := r.Run(); err != nil || code != 0 {
+			t.Fatalf("golo build %v failed: %d %v", eg.args, code, err)
+		}
+		_, fixed := r.fixed[filepath.Join(dir, "integration.go")]
+		if fixed != eg.fixed || len(r.fixed) > 1 {
+			t.Errorf("golo build %v: expected integration.go to be fixed=%v, got %v", eg.args, eg.fixed, r.applied)
+		}
+	}
+}
+
+func TestRunner_CrossCompile(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go":        "package main\n\nfunc main() {\n\tprintln(name())\n}\n",
+		"name_linux.go":  "package main\n\nfunc name() string {\n\treturn undefinedLinux()\n}\n",
+		"name_darwin.go": "package main\n\nfunc name() string {\n\treturn undefinedDarwin()\n}\n",
+	})
+	chdir(t, dir)
+
+	// whichever platform the tests run on, the build is for the other one
+	target, other := "linux", "darwin"
+	if runtime.GOOS == "linux" {
+		target, other = "darwin", "linux"
+	}
+	t.Setenv("GOOS", target)
+	t.Setenv("GOARCH", "arm64")
+	t.Setenv("CGO_ENABLED", "0")
+	r := New("build", false, []string{"-o", "app", "."})
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.fixed[filepath.Join(dir, "name_"+target+".go")]; !ok {
+		t.Errorf("expected name_%s.go to be fixed, got %v", target, r.applied)
+	}
+	if _, ok := r.fixed[filepath.Join(dir, "name_"+other+".go")]; ok {
+		t.Errorf("expected name_%s.go not to be fixed, as it isn't part of the build", other)
+	}
+
+	// the package with the most files in the target's build wins a clash
+	dir = writeModule(t, map[string]string{
+		"main.go":                       "package main\n\nimport \"example.com/m/lib\"\n\nfunc main() {\n\tprintln(lib.Name())\n}\n",
+		"lib/lib.go":                    "package lib\n\nfunc Name() string {\n\treturn \"lib\"\n}\n",
+		"lib/scratch_" + target + ".go": "package main\n\nfunc main() {}\n",
+		"lib/a_" + other + ".go":        "package other\n",
+		"lib/b_" + other + ".go":        "package other\n",
+	})
+	chdir(t, dir)
+	r = New("build", false, []string{"-o", "app", "."})
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.fixed[filepath.Join(dir, "lib", "lib.go")]; ok {
+		t.Errorf("expected package lib to win, got %v", r.applied)
+	}
+}
+
+func TestRunner_FilesFrom(t *testing.T) {
+	// a GOPATH-shaped tree (as a build system like bazel might stage it), with no go.mod
+	dir := t.TempDir()
+	files := map[string]string{
+		"deps/src/example.com/greet/greet.go": "package greet\n\nfunc Hello() string {\n\treturn \"hello\"\n}\n",
+		"app/main.go":                         "package main\n\nimport \"example.com/greet\"\n\nfunc main() {\n\tprintln(greet.Hello())\n\tundefined()\n}\n",
+		// not part of the target, so not built
+		"app/other.go":  "package other\n",
+		"manifest.json": `{"ImportPath": "example.com/app", "Files": ["app/main.go"], "GOPATH": ["deps"]}`,
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chdir(t, dir)
+
+	r := New("run", false, []string{"arg"})
+	r.Options.FilesFrom = filepath.Join(dir, "manifest.json")
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.built || len(r.applied) != 1 {
+		t.Fatalf("expected the call to be deferred, got %v", r.applied)
+	}
+	if !slices.Equal(r.buildArgs, []string{"example.com/app"}) || !slices.Equal(r.runArgs, []string{"arg"}) {
+		t.Errorf("expected to run example.com/app with arg, got %v %v", r.buildArgs, r.runArgs)
+	}
+	content, err := os.ReadFile(r.applied[0].Pos.Filename)
+	if err != nil || !strings.Contains(string(content), "undefined()") {
+		t.Errorf("expected the error to be in a link to main.go, got %q (%v)", content, err)
+	}
+}
+
+func TestRunner_Build(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go": "package main\n\nfunc main() {\n\tprintln(undefined())\n}\n",
+	})
+	chdir(t, dir)
+
+	r := New("run", false, []string{"."})
+	defer cleanup(r)
+	out, log := &bytes.Buffer{}, &bytes.Buffer{}
+	r.Stdout, r.Stderr = out, out
+	r.Log = log
+	res, err := r.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(res.Binary); err != nil || info.Size() == 0 {
+		t.Errorf("expected a binary, got %q (%v)", res.Binary, err)
+	}
+	if res.Overlay == "" || !strings.Contains(string(res.Fixed[filepath.Join(dir, "main.go")]), "undefined") {
+		t.Errorf("expected main.go to be fixed in an overlay, got %q %v", res.Overlay, res.Fixed)
+	}
+	if len(res.Deferred) != 1 || res.Deferred[0].Msg != "undefined: undefined" || len(res.Report.Fixes) != 1 {
+		t.Errorf("expected the call to be deferred, got %v", res.Deferred)
+	}
+	if out.Len() > 0 || !strings.Contains(log.String(), "golo:") {
+		t.Errorf("expected golo's messages to go to Log, got %q and %q", out, log)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New("run", false, []string{"."}).Build(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled build to fail, got %v", err)
+	}
+}
+
+func TestRunner_Cancel(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go": "package main\n\nimport \"os\"\n\nfunc main() {\n\tos.Stdin.Read(make([]byte, 1))\n}\n",
+		// so that the build is still running when the context is done
+		"slow.sh": "#!/bin/sh\nsleep 10\nexec \"$@\"\n",
+	})
+	if err := os.Chmod(filepath.Join(dir, "slow.sh"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+
+	r := New("run", false, []string{"-toolexec=" + filepath.Join(dir, "slow.sh"), "."})
+	defer cleanup(r)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := r.PrepareContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Prepare to stop at the deadline, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("expected Prepare to stop the build, took %v", time.Since(start))
+	}
+	if _, err := os.Stat(r.tempDir); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary files to be removed, got %v", err)
+	}
+
+	// the program waits for input that never comes until it is interrupted
+	r = New("run", false, []string{"."})
+	defer cleanup(r)
+	stdin, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	defer w.Close()
+	r.Stdin = stdin
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if code, err := r.RunContext(ctx); err != nil || code == 0 {
+		t.Errorf("expected the program to be interrupted, got %d %v", code, err)
+	}
+	if time.Since(start) > stopTimeout {
+		t.Errorf("expected the program to be interrupted, took %v", time.Since(start))
+	}
+}
+
+// Before Go 1.24, go test -c accepts -json but prints the errors as it would without it.
+func TestRunner_ProbeWithoutBuildEvents(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake go command is a shell script")
+	}
+	dir := writeModule(t, map[string]string{
+		"go": "#!/bin/sh\necho '# example.com/m [example.com/m.test]' >&2\necho './m_test.go:3:2: undefined: x' >&2\nexit 1\n",
+	})
+	if err := os.Chmod(filepath.Join(dir, "go"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	r := New("test", false, []string{"."})
+	pkgs, err := r.probe([]string{"test", "-c", "."})
+	if err != nil || !slices.Equal(pkgs, []string{"example.com/m"}) {
+		t.Errorf("expected example.com/m to be fixed, got %v (%v)", pkgs, err)
+	}
+}
+
+func TestRunner_Close(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go": "package main\n\nfunc main() {\n\tprintln(undefined())\n}\n",
+	})
+	chdir(t, dir)
+
+	// Prepare fails, so Run never removes the temporary files
+	r := New("run", false, []string{"."})
+	r.Stdout = io.Discard
+	r.Options.Strict = []string{"..."}
+	if err := r.Prepare(); err == nil {
+		t.Fatal("expected the strict package to fail")
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(r.tempDir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", r.tempDir, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("expected closing again to do nothing, got %v", err)
+	}
+
+	r = New("run", true, []string{"."})
+	out := &bytes.Buffer{}
+	r.Stdout, r.Stderr = io.Discard, out
+	r.Options.Keep = true
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := r.Run(); err != nil || code == 0 {
+		t.Fatalf("expected the deferred error to panic, got %d %v", code, err)
+	}
+	r.Close()
+	if _, err := os.Stat(r.exeFile); err != nil {
+		t.Errorf("expected the binary to be kept, got %v", err)
+	}
+	if !strings.Contains(out.String(), "golo: kept temporary files in "+r.tempDir) {
+		t.Errorf("expected to be told where the files are, got %q", out)
+	}
+}
+
+func TestRunner_ExcludesBrokenTests(t *testing.T) {
+	test := func(pkg string) string {
+		return "package " + pkg + "\n\nimport \"testing\"\n\nfunc TestRan(t *testing.T) {\n\tt.Log(\"ran " + pkg + "\")\n}\n"
+	}
+	dir := writeModule(t, map[string]string{
+		"a/a.go":             "package a\n",
+		"a/a_test.go":        test("a"),
+		"b/b.go":             "package b\n\nfunc B() string {\n\treturn undefinedB()\n}\n",
+		"b/b_test.go":        test("b"),
+		"hopeless/cycle.go":  "package hopeless\n\nvar x = y + \"x\"\nvar y = x + \"y\"\n",
+		"hopeless/h_test.go": test("hopeless"),
+	})
+	chdir(t, dir)
+
+	r := New("test", false, []string{"-v", "./..."})
+	defer cleanup(r)
+	out := &bytes.Buffer{}
+	r.Stdout, r.Stderr = out, out
+	r.Options.ReportFile = "report.json"
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.built || !slices.Equal(r.excluded, []string{"example.com/m/hopeless"}) {
+		t.Fatalf("expected the tests of hopeless to be skipped, got %v\n%s", r.excluded, out)
+	}
+	if code, err := r.Run(); err != nil || code != 1 {
+		t.Errorf("expected the skipped tests to fail the run, got %d %v", code, err)
+	}
+	for _, want := range []string{"golo: skipping the tests in example.com/m/hopeless", "ran a", "ran b"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the output, got\n%s", want, out)
+		}
+	}
+	if strings.Contains(out.String(), "ran hopeless") {
+		t.Errorf("expected the tests of hopeless not to run, got\n%s", out)
+	}
+	report, err := ReadReport("report.json")
+	if err != nil || !slices.Equal(report.Excluded, r.excluded) {
+		t.Errorf("expected the report to list the skipped package, got %v (%v)", report, err)
+	}
+
+	// when building, there is nothing to skip
+	r = New("build", false, []string{"./hopeless"})
+	defer cleanup(r)
+	out = &bytes.Buffer{}
+	r.Stdout, r.Stderr = out, out
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := r.Run(); err != nil || code != 1 || !strings.Contains(out.String(), "golo: could not fix or defer the errors in example.com/m/hopeless") {
+		t.Errorf("expected to be told which package failed, got %d %v\n%s", code, err, out)
+	}
+}
+
+func TestNew_RunFiles(t *testing.T) {
+	// the .go suffix is matched case-insensitively, as file names are on Windows and macOS
+	r := New("run", false, []string{"-race", "main.go", "Util.GO", "arg.go.txt", "x.go"})
+	if want := []string{"-race", "main.go", "Util.GO"}; !slices.Equal(r.buildArgs, want) {
+		t.Errorf("expected build args %v, got %v", want, r.buildArgs)
+	}
+	if want := []string{"arg.go.txt", "x.go"}; !slices.Equal(r.runArgs, want) {
+		t.Errorf("expected run args %v, got %v", want, r.runArgs)
+	}
+	if isGoFile(".go") || isGoFile("go") {
+		t.Errorf("expected .go and go not to be Go files")
+	}
+}
+
+func TestDecodeBuildEvents(t *testing.T) {
+	out := `{"ImportPath":"example.com/m/a","Action":"build-output","Output":"# example.com/m/a\n"}
+{"ImportPath":"example.com/m/a","Action":"build-output","Output":"a/a.go:3:2: undefined: x\n"}
+{"ImportPath":"example.com/m/a","Action":"build-fail"}
+{"ImportPath":"example.com/m/b [example.com/m/b.test]","Action":"build-output","Output":"# example.com/m/b [example.com/m/b.test]\n"}
+{"ImportPath":"example.com/m/b [example.com/m/b.test]","Action":"build-output","Output":"b/b_test.go:5:1: missing return\n"}
+{"ImportPath":"example.com/m/b [example.com/m/b.test]","Action":"build-fail"}
+# not a package
+`
+	failed, text := decodeBuildEvents([]byte(out))
+	if want := []string{"example.com/m/a", "example.com/m/b"}; !slices.Equal(failed, want) {
+		t.Errorf("expected %v to have failed, got %v", want, failed)
+	}
+	want := "# example.com/m/a\na/a.go:3:2: undefined: x\n# example.com/m/b [example.com/m/b.test]\nb/b_test.go:5:1: missing return\n# not a package\n"
+	if string(text) != want {
+		t.Errorf("expected the output to be %q, got %q", want, text)
+	}
+}
+
+func TestRunner_NotMainPackage(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"cmd/tool/tool.go": "package tool\n\nfunc Run() {\n\tprintln(\"ran\")\n}\n",
+	})
+	chdir(t, dir)
+
+	for _, guess := range []bool{false, true} {
+		r := New("run", false, []string{"./cmd/tool"})
+		defer cleanup(r)
+		r.Options.GuessMain = guess
+		out := &bytes.Buffer{}
+		r.Stdout, r.Stderr = out, out
+		if err := r.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+		kinds := []FixKind{}
+		for _, fix := range r.applied {
+			kinds = append(kinds, fix.Kind)
+		}
+		want, wantCode, wantOut := []FixKind{Rewrite, Deferred}, 2, "golo: func main is missing"
+		if guess {
+			want, wantCode, wantOut = []FixKind{Rewrite, Declared}, 0, "ran"
+		}
+		if !slices.Equal(kinds, want) {
+			t.Errorf("expected %v with -guess-main=%v, got %v", want, guess, r.applied)
+		}
+		if code, err := r.Run(); err != nil || code != wantCode || !strings.Contains(out.String(), wantOut) {
+			t.Errorf("expected %q and exit status %d with -guess-main=%v, got %d %v\n%s", wantOut, wantCode, guess, code, err, out)
+		}
+	}
+}
+
+func TestRunner_StableOverlays(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": "package main\n\nfunc main() {\n\tx := \"unused\"\n\tprintln(\"hello\")\n}\n"})
+	chdir(t, dir)
+	main := filepath.Join(dir, "main.go")
+
+	// the same fixes are written to the same files each time, so the go command sees the same overlay
+	overlays := []string{}
+	for i := 0; i < 2; i++ {
+		r := New("build", false, []string{"-o", os.DevNull, "."})
+		r.Stdout = io.Discard
+		defer cleanup(r)
+		if err := r.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(r.overlays.Replace[main], r.overlayDir) || strings.HasPrefix(r.overlayFile, r.tempDir) {
+			t.Errorf("expected the overlay to be outside the temporary directory, got %s", r.overlayFile)
+		}
+		overlays = append(overlays, r.overlays.Replace[main], r.overlayFile)
+	}
+	if overlays[0] != overlays[2] || overlays[1] != overlays[3] {
+		t.Errorf("expected the same overlay each time, got %v", overlays)
+	}
+}
+
+func TestRunner_FixCache(t *testing.T) {
+	cache := t.TempDir()
+	defer func(dir func() (string, error)) { userCacheDir = dir }(userCacheDir)
+	userCacheDir = func() (string, error) { return cache, nil }
+	dir := writeModule(t, map[string]string{"main.go": "package main\n\nfunc main() {\n\tx := \"unused\"\n\tprintln(\"hello\")\n}\n"})
+	chdir(t, dir)
+
+	prepare := func(options Options) string {
+		t.Helper()
+		out := &bytes.Buffer{}
+		r := New("build", false, []string{"-o", os.DevNull, "."})
+		r.Options = options
+		r.Stdout, r.Stderr = out, out
+		defer cleanup(r)
+		if err := r.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+		if len(r.applied) != 1 {
+			t.Errorf("expected 1 fix, got %v", r.applied)
+		}
+		return out.String()
+	}
+
+	if out := prepare(Options{}); strings.Contains(out, "from the cache") {
+		t.Errorf("expected the first run to fix the error, got:\n%s", out)
+	}
+	out := prepare(Options{})
+	if !strings.Contains(out, "reusing 1 fixes from the cache") || !strings.Contains(out, "main.go:4:2:") {
+		t.Errorf("expected the second run to reuse the fix, got:\n%s", out)
+	}
+	if out := prepare(Options{NoCache: true}); strings.Contains(out, "from the cache") {
+		t.Errorf("expected -nocache to fix the error again, got:\n%s", out)
+	}
+
+	// the same error in a different file isn't fixed in the same way
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n\tx := \"unused\"\n\tprintln(\"goodbye\")\n}\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if out := prepare(Options{}); strings.Contains(out, "from the cache") {
+		t.Errorf("expected a changed file to be fixed again, got:\n%s", out)
+	}
+}
@@ -0,0 +1,149 @@
+package golo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sandbox restricts where golo writes files. Every file that golo writes is written by a
+// sandbox method, which panics if the file is not inside one of the allowed paths
+// (the runner's temporary directory, and the outputs the user asked for).
+// golo never writes to the source files it is fixing, except in fix mode.
+//
+// The one exception is Options.RunGenerators: go generate runs arbitrary commands, which
+// write wherever they like (usually over the generated files in the module), so they can't
+// be kept in the sandbox or redirected to the overlay. That is why it is off unless the user
+// passes -run-generators.
+//
+// If GOLO_ENFORCE_SANDBOX=1, every path written is also recorded (and printed to stderr)
+// so that tests can check what was written.
+type sandbox struct {
+	allowed []string
+	enforce bool
+
+	mu      sync.Mutex
+	written []string
+}
+
+// newSandbox returns a sandbox that allows writes inside the given paths.
+// Empty paths are ignored.
+func newSandbox(paths ...string) *sandbox {
+	s := &sandbox{enforce: os.Getenv("GOLO_ENFORCE_SANDBOX") == "1"}
+	for _, p := range paths {
+		if p != "" {
+			s.allowed = append(s.allowed, resolvePath(p))
+		}
+	}
+	return s
+}
+
+// resolvePath returns the absolute path of name with any symlinks in it resolved
+// (even if name itself does not exist yet).
+func resolvePath(name string) string {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return filepath.Clean(name)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	dir := filepath.Dir(abs)
+	if dir == abs {
+		return abs
+	}
+	return filepath.Join(resolvePath(dir), filepath.Base(abs))
+}
+
+// allow allows writes to path, once golo knows it is to write there (like the files that fix
+// mode overwrites, or the binary that golo build writes to -o).
+func (s *sandbox) allow(path string) {
+	s.allowed = append(s.allowed, resolvePath(path))
+}
+
+// check panics if golo is not allowed to write to name.
+func (s *sandbox) check(name string) {
+	path := resolvePath(name)
+	for _, dir := range s.allowed {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return
+		}
+	}
+	panic(fmt.Sprintf("golo: refusing to write %s, as it is not in %s", name, strings.Join(s.allowed, " or ")))
+}
+
+// record records that name was written, if GOLO_ENFORCE_SANDBOX=1.
+func (s *sandbox) record(name string) {
+	if !s.enforce {
+		return
+	}
+	path := resolvePath(name)
+	s.mu.Lock()
+	s.written = append(s.written, path)
+	s.mu.Unlock()
+	fmt.Fprintln(os.Stderr, "golo: sandbox: writing "+path)
+}
+
+func (s *sandbox) writeFile(name string, content []byte, perm os.FileMode) error {
+	s.check(name)
+	s.record(name)
+	return os.WriteFile(name, content, perm)
+}
+
+func (s *sandbox) openFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	s.check(name)
+	s.record(name)
+	return os.OpenFile(name, flag, perm)
+}
+
+func (s *sandbox) createTemp(dir, pattern string) (*os.File, error) {
+	s.check(dir)
+	f, err := os.CreateTemp(dir, pattern)
+	if err == nil {
+		s.record(f.Name())
+	}
+	return f, err
+}
+
+func (s *sandbox) mkdirTemp(dir, pattern string) (string, error) {
+	s.check(dir)
+	name, err := os.MkdirTemp(dir, pattern)
+	if err == nil {
+		s.record(name)
+	}
+	return name, err
+}
+
+func (s *sandbox) mkdirAll(dir string, perm os.FileMode) error {
+	s.check(dir)
+	s.record(dir)
+	return os.MkdirAll(dir, perm)
+}
+
+func (s *sandbox) rename(from, to string) error {
+	s.check(from)
+	s.check(to)
+	s.record(to)
+	return os.Rename(from, to)
+}
+
+func (s *sandbox) chmod(name string, mode os.FileMode) error {
+	s.check(name)
+	s.record(name)
+	return os.Chmod(name, mode)
+}
+
+func (s *sandbox) chtimes(name string, t time.Time) error {
+	s.check(name)
+	s.record(name)
+	return os.Chtimes(name, t, t)
+}
+
+func (s *sandbox) symlink(from, to string) error {
+	s.check(to)
+	s.record(to)
+	return os.Symlink(from, to)
+}
@@ -0,0 +1,91 @@
+package golo
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func expectBlocked(t *testing.T, name string, write func()) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "refusing to write") {
+			t.Errorf("expected writing %s to be blocked, got %v", name, r)
+		}
+		if _, err := os.Stat(name); err == nil {
+			t.Errorf("expected %s not to be written", name)
+		}
+	}()
+	write()
+}
+
+func TestSandbox(t *testing.T) {
+	root := t.TempDir()
+	allowed := filepath.Join(root, "allowed")
+	if err := os.Mkdir(allowed, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	s := newSandbox(allowed)
+
+	if err := s.writeFile(filepath.Join(allowed, "ok.go"), nil, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	outside := filepath.Join(root, "outside.go")
+	expectBlocked(t, outside, func() { s.writeFile(filepath.Join(allowed, "..", "outside.go"), nil, 0o666) })
+	expectBlocked(t, outside, func() { s.writeFile(allowed+"/../../"+filepath.Base(root)+"/outside.go", nil, 0o666) })
+
+	// a symlink inside the sandbox doesn't let golo write outside it
+	if err := os.Symlink(root, filepath.Join(allowed, "link")); err != nil {
+		t.Skip(err)
+	}
+	expectBlocked(t, outside, func() { s.writeFile(filepath.Join(allowed, "link", "outside.go"), nil, 0o666) })
+}
+
+func TestRunner_Sandbox(t *testing.T) {
+	t.Setenv("GOLO_ENFORCE_SANDBOX", "1")
+	dir := writeModule(t, map[string]string{"main.go": "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(1 + \"a\")\n}\n"})
+	chdir(t, dir)
+
+	r := New("build", false, []string{"-o", os.DevNull, "."})
+	r.Options.ReportFile = filepath.Join(t.TempDir(), "report.json")
+	defer cleanup(r)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.built || len(r.sandbox.written) == 0 {
+		t.Fatalf("expected the fixed files to be built, and the writes recorded")
+	}
+	dirs := []string{resolvePath(r.tempDir), resolvePath(r.overlayDir)}
+	if r.fixCache != "" {
+		dirs = append(dirs, resolvePath(r.fixCache))
+	}
+	for _, path := range r.sandbox.written {
+		inDir := false
+		for _, dir := range dirs {
+			inDir = inDir || path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+		}
+		if !inDir && path != resolvePath(r.Options.ReportFile) {
+			t.Errorf("unexpected write to %s", path)
+		}
+	}
+
+	// a malicious overlay directory can't be used to write outside the sandbox
+	main := filepath.Join(dir, "main.go")
+	r.overlayDir = filepath.Join(r.tempDir, "..", "..", dir)
+	evil := r.overlayPath(main, sha256.Sum256(r.fixed[main]))
+	expectBlocked(t, evil, func() { r.updateOverlays() })
+
+	// nor can golo overwrite the source files
+	r.overlayDir = dir
+	defer func() {
+		recover()
+		if content, err := os.ReadFile(main); err != nil || strings.Contains(string(content), "panic") {
+			t.Errorf("expected %s not to be changed, got %s", main, content)
+		}
+	}()
+	r.updateOverlays()
+	t.Errorf("expected writing to %s to be blocked", dir)
+}
@@ -0,0 +1,60 @@
+package golo
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// stopTimeout is how long Stop waits for the command to exit after interrupting it.
+const stopTimeout = 5 * time.Second
+
+// forwardedSignals are the signals that golo passes on to the command it runs.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// forwardSignals starts cmd, and forwards signals that golo gets to it (instead of letting them
+// kill golo) until the returned function is called, so that golo can wait for it to exit and
+// clean up afterwards.
+func forwardSignals(cmd *exec.Cmd) (func(), error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	restore := startProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		signal.Stop(signals)
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for sig := range signals {
+			signalProcess(cmd, sig)
+		}
+		close(done)
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+		<-done
+		restore()
+	}, nil
+}
+
+// Stop stops the command that Run is running, if there is one, so that Run returns (with its
+// exit status). It is interrupted first, and killed if it hasn't exited after stopTimeout.
+func (r *Runner) Stop() {
+	r.mu.Lock()
+	cmd, exited := r.running, r.exited
+	r.mu.Unlock()
+	if cmd == nil {
+		return
+	}
+	signalProcess(cmd, os.Interrupt)
+	select {
+	case <-exited:
+	case <-time.After(stopTimeout):
+		signalProcess(cmd, os.Kill)
+		<-exited
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package golo
+
+import (
+	"os"
+	"os/exec"
+)
+
+// startProcessGroup does nothing on platforms without process groups.
+func startProcessGroup(cmd *exec.Cmd) func() {
+	return func() {}
+}
+
+// signalProcess sends sig to cmd's process, or kills it if sig can't be sent (as on Windows).
+func signalProcess(cmd *exec.Cmd, sig os.Signal) {
+	if err := cmd.Process.Signal(sig); err != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// processExists returns true if there is a process with the given ID (on Windows, finding a
+// process fails if it has exited).
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,55 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package golo
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// startProcessGroup makes cmd start in its own process group, so that signals reach
+// everything it runs (go run and go test run the program in a child process of their own).
+//
+// If golo is in the foreground of a terminal, the new process group is made the foreground
+// instead, so that the command can still read from the terminal (and gets ctrl-C from it
+// directly). The returned function gives the terminal back to golo once cmd has exited.
+func startProcessGroup(cmd *exec.Cmd) func() {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// the command can only be given the terminal if it is reading from golo's stdin
+	if cmd.Stdin != os.Stdin {
+		return func() {}
+	}
+	fd := os.Stdin.Fd()
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp))); errno != 0 || int(pgrp) != syscall.Getpgrp() {
+		// stdin is not a terminal, or golo is running in the background
+		return func() {}
+	}
+	cmd.SysProcAttr.Foreground = true
+	cmd.SysProcAttr.Ctty = int(fd)
+	return func() {
+		// golo is not in the foreground until this succeeds, so SIGTTOU would stop it
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+		syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&pgrp)))
+	}
+}
+
+// signalProcess sends sig to cmd's process group.
+func signalProcess(cmd *exec.Cmd, sig os.Signal) {
+	if s, ok := sig.(syscall.Signal); ok {
+		syscall.Kill(-cmd.Process.Pid, s)
+		return
+	}
+	cmd.Process.Signal(sig)
+}
+
+// processExists returns true if there is a process with the given ID.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
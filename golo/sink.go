@@ -0,0 +1,146 @@
+package golo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A Sink is told what golo fixed once Prepare has finished. A Runner reports to all of its
+// sinks, so one run can (for example) print a summary, write a JSON report, and write
+// GitHub annotations.
+type Sink interface {
+	Report(o *Outcome) error
+}
+
+// Outcome is what happened when golo fixed the code.
+type Outcome struct {
+	// Report lists the fixes (with filenames relative to the current directory).
+	Report *Report
+	// FilesChanged is the number of files that were fixed.
+	FilesChanged int
+	// Baseline is the report named by Options.Baseline, if it was set.
+	Baseline *Report
+	// Added contains the deferrals that are not in the Baseline.
+	Added []Fix
+	// Unfixed contains the errors that golo could not fix.
+	Unfixed []packages.Error
+}
+
+type summarySink struct{ w io.Writer }
+
+// NewSummarySink returns a sink that writes a one-line summary of the fixes to w
+// (as golo does by default).
+func NewSummarySink(w io.Writer) Sink {
+	return summarySink{w}
+}
+
+func (s summarySink) Report(o *Outcome) error {
+	if len(o.Report.Fixes) == 0 && o.Baseline == nil {
+		return nil
+	}
+	_, err := fmt.Fprintln(s.w, o.Report.summary(o.FilesChanged, o.Baseline))
+	return err
+}
+
+type jsonSink struct{ w io.Writer }
+
+// NewJSONSink returns a sink that writes the Report to w as JSON (see ReadReport).
+func NewJSONSink(w io.Writer) Sink {
+	return jsonSink{w}
+}
+
+func (s jsonSink) Report(o *Outcome) error {
+	content, err := o.Report.marshal()
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(content)
+	return err
+}
+
+type githubSink struct{ w io.Writer }
+
+// NewGitHubSink returns a sink that writes GitHub Actions annotations for the fixes to w,
+// in the same way as ci mode.
+func NewGitHubSink(w io.Writer) Sink {
+	return githubSink{w}
+}
+
+func (s githubSink) Report(o *Outcome) error {
+	writeAnnotations(s.w, o)
+	return nil
+}
+
+// DeltaSink is a sink for running golo again and again (as golo run -w and golo retry do),
+// that writes what changed in the deferrals since the last run instead of every fix.
+type DeltaSink struct {
+	w     io.Writer
+	last  *Report
+	files int // the number of files changed in the last run
+}
+
+// NewDeltaSink returns a DeltaSink that writes to w.
+func NewDeltaSink(w io.Writer) *DeltaSink {
+	return &DeltaSink{w: w}
+}
+
+// Attach makes r report to s. After the first run, r no longer writes each fix as it makes it.
+func (s *DeltaSink) Attach(r *Runner) {
+	r.AddSink(s)
+	if s.last != nil {
+		r.FixLog = io.Discard
+	}
+}
+
+func (s *DeltaSink) Report(o *Outcome) error {
+	last := s.last
+	s.last, s.files = o.Report, o.FilesChanged
+	if last == nil {
+		return nil
+	}
+	diff := DiffReports(last, o.Report)
+	if len(diff.Added)+len(diff.Removed) == 0 && len(o.Report.Fixes) == 0 {
+		return nil
+	}
+	for _, fix := range diff.Added {
+		fmt.Fprintf(s.w, "golo: new deferral: %s: %s\n", fix.Pos, fix.Msg)
+	}
+	for _, fix := range diff.Removed {
+		fmt.Fprintf(s.w, "golo: resolved: %s: %s\n", fix.Pos, fix.Msg)
+	}
+	_, err := fmt.Fprintln(s.w, "golo: "+diff.String())
+	return err
+}
+
+// WriteList writes every fix that was made in the last run to w, as golo writes them while fixing.
+func (s *DeltaSink) WriteList(w io.Writer) {
+	if s.last == nil || len(s.last.Fixes) == 0 {
+		fmt.Fprintln(w, "golo: nothing was fixed")
+		return
+	}
+	for _, fix := range s.last.Fixes {
+		fmt.Fprintf(w, "golo: %s: %s\n", fix.Pos, fix.Msg)
+		if fix.Note != "" {
+			fmt.Fprintf(w, "golo: note: %s\n", fix.Note)
+		}
+	}
+	fmt.Fprintln(w, s.last.summary(s.files, nil))
+}
+
+// fileSink writes the output of a sink to a file, once it is complete.
+type fileSink struct {
+	name    string
+	sandbox *sandbox
+	sink    func(w io.Writer) Sink
+}
+
+func (s fileSink) Report(o *Outcome) error {
+	buf := &bytes.Buffer{}
+	if err := s.sink(buf).Report(o); err != nil {
+		return err
+	}
+	return s.sandbox.writeFile(s.name, buf.Bytes(), 0o666)
+}
@@ -0,0 +1,94 @@
+package golo
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// speculate applies a fix that guesses at what the code should have been, but only if
+// type-checking pkg with the new content for filename results in fewer errors than before.
+// This lets golo try fixes that are better than deferring the error when they work,
+// without making things worse when they don't.
+func (f *Fixer) speculate(pkg *packages.Package, filename string, content ...[]byte) bool {
+	previous, wasFixed := f.Fixed[filename]
+	spilled, wasSpilled := f.spilled[filename]
+	f.update(filename, content...)
+
+	errs, ok := recheck(pkg, filename, f.Fixed[filename])
+	if ok && len(errs) < len(pkg.TypeErrors) {
+		return true
+	}
+
+	if f.verbose {
+		f.logf("golo: speculative fix to %s didn't help: %v", filename, errs)
+	}
+	if wasFixed {
+		f.Fixed[filename] = previous
+	} else {
+		delete(f.Fixed, filename)
+	}
+	if wasSpilled {
+		f.spilled[filename] = spilled
+	}
+	return false
+}
+
+// recheck type-checks pkg with the content of filename replaced, and returns the errors.
+// It returns false if the package could not be checked (for example if filename is not part of it).
+func recheck(pkg *packages.Package, filename string, content []byte) ([]types.Error, bool) {
+	if pkg.Types == nil || pkg.Fset == nil {
+		return nil, false
+	}
+
+	file, err := parser.ParseFile(pkg.Fset, filename, content, 0)
+	if err != nil {
+		return nil, false
+	}
+
+	files := []*ast.File{}
+	found := false
+	for _, f := range pkg.Syntax {
+		if pkg.Fset.File(f.Pos()).Name() == filename {
+			files = append(files, file)
+			found = true
+		} else {
+			files = append(files, f)
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	imports := map[string]*types.Package{}
+	for _, imp := range pkg.Types.Imports() {
+		imports[imp.Path()] = imp
+	}
+	fallback := importer.Default()
+
+	errs := []types.Error{}
+	config := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if imp, ok := imports[path]; ok {
+				return imp, nil
+			}
+			return fallback.Import(path)
+		}),
+		Error: func(err error) {
+			if e, ok := err.(types.Error); ok {
+				errs = append(errs, e)
+			}
+		},
+	}
+	config.Check(pkg.PkgPath, pkg.Fset, files, nil)
+	return errs, true
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (i importerFunc) Import(path string) (*types.Package, error) {
+	return i(path)
+}
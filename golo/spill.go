@@ -0,0 +1,125 @@
+package golo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// spillThreshold is the size above which fixed files are written to disk at the end of
+// each iteration instead of being kept in memory. (If golo is using more than
+// Options.MaxMemory, every fixed file is written to disk.)
+const spillThreshold = 256 << 10
+
+// spilledFile is a fixed file whose content is on disk instead of in Fixer.Fixed.
+type spilledFile struct {
+	Path string
+	Hash [sha256.Size]byte
+}
+
+// spill moves fixed files from memory into f.spillDir, so that fixing very large
+// repositories doesn't keep a copy of every file in memory for the life of the process.
+// The files are read back if they need fixing again.
+func (f *Fixer) spill() error {
+	if f.spillDir == "" {
+		return nil
+	}
+	threshold := spillThreshold
+	if f.options.MaxMemory > 0 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > uint64(f.options.MaxMemory) {
+			threshold = 0
+		}
+	}
+
+	for filename, content := range f.Fixed {
+		if len(content) < threshold {
+			continue
+		}
+		if err := f.spillFile(filename, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spillFile writes the fixed content of filename to f.spillDir instead of keeping it in memory.
+func (f *Fixer) spillFile(filename string, content []byte) error {
+	if f.spilled == nil {
+		f.spilled = map[string]spilledFile{}
+	}
+	s := spilledFile{Path: f.spillPath(filename), Hash: sha256.Sum256(content)}
+	if err := f.sandbox.writeFile(s.Path, content, 0o666); err != nil {
+		return err
+	}
+	f.spilled[filename] = s
+	delete(f.Fixed, filename)
+	return nil
+}
+
+// spillPath returns where filename is written to when it is spilled.
+func (f *Fixer) spillPath(filename string) string {
+	sum := sha256.Sum256([]byte(filename))
+	return filepath.Join(f.spillDir, hex.EncodeToString(sum[:8])+"-"+filepath.Base(filename))
+}
+
+// readSpilled returns the content of a spilled file.
+func (f *Fixer) readSpilled(filename string) ([]byte, bool, error) {
+	s, ok := f.spilled[filename]
+	if !ok {
+		return nil, false, nil
+	}
+	content, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, true, err
+	}
+	if sha256.Sum256(content) != s.Hash {
+		return nil, true, fmt.Errorf("%s was modified while golo was running", s.Path)
+	}
+	return content, true, nil
+}
+
+// spillOverlay configures config to use an overlay file that includes the spilled files.
+// packages.Config.Overlay can only hold content in memory, and go/packages passes
+// its own -overlay flag after ours, so once anything is spilled the in-memory files
+// are written out alongside them and config.Overlay is not used. (f.parseFile
+// substitutes the fixed content when go/packages reads the original from disk.)
+func (f *Fixer) spillOverlay(config *packages.Config) error {
+	overlays := packages.OverlayJSON{Replace: map[string]string{}}
+	for filename, s := range f.spilled {
+		overlays.Replace[filename] = s.Path
+	}
+	for filename, content := range f.Fixed {
+		path := f.spillPath(filename)
+		if err := f.sandbox.writeFile(path, content, 0o666); err != nil {
+			return err
+		}
+		overlays.Replace[filename] = path
+	}
+
+	content, err := json.Marshal(nativeOverlay(overlays))
+	if err != nil {
+		return err
+	}
+	overlayFile := filepath.Join(f.spillDir, "overlay.json")
+	if err := f.sandbox.writeFile(overlayFile, content, 0o666); err != nil {
+		return err
+	}
+	config.Overlay = nil
+	config.BuildFlags = append(config.BuildFlags[:len(config.BuildFlags):len(config.BuildFlags)], "-overlay="+overlayFile)
+	return nil
+}
+
+// freeSyntax drops the syntax trees and type information of packages that had no errors,
+// so that they can be garbage collected before the next iteration is loaded.
+func freeSyntax(pkg *packages.Package) {
+	pkg.Syntax = nil
+	pkg.TypesInfo = nil
+}
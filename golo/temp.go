@@ -0,0 +1,138 @@
+package golo
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pidFile is written to each temporary directory that MkdirTemp creates, with the process ID of
+// the golo that created it, so that Clean can tell that golo created it, and whether it is
+// still in use.
+const pidFile = "golo.pid"
+
+// cacheAge is how long Clean keeps the files in golo's caches that no run of golo has used.
+const cacheAge = 7 * 24 * time.Hour
+
+// cacheDirs are the directories in the user's cache directory that golo uses (see cacheDir).
+// The overlay files are each named after the file they replace and its content, so the go
+// command sees the same overlay each time a file is fixed in the same way. The fixes are
+// named after what was fixed (see fixCacheFile).
+var cacheDirs = []string{"overlays", "fixes"}
+
+// userCacheDir returns the user's cache directory. (The tests replace it, so that they don't
+// leave anything in the real one.)
+var userCacheDir = os.UserCacheDir
+
+// cacheDir returns the directory called name in golo's part of the user's cache directory
+// (or "" if there isn't one). It may not exist yet, so the runner creates it (in its sandbox)
+// before using it.
+func cacheDir(name string) string {
+	dir, err := userCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "golo", name)
+}
+
+// MkdirTemp creates a new directory in the system's temporary directory, as os.MkdirTemp does,
+// and records that this process is using it. The pattern should start with "golo-", so that
+// Clean can remove the directory if golo is killed before it can remove it.
+func MkdirTemp(pattern string) (string, error) {
+	s := newSandbox(os.TempDir())
+	dir, err := s.mkdirTemp(os.TempDir(), pattern)
+	if err != nil {
+		return "", err
+	}
+	if err := s.writeFile(filepath.Join(dir, pidFile), []byte(strconv.Itoa(os.Getpid())), 0o666); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// Clean removes the temporary directories that golo left in dir (or the system's temporary
+// directory, if dir is "") because it was killed before it could remove them, and returns their
+// paths. Only the directories that MkdirTemp created are removed (anything else whose name
+// starts with golo- may belong to someone else), and those that a running golo is still using
+// are left alone. If dir is "", the files in golo's caches that haven't been used for a week
+// are removed too.
+func Clean(dir string) ([]string, error) {
+	removed := []string{}
+	if dir == "" {
+		dir = os.TempDir()
+		for _, name := range cacheDirs {
+			cache := cacheDir(name)
+			if cache == "" {
+				continue
+			}
+			stale, err := cleanCache(cache)
+			removed = append(removed, stale...)
+			if err != nil {
+				return removed, err
+			}
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return removed, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "golo-") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		pid, ok := creator(path)
+		if !ok || processExists(pid) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// cleanCache removes the files in one of golo's caches that haven't been used for cacheAge.
+func cleanCache(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	removed := []string{}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < cacheAge {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// creator returns the process ID that MkdirTemp recorded in the directory at path, or false if
+// it didn't create it.
+func creator(path string) (int, bool) {
+	name := filepath.Join(path, pidFile)
+	if info, err := os.Lstat(name); err != nil || !info.Mode().IsRegular() {
+		return 0, false
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	return pid, err == nil && pid > 0
+}
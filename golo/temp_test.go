@@ -0,0 +1,60 @@
+package golo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestClean(t *testing.T) {
+	// a process that has exited
+	cmd := exec.Command("go", "version")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for name, pid := range map[string]int{
+		"golo-running": os.Getpid(),
+		"golo-crashed": cmd.Process.Pid,
+		"golo-unknown": 0,
+		"other":        cmd.Process.Pid,
+	} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if pid != 0 {
+			if err := os.WriteFile(filepath.Join(dir, name, pidFile), []byte(strconv.Itoa(pid)), 0o666); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// files (even old ones) are not golo's temporary directories
+	if err := os.WriteFile(filepath.Join(dir, "golo-1234.go"), nil, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-7 * 24 * time.Hour)
+	for _, name := range []string{"golo-unknown", "golo-1234.go"} {
+		if err := os.Chtimes(filepath.Join(dir, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := Clean(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{filepath.Join(dir, "golo-crashed")}
+	if !slices.Equal(removed, expected) {
+		t.Errorf("expected %v to be removed, got %v", expected, removed)
+	}
+	for _, name := range []string{"golo-running", "golo-unknown", "golo-1234.go", "other"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to be kept, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package golo
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ToolchainError is returned by Prepare when the go command fails without saying which
+// packages are broken. This means the problem is with the go toolchain or its environment
+// (for example a build cache that can't be written to), not with code that golo can fix.
+type ToolchainError struct {
+	// ExitStatus is the exit status of the go command.
+	ExitStatus int
+	// Output is what the go command printed.
+	Output string
+}
+
+// reCacheCorrupt matches the errors the go command prints when the build cache is
+// corrupt or unusable.
+var reCacheCorrupt = regexp.MustCompile(strings.Join([]string{
+	`failed to initialize build cache`,
+	`GOCACHE is not defined`,
+	`go-build\S*: (permission denied|read-only file system|input/output error|no such file or directory)`,
+	`(?i)(build cache|cache entry|object file|export data)\S* .*(corrupt|truncated|invalid)`,
+}, "|"))
+
+// reNotUTF8 matches the errors the go command prints for a file that is not UTF-8.
+var reNotUTF8 = regexp.MustCompile(`(?m)^(?:go: reading )?(\S+\.go)(?::\d+:\d+)?: (?:illegal UTF-8 encoding|unexpected NUL in input)`)
+
+func (e *ToolchainError) Error() string {
+	msg := fmt.Sprintf("go failed (exit status %d) without reporting any broken packages for golo to fix:\n%s",
+		e.ExitStatus, strings.TrimRight(e.Output, "\n"))
+	if e.CacheCorrupt() {
+		msg += "\ngolo: the go build cache looks corrupt or unwritable, try running: go clean -cache"
+	}
+	for _, m := range reNotUTF8.FindAllStringSubmatch(e.Output, -1) {
+		if content, err := os.ReadFile(m[1]); err == nil {
+			if err := checkEncoding(m[1], content); err != nil {
+				msg += "\ngolo: " + err.Error()
+			}
+		}
+	}
+	return msg
+}
+
+// CacheCorrupt returns true if the output looks like the go build cache is the problem.
+func (e *ToolchainError) CacheCorrupt() bool {
+	return reCacheCorrupt.MatchString(e.Output)
+}
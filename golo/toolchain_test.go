@@ -0,0 +1,57 @@
+package golo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToolchainError(t *testing.T) {
+	for _, eg := range []struct {
+		output  string
+		corrupt bool
+	}{
+		{"go: failed to initialize build cache at /root/.cache/go-build: mkdir /root/.cache/go-build: permission denied\n", true},
+		{"open /home/me/.cache/go-build/3f/3f9a2c-d: permission denied\n", true},
+		{"build cache is required, but could not be located: GOCACHE is not defined and neither $XDG_CACHE_HOME nor $HOME are defined\n", true},
+		{"go build fmt: cache entry for action is corrupt\n", true},
+		{"go: go.mod file not found in current directory or any parent directory; see 'go help modules'\n", false},
+		{"open /etc/golo.conf: permission denied\n", false},
+	} {
+		e := &ToolchainError{ExitStatus: 1, Output: eg.output}
+		if e.CacheCorrupt() != eg.corrupt {
+			t.Errorf("expected CacheCorrupt() to be %v for %q", eg.corrupt, eg.output)
+		}
+		msg := e.Error()
+		if !strings.Contains(msg, strings.TrimSpace(eg.output)) || !strings.Contains(msg, "exit status 1") {
+			t.Errorf("expected the error to include the output and exit status, got %q", msg)
+		}
+		if strings.Contains(msg, "go clean -cache") != eg.corrupt {
+			t.Errorf("expected go clean -cache to be suggested only for a corrupt cache, got %q", msg)
+		}
+	}
+}
+
+func TestRunner_ToolchainError(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(main, []byte("package main\n\nfunc main() {}\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	// the cache can't be created inside a file
+	t.Setenv("GOCACHE", filepath.Join(main, "cache"))
+
+	r := New("run", false, []string{main})
+	err := r.Prepare()
+	e, ok := err.(*ToolchainError)
+	if !ok {
+		t.Fatalf("expected a ToolchainError, got %v", err)
+	}
+	if !e.CacheCorrupt() {
+		t.Errorf("expected the cache to be blamed, got %q", e.Output)
+	}
+	if r.built {
+		t.Error("expected the build not to be marked as successful")
+	}
+}
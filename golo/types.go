@@ -0,0 +1,203 @@
+package golo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// typeExpr returns the source code for t as it should be written in file.
+// It returns false if t refers to a package that file does not import.
+func typeExpr(pkg *packages.Package, file *ast.File, t types.Type) (string, bool) {
+	names := map[string]string{}
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if spec.Name != nil {
+			names[path] = spec.Name.Name
+			continue
+		}
+		for _, imp := range pkg.Types.Imports() {
+			if imp.Path() == path {
+				names[path] = imp.Name()
+			}
+		}
+	}
+
+	ok := true
+	s := types.TypeString(t, func(p *types.Package) string {
+		if p == pkg.Types {
+			return ""
+		}
+		name, found := names[p.Path()]
+		if !found || name == "_" {
+			ok = false
+		}
+		if name == "." {
+			return ""
+		}
+		return name
+	})
+	return s, ok
+}
+
+// typedPanic returns an expression of type t (as returned by typeExpr) that panics with msg.
+func (f *Fixer) typedPanic(t string, msg string) string {
+	return fmt.Sprintf("func() %s { panic(%#v) }()", t, f.panicMsg(msg))
+}
+
+// operandAt returns the path to the expression starting at pos that is used as an
+// operand (a function argument, the right hand side of an assignment, a return value, etc.),
+// along with the type its context expects it to have.
+func operandAt(pkg *packages.Package, file *ast.File, pos token.Pos) ([]ast.Node, types.Type) {
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	for i, n := range path {
+		if _, ok := n.(ast.Expr); !ok || n.Pos() != pos {
+			continue
+		}
+		if t := expectedType(pkg, path[i:]); t != nil {
+			return path[i:], t
+		}
+	}
+	return nil, nil
+}
+
+// expectedType returns the type that the expression path[0] is required to have by
+// its context, or nil if it can't be determined.
+func expectedType(pkg *packages.Package, path []ast.Node) types.Type {
+	if len(path) < 2 {
+		return nil
+	}
+	expr := path[0]
+	switch p := path[1].(type) {
+	case *ast.CallExpr:
+		sig, ok := underlying(pkg.TypesInfo.TypeOf(p.Fun)).(*types.Signature)
+		if !ok {
+			return nil
+		}
+		params := sig.Params()
+		for i, arg := range p.Args {
+			if arg != expr {
+				continue
+			}
+			if sig.Variadic() && i >= params.Len()-1 {
+				last := params.At(params.Len() - 1).Type()
+				if p.Ellipsis.IsValid() {
+					return last
+				}
+				if s, ok := last.(*types.Slice); ok {
+					return s.Elem()
+				}
+				return nil
+			}
+			if i < params.Len() {
+				return params.At(i).Type()
+			}
+		}
+	case *ast.AssignStmt:
+		if p.Tok != token.ASSIGN || len(p.Lhs) != len(p.Rhs) {
+			return nil
+		}
+		for i, rhs := range p.Rhs {
+			if rhs == expr {
+				return pkg.TypesInfo.TypeOf(p.Lhs[i])
+			}
+		}
+	case *ast.ValueSpec:
+		if p.Type == nil {
+			return nil
+		}
+		for _, v := range p.Values {
+			if v == expr {
+				return pkg.TypesInfo.TypeOf(p.Type)
+			}
+		}
+	case *ast.SendStmt:
+		if p.Value == expr {
+			if ch, ok := underlying(pkg.TypesInfo.TypeOf(p.Chan)).(*types.Chan); ok {
+				return ch.Elem()
+			}
+		}
+	case *ast.KeyValueExpr:
+		if p.Value != expr || len(path) < 3 {
+			return nil
+		}
+		lit, ok := path[2].(*ast.CompositeLit)
+		if !ok {
+			return nil
+		}
+		switch t := underlying(pkg.TypesInfo.TypeOf(lit)).(type) {
+		case *types.Struct:
+			key, ok := p.Key.(*ast.Ident)
+			if !ok {
+				return nil
+			}
+			for i := 0; i < t.NumFields(); i++ {
+				if t.Field(i).Name() == key.Name {
+					return t.Field(i).Type()
+				}
+			}
+		case *types.Map:
+			return t.Elem()
+		case *types.Slice:
+			return t.Elem()
+		case *types.Array:
+			return t.Elem()
+		}
+	case *ast.CompositeLit:
+		for i, elt := range p.Elts {
+			if elt != expr {
+				continue
+			}
+			switch t := underlying(pkg.TypesInfo.TypeOf(p)).(type) {
+			case *types.Struct:
+				if i < t.NumFields() {
+					return t.Field(i).Type()
+				}
+			case *types.Slice:
+				return t.Elem()
+			case *types.Array:
+				return t.Elem()
+			}
+		}
+	case *ast.ReturnStmt:
+		results := enclosingResults(pkg, path[2:])
+		if results == nil || results.Len() != len(p.Results) {
+			return nil
+		}
+		for i, r := range p.Results {
+			if r == expr {
+				return results.At(i).Type()
+			}
+		}
+	}
+	return nil
+}
+
+// enclosingResults returns the results of the innermost function in path.
+func enclosingResults(pkg *packages.Package, path []ast.Node) *types.Tuple {
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			if sig, ok := pkg.TypesInfo.TypeOf(n).(*types.Signature); ok {
+				return sig.Results()
+			}
+			return nil
+		case *ast.FuncDecl:
+			if obj := pkg.TypesInfo.Defs[n.Name]; obj != nil {
+				if sig, ok := obj.Type().(*types.Signature); ok {
+					return sig.Results()
+				}
+			}
+			return nil
+		}
+	}
+	return nil
+}
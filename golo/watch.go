@@ -0,0 +1,60 @@
+package golo
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// WatchFiles returns the files that the packages being built are made from, including those
+// of the packages in the module that they import (but not the standard library or the module
+// cache, which don't change), and the go.mod files. If any of them change, running golo
+// again may have a different result. Call Prepare first, so that the package patterns have
+// been checked.
+func (r *Runner) WatchFiles() ([]string, error) {
+	build := r.build
+	flags, pkgs := splitFlags(r.buildArgs)
+	if build == nil {
+		build = newBuildConfig(r.Options, flags)
+	}
+	// go test accepts test flags after the packages
+	for i, arg := range pkgs {
+		if strings.HasPrefix(arg, "-") {
+			pkgs = pkgs[:i]
+			break
+		}
+	}
+	loadFlags, dir := loadFlags(build.flags)
+	config := &packages.Config{
+		Mode:       packages.NeedFiles | packages.NeedEmbedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedModule,
+		Env:        build.env,
+		BuildFlags: loadFlags,
+		Dir:        dir,
+		Tests:      r.mode == "test",
+	}
+	loaded, err := packages.Load(config, pkgs...)
+	if err != nil {
+		return nil, fmt.Errorf("packages.Load failed: %w", err)
+	}
+
+	files := []string{}
+	seen := map[string]bool{}
+	add := func(file string) {
+		if file != "" && !seen[file] && !isDependency(file) {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	packages.Visit(loaded, nil, func(pkg *packages.Package) {
+		for _, list := range [][]string{pkg.GoFiles, pkg.OtherFiles, pkg.EmbedFiles, pkg.IgnoredFiles} {
+			for _, file := range list {
+				add(file)
+			}
+		}
+		if pkg.Module != nil {
+			add(pkg.Module.GoMod)
+		}
+	})
+	return files, nil
+}
@@ -1,20 +1,71 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/ConradIrwin/golo/golo"
 )
 
 func main() {
+	if isShim() {
+		shim(os.Args[1:])
+	}
+
 	flag.Usage = func() {
-		fmt.Println("Usage: golo [-v] [test|run|build] [package|file]...")
+		fmt.Println("Usage: golo [flags] [test|run|build] [package|file]...")
+		fmt.Println("       golo [flags] ci [package]...")
+		fmt.Println("       golo [flags] fix [package|file]...")
+		fmt.Println("       golo [flags] diff [package|file]...")
+		fmt.Println("       golo [flags] retry [test|run|build] [package|file]...")
+		fmt.Println("       golo [flags] run -w [package|file]...")
+		fmt.Println("       golo shim -- [go command]...")
+		fmt.Println("       golo clean")
+		flag.PrintDefaults()
 		os.Exit(0)
 	}
 	vFlag := flag.Bool("v", false, "verbose")
+	keepFlag := flag.Bool("keep", false, "keep golo's temporary files (like the binary), and print where they are (and the overlay)")
+	watchFlag := flag.Bool("w", false, "with golo run, fix and run the program again whenever its files change (also golo run -w)")
+	debugAddrFlag := flag.String("debug-addr", "", "with golo run -w, serve pprof, golo's counters and /healthz on `addr` (on localhost, like 127.0.0.1:6060)")
+	resumeFlag := flag.String("resume", "", "save progress in `dir`, and continue from there if interrupted")
+	nocacheFlag := flag.Bool("nocache", false, "fix the errors again, instead of reusing the fixes from an earlier run on the same files")
+	reportFlag := flag.String("report", "", "write a JSON report of the fixes to `file`")
+	flag.StringVar(reportFlag, "report-json", "", "write a JSON report of the fixes to `file` (same as -report)")
+	githubFlag := &optionalFlag{value: "-"}
+	flag.Var(githubFlag, "report-github", "write GitHub Actions annotations for the fixes to stdout (or to `file`)")
+	baselineFlag := flag.String("baseline", "", "compare the fixes to a previous report `file`")
+	maxFixesFlag := flag.Int("max-fixes", golo.DefaultMaxFixes, "give up after fixing `n` syntax errors in a file, or reloading the packages n times (0 for no limit)")
+	maxNewFlag := flag.Int("max-new-deferrals", -1, "fail if there are more than `n` deferrals not in the -baseline")
+	fuzzyRenameFlag := flag.Bool("fuzzy-rename", false, "replace undefined names with similar ones from the same package")
+	guessMainFlag := flag.Bool("guess-main", false, "if the package being run has no func main, call its only exported function without parameters")
+	checkDiscardsFlag := flag.Bool("check-discards", false, "panic if the unused result of a Validate, Check, Verify or Must function says it failed, instead of ignoring it")
+	artifactFlag := flag.String("artifact-out", "", "copy the built binary (or test binary) to `dir`")
+	fixFormatFlag := flag.Bool("fix-format", false, "fix printf-style calls whose format string doesn't match their arguments")
+	fixTagsFlag := flag.Bool("fix-tags", false, "fix struct tags that repeat a key, and struct fields whose JSON names only differ in case")
+	fixLoopClosureFlag := flag.Bool("fix-loopclosure", false, "before Go 1.22, copy loop variables that vet says are captured by a func literal (v := v)")
+	moveTestHelpersFlag := flag.Bool("move-test-helpers", false, "copy helpers from _test.go files into the non-test files that use them")
+	exportShimsFlag := flag.Bool("export-shims", false, "call unexported functions in other packages in the module through exported shims that only golo sees")
+	runGeneratorsFlag := flag.Bool("run-generators", false, "run go:generate to regenerate generated files that don't compile (which writes to your source files, outside golo's sandbox)")
+	diffFlag := flag.Bool("diff", false, "print a diff of the fixes instead of running (or with golo fix, writing) them")
+	allowPanicsFlag := flag.Bool("allow-panics", false, "with golo fix, also write files in which errors were deferred (replaced with a panic)")
+	jsonFlag := flag.Bool("json", false, "also write each fix to stderr as a JSON object")
+	offlineFlag := flag.Bool("offline", false, "don't let go download modules (set GOPROXY=off)")
+	minConfidenceFlag := flag.String("min-confidence", "guessing", "defer errors instead of fixing them less confidently than `level` (safe, preserving or guessing)")
+	onErrorFlag := flag.String("onerror", "panic", "what deferred errors do when they run: panic, or log to print them and return (or set GOLO_ONERROR=`mode`)")
+	filesFromFlag := flag.String("files-from", "", "fix and run the package described by the JSON manifest `file` (for trees built without go.mod)")
+	maxMemoryFlag := flag.Int64("max-memory", 0, "move fixed files to disk if golo is using more than `mb` megabytes")
+	strictFlag := &listFlag{}
+	flag.Var(strictFlag, "strict", "don't fix errors in packages matching `patterns` (comma-separated, like internal/payments/...), and fail instead")
 
+	if env := os.Getenv("GOLO_ONERROR"); env != "" {
+		flag.Set("onerror", env)
+	}
 	flag.Parse()
 	args := flag.Args()
 	if len(args) == 0 {
@@ -22,22 +73,192 @@ func main() {
 	}
 	var mode = args[0]
 	switch mode {
-	case "run", "test", "build":
+	case "run", "test", "build", "ci", "fix", "diff", "retry":
+	case "clean":
+		os.Exit(clean(*vFlag))
+	case "shim":
+		if len(args) > 1 && args[1] == "--" {
+			args = args[1:]
+		}
+		shim(args[1:])
 	default:
 		flag.Usage()
 	}
 
-	runner := golo.New(mode, *vFlag, args[1:])
+	minConfidence, err := golo.ParseConfidence(*minConfidenceFlag)
+	if err != nil {
+		fmt.Println("golo: " + err.Error())
+		os.Exit(2)
+	}
+
+	if *maxFixesFlag < 0 {
+		fmt.Printf("golo: invalid -max-fixes %d (expected 0 or more)\n", *maxFixesFlag)
+		os.Exit(2)
+	}
+
+	if *onErrorFlag != "panic" && *onErrorFlag != "log" {
+		fmt.Printf("golo: invalid -onerror %q (expected panic or log)\n", *onErrorFlag)
+		os.Exit(2)
+	}
+
+	options := golo.Options{
+		ResumeDir:       *resumeFlag,
+		NoCache:         *nocacheFlag,
+		ReportFile:      *reportFlag,
+		GitHubReport:    githubFlag.String(),
+		Baseline:        *baselineFlag,
+		MaxNewDeferrals: *maxNewFlag,
+		MaxFixes:        *maxFixesFlag,
+		FuzzyRename:     *fuzzyRenameFlag,
+		GuessMain:       *guessMainFlag,
+		FixFormat:       *fixFormatFlag,
+		CheckDiscards:   *checkDiscardsFlag,
+		FixTags:         *fixTagsFlag,
+		FixLoopClosure:  *fixLoopClosureFlag,
+		MoveTestHelpers: *moveTestHelpersFlag,
+		RunGenerators:   *runGeneratorsFlag,
+		ExportShims:     *exportShimsFlag,
+		MinConfidence:   minConfidence,
+		MaxMemory:       *maxMemoryFlag << 20,
+		ArtifactDir:     *artifactFlag,
+		Offline:         *offlineFlag,
+		OnError:         *onErrorFlag,
+		Diff:            *diffFlag,
+		AllowPanics:     *allowPanicsFlag,
+		JSON:            *jsonFlag,
+		FilesFrom:       *filesFromFlag,
+		Strict:          *strictFlag,
+		Keep:            *keepFlag,
+	}
+
+	// golo diff is golo build -diff
+	if mode == "diff" {
+		mode, options.Diff = "build", true
+	}
+	if mode == "retry" {
+		if len(args) < 2 || args[1] != "run" && args[1] != "test" && args[1] != "build" {
+			flag.Usage()
+		}
+		os.Exit(retry(args[1], *vFlag, args[2:], options))
+	}
+	if mode == "run" && len(args) > 1 && (args[1] == "-w" || args[1] == "--w") {
+		*watchFlag = true
+		args = append(args[:1:1], args[2:]...)
+	}
+	if *watchFlag {
+		if mode != "run" {
+			fmt.Println("golo: -w only works with golo run")
+			os.Exit(2)
+		}
+		var debug *debugServer
+		if *debugAddrFlag != "" {
+			if debug, err = startDebugServer(*debugAddrFlag); err != nil {
+				fmt.Println("golo: " + err.Error())
+				os.Exit(2)
+			}
+			fmt.Printf("golo: serving debug endpoints on http://%s\n", debug.addr)
+		}
+		interrupted := make(chan os.Signal, 1)
+		signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+		os.Exit(watch(*vFlag, args[1:], options, interrupted, debug))
+	}
+	if *debugAddrFlag != "" {
+		fmt.Println("golo: -debug-addr only works with golo run -w")
+		os.Exit(2)
+	}
+	os.Exit(run(mode, *vFlag, args[1:], options))
+}
+
+// run runs golo in the given mode, and returns the exit status.
+func run(mode string, verbose bool, args []string, options golo.Options) int {
+	return runReporting(mode, verbose, args, options, nil)
+}
+
+// runReporting is run, but if deltas is set it reports what changed since the last run
+// instead of every fix (see golo.DeltaSink).
+func runReporting(mode string, verbose bool, args []string, options golo.Options, deltas *golo.DeltaSink) int {
+	runner := golo.New(mode, verbose, args)
+	runner.Options = options
+	if deltas != nil {
+		deltas.Attach(runner)
+	}
+	defer runner.Close()
 
-	if err := runner.Prepare(); err != nil {
+	// Run forwards signals to what it runs, but until then they stop golo (after it has
+	// removed its temporary files)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := runner.PrepareContext(ctx)
+	stop()
+	if err != nil {
 		fmt.Println("golo: " + err.Error())
-		os.Exit(1)
+		return 1
 	}
 
-	if exitStatus, err := runner.Run(); err != nil {
+	exitStatus, err := runner.Run()
+	if err != nil {
 		fmt.Println("golo: " + err.Error())
-		os.Exit(1)
+		return 1
+	}
+	return exitStatus
+}
+
+// clean removes the temporary files left by golo processes that were killed, and returns the
+// exit status.
+func clean(verbose bool) int {
+	removed, err := golo.Clean("")
+	if verbose {
+		for _, path := range removed {
+			fmt.Println("golo: removed " + path)
+		}
+	}
+	if len(removed) == 1 {
+		fmt.Println("golo: removed 1 leftover temporary file")
 	} else {
-		os.Exit(exitStatus)
+		fmt.Printf("golo: removed %d leftover temporary files\n", len(removed))
+	}
+	if err != nil {
+		fmt.Println("golo: " + err.Error())
+		return 1
+	}
+	return 0
+}
+
+// optionalFlag is a string flag whose value can be left out (e.g. -report-github or
+// -report-github=file), in which case it is the default value.
+type optionalFlag struct {
+	value string
+	set   bool
+}
+
+func (f *optionalFlag) String() string {
+	if !f.set {
+		return ""
+	}
+	return f.value
+}
+
+func (f *optionalFlag) Set(s string) error {
+	f.set = s != "false"
+	if s != "true" && s != "false" {
+		f.value = s
+	}
+	return nil
+}
+
+func (f *optionalFlag) IsBoolFlag() bool { return true }
+
+// listFlag is a flag that can be given more than once, each time with a comma-separated list.
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(s string) error {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*f = append(*f, v)
+		}
 	}
+	return nil
 }
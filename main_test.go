@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ConradIrwin/golo/golo/golotest"
+)
+
+func TestShim(t *testing.T) {
+	bin := t.TempDir()
+	shimPath := filepath.Join(bin, "go")
+	if out, err := exec.Command("go", "build", "-o", shimPath, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, out)
+	}
+	realEnv, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mod := golotest.Module(t, map[string]string{
+		"go.mod": "module example.com/shim\n\ngo 1.20\n",
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello from main")
+	fmt.Println(undefined())
+}
+`,
+		"main_test.go": `package main
+
+import "testing"
+
+func TestOK(t *testing.T) {}
+
+func TestBroken(t *testing.T) {
+	t.Log(alsoUndefined)
+}
+`,
+	})
+
+	shim := func(args ...string) (string, int) {
+		cmd := exec.Command(shimPath, args...)
+		cmd.Dir = mod
+		cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+		out, _ := cmd.CombinedOutput()
+		return string(out), cmd.ProcessState.ExitCode()
+	}
+
+	out, status := shim("run", "-race=false", ".")
+	if !strings.Contains(out, "hello from main") || !strings.Contains(out, "undefined: undefined") || status == 0 {
+		t.Errorf("go run: unexpected output (exit %d):\n%s", status, out)
+	}
+
+	out, status = shim("test", "-run", "TestOK", "-v", ".")
+	if !strings.Contains(out, "--- PASS: TestOK") || strings.Contains(out, "TestBroken") || status != 0 {
+		t.Errorf("go test: unexpected output (exit %d):\n%s", status, out)
+	}
+
+	out, status = shim("env", "GOROOT")
+	if out != string(realEnv) || status != 0 {
+		t.Errorf("go env: expected %q, got %q (exit %d)", realEnv, out, status)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be written to while it is being read.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRetry(t *testing.T) {
+	golo := filepath.Join(t.TempDir(), "golo")
+	if out, err := exec.Command("go", "build", "-o", golo, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, out)
+	}
+
+	program := func(version string) string {
+		return `package main
+
+import "fmt"
+
+func main() {
+	var name string
+	fmt.Scanln(&name)
+	fmt.Println("` + version + ` hello", name)
+	fmt.Println(undefined())
+}
+`
+	}
+	mod := golotest.Module(t, map[string]string{"go.mod": "module example.com/retry\n\ngo 1.20\n", "main.go": program("v1")})
+
+	cmd := exec.Command(golo, "retry", "run", ".")
+	cmd.Dir = mod
+	out := &syncBuffer{}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	waitForPrompt := func(n int) {
+		deadline := time.Now().Add(time.Minute)
+		for strings.Count(out.String(), "press enter to re-run") < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for prompt %d:\n%s", n, out)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// the program reads its input from golo's stdin
+	io.WriteString(stdin, "alice\n")
+	waitForPrompt(1)
+	if !strings.Contains(out.String(), "v1 hello alice") {
+		t.Fatalf("unexpected output from first run:\n%s", out)
+	}
+
+	// golo only reads the line it needs, the rest is for the program
+	if err := os.WriteFile(filepath.Join(mod, "main.go"), []byte(program("v2")), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(stdin, "\nbob\n")
+	waitForPrompt(2)
+	if !strings.Contains(out.String(), "v2 hello bob") {
+		t.Fatalf("unexpected output from second run:\n%s", out)
+	}
+
+	io.WriteString(stdin, "q\n")
+	cmd.Wait()
+	if status := cmd.ProcessState.ExitCode(); status == 0 {
+		t.Errorf("expected the exit status of the last run, got %d", status)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupting golo needs signals")
+	}
+	golo := filepath.Join(t.TempDir(), "golo")
+	if out, err := exec.Command("go", "build", "-o", golo, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, out)
+	}
+
+	version := func(v string) string {
+		return "package version\n\nfunc Version() string {\n\treturn \"" + v + "\"\n}\n"
+	}
+	mod := golotest.Module(t, map[string]string{
+		"main.go": `package main
+
+import (
+	"time"
+
+	"example.com/m/version"
+)
+
+func main() {
+	println("running", version.Version())
+	time.Sleep(time.Hour)
+}
+`,
+		"version/version.go": version("v1"),
+	})
+
+	cmd := exec.Command(golo, "-debug-addr", "127.0.0.1:0", "run", "-w", ".")
+	cmd.Dir = mod
+	out := &syncBuffer{}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	waitFor := func(s string) {
+		deadline := time.Now().Add(time.Minute)
+		for !strings.Contains(out.String(), s) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %q:\n%s", s, out)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("running v1")
+
+	// the debug endpoints say that golo has built the program, and how often
+	addr := regexp.MustCompile(`serving debug endpoints on (http://\S+)`).FindStringSubmatch(out.String())
+	if addr == nil {
+		t.Fatalf("expected the debug address:\n%s", out)
+	}
+	if resp, err := http.Get(addr[1] + "/healthz"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("expected /healthz to succeed, got %v %v", resp, err)
+	}
+	counters := map[string]any{}
+	if resp, err := http.Get(addr[1] + "/debug/vars"); err != nil {
+		t.Error(err)
+	} else if err := json.NewDecoder(resp.Body).Decode(&counters); err != nil || counters["runs"] != 1.0 {
+		t.Errorf("expected one run, got %v %v", counters, err)
+	}
+
+	// saving a file without changing it doesn't restart the program
+	if err := os.WriteFile(filepath.Join(mod, "version", "version.go"), []byte(version("v1")), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	// a change to a package that the program imports does
+	time.Sleep(time.Second)
+	if err := os.WriteFile(filepath.Join(mod, "version", "version.go"), []byte(version("v2")), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("running v2")
+	if n := strings.Count(out.String(), "files changed, restarting"); n != 1 {
+		t.Errorf("expected one restart, got %d:\n%s", n, out)
+	}
+
+	// ctrl-C stops the program and golo
+	cmd.Process.Signal(os.Interrupt)
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("golo didn't exit after being interrupted:\n%s", out)
+	}
+}
+
+func TestDebugServer(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:0", "example.com:6060", "6060"} {
+		if _, err := startDebugServer(addr); err == nil {
+			t.Errorf("expected %s to be refused", addr)
+		}
+	}
+
+	d, err := startDebugServer("localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) (int, string) {
+		resp, err := http.Get("http://" + d.addr + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	if status, _ := get("/healthz"); status != http.StatusServiceUnavailable {
+		t.Errorf("expected /healthz to fail before the first run, got %d", status)
+	}
+	d.started()
+	d.prepared(nil)
+	if status, _ := get("/healthz"); status != http.StatusOK {
+		t.Errorf("expected /healthz to succeed after the first run, got %d", status)
+	}
+	if status, _ := get("/debug/pprof/"); status != http.StatusOK {
+		t.Errorf("expected pprof, got %d", status)
+	}
+	if _, body := get("/debug/vars"); !strings.Contains(body, `"runs": 1`) {
+		t.Errorf("expected the counters, got %s", body)
+	}
+}
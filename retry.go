@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ConradIrwin/golo/golo"
+)
+
+// retry runs golo in the given mode, and then waits for the user to ask for it to run
+// again. Progress is saved between runs (as with -resume) so that if no source files have
+// changed the fixes don't need to be found again, and each run after the first only lists
+// what changed in the deferrals (l lists every fix). It returns the exit status of the last run.
+func retry(mode string, verbose bool, args []string, options golo.Options) int {
+	var dirs []string
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}()
+	fresh := func() error {
+		dir, err := golo.MkdirTemp("golo-retry-*")
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, dir)
+		options.ResumeDir = dir
+		return nil
+	}
+	if options.ResumeDir == "" {
+		if err := fresh(); err != nil {
+			fmt.Println("golo: " + err.Error())
+			return 1
+		}
+	}
+
+	// after the first run, only the changes to the deferrals are listed
+	deltas := golo.NewDeltaSink(os.Stdout)
+	for {
+		status := runReporting(mode, verbose, args, options, deltas)
+
+		line := ""
+		for {
+			fmt.Print("golo: press enter to re-run, r to re-fix from scratch, l to list the fixes, q to quit: ")
+			var err error
+			if line, err = readLine(os.Stdin); err != nil {
+				fmt.Println()
+				return status
+			}
+			if line = strings.TrimSpace(line); line != "l" {
+				break
+			}
+			deltas.WriteList(os.Stdout)
+		}
+		switch line {
+		case "q":
+			return status
+		case "r":
+			if err := fresh(); err != nil {
+				fmt.Println("golo: " + err.Error())
+				return 1
+			}
+		}
+	}
+}
+
+// readLine reads a line from r one byte at a time, so that nothing after the line is
+// consumed (the rest of stdin belongs to the program being run).
+func readLine(r io.Reader) (string, error) {
+	line := []byte{}
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ConradIrwin/golo/golo"
+)
+
+// isShim returns true if golo was run via a symlink or copy named go or golo-shim.
+func isShim() bool {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	return name == "go" || name == "golo-shim"
+}
+
+// shim lets golo stand in for the go command: run, test and build are handled by golo,
+// and every other subcommand is passed through to the real go command untouched.
+// It never returns.
+func shim(args []string) {
+	goBin, err := findGo()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "golo: "+err.Error())
+		os.Exit(1)
+	}
+	// make sure that golo itself (and go/packages) run the real go command
+	os.Setenv("PATH", filepath.Dir(goBin)+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "run", "test", "build":
+			os.Exit(run(args[0], false, args[1:], golo.Options{MaxNewDeferrals: -1, MaxFixes: golo.DefaultMaxFixes}))
+		}
+	}
+
+	cmd := exec.Command(goBin, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil && cmd.ProcessState == nil {
+		fmt.Fprintln(os.Stderr, "golo: "+err.Error())
+		os.Exit(1)
+	}
+	os.Exit(cmd.ProcessState.ExitCode())
+}
+
+// findGo returns the first go command on $PATH that is not this program.
+func findGo() (string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	selfInfo, err := os.Stat(self)
+	if err != nil {
+		return "", err
+	}
+
+	name := "go"
+	if runtime.GOOS == "windows" {
+		name = "go.exe"
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		candidate := filepath.Join(dir, name)
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() || info.Mode()&0o111 == 0 && runtime.GOOS != "windows" {
+			continue
+		}
+		if os.SameFile(info, selfInfo) {
+			continue
+		}
+		return candidate, nil
+	}
+	return "", errors.New("could not find the go command on $PATH")
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ConradIrwin/golo/golo"
+)
+
+// pollInterval is how often watch checks whether any of the files have changed.
+const pollInterval = 300 * time.Millisecond
+
+// watch runs golo run with args, and then runs it again whenever any of the files that the
+// program is built from change (stopping the program first if it is still running). As in
+// retry, progress is saved between runs so that the fixes are only found again if a file's
+// content has changed, and each run after the first only lists what changed in the deferrals.
+// It returns the exit status of the last run once interrupted. If debug is set, each run is
+// counted in it.
+func watch(verbose bool, args []string, options golo.Options, interrupted <-chan os.Signal, debug *debugServer) int {
+	if options.ResumeDir == "" {
+		dir, err := golo.MkdirTemp("golo-watch-*")
+		if err != nil {
+			fmt.Println("golo: " + err.Error())
+			return 1
+		}
+		defer os.RemoveAll(dir)
+		options.ResumeDir = dir
+	}
+
+	// after the first run, only the changes to the deferrals are listed (l lists every fix)
+	deltas := golo.NewDeltaSink(os.Stdout)
+	keys := readLines(os.Stdin)
+
+	// ctx is done once golo is interrupted, which stops it whether it is fixing the program or
+	// waiting for it to exit
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupted:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	status := 0
+	for {
+		runner := golo.New("run", verbose, args)
+		runner.Options = options
+		deltas.Attach(runner)
+		if debug != nil {
+			debug.started()
+			runner.AddSink(debug)
+		}
+		// the program doesn't get the terminal (as it would if it read golo's stdin), so that
+		// ctrl-C stops golo as well as the program
+		runner.Stdin = strings.NewReader("")
+
+		exited := make(chan int, 1)
+		running := false
+		err := runner.PrepareContext(ctx)
+		if ctx.Err() != nil {
+			runner.Close()
+			return status
+		}
+		if debug != nil {
+			debug.prepared(err)
+		}
+		if err != nil {
+			fmt.Println("golo: " + err.Error())
+			exited <- 1
+		} else {
+			running = true
+			go func() {
+				status, err := runner.Run()
+				if err != nil {
+					fmt.Println("golo: " + err.Error())
+					status = 1
+				}
+				exited <- status
+			}()
+		}
+
+		files, err := runner.WatchFiles()
+		if err != nil {
+			fmt.Println("golo: " + err.Error())
+		}
+		stop := make(chan struct{})
+		changed := pollFiles(files, stop)
+
+	wait:
+		for {
+			select {
+			case status = <-exited:
+				running = false
+				if debug != nil {
+					debug.exited(status)
+				}
+				fmt.Printf("golo: exited with status %d, waiting for changes\n", status)
+			case <-changed:
+				fmt.Println("golo: files changed, restarting")
+				if running {
+					runner.Stop()
+					status = <-exited
+				}
+				break wait
+			case line, ok := <-keys:
+				if !ok {
+					keys = nil
+				} else if strings.TrimSpace(line) == "l" {
+					deltas.WriteList(os.Stdout)
+				}
+			case <-ctx.Done():
+				close(stop)
+				if running {
+					runner.Stop()
+					status = <-exited
+				}
+				runner.Close()
+				return status
+			}
+		}
+		close(stop)
+		runner.Close()
+	}
+}
+
+// readLines returns a channel that receives each line read from r, and is closed once r
+// has been read.
+func readLines(r io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		for {
+			line, err := readLine(r)
+			if line != "" || err == nil {
+				lines <- line
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return lines
+}
+
+// fileState is what pollFiles knows about a file (or the files in a directory).
+type fileState struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+// pollFiles returns a channel that is closed once the content of any of files changes (or a
+// file is added to or removed from one of their directories), checking every pollInterval
+// until stop is closed. A file that is saved without changing doesn't count.
+func pollFiles(files []string, stop <-chan struct{}) <-chan struct{} {
+	states := map[string]*fileState{}
+	for _, file := range files {
+		states[file] = readState(file)
+		if dir := filepath.Dir(file); states[dir] == nil {
+			states[dir] = readState(dir)
+		}
+	}
+
+	changed := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			for name, state := range states {
+				info, err := os.Stat(name)
+				if err == nil && state != nil && info.ModTime().Equal(state.modTime) && info.Size() == state.size {
+					continue
+				}
+				now := readState(name)
+				if (now == nil) != (state == nil) || now != nil && now.hash != state.hash {
+					close(changed)
+					return
+				}
+				states[name] = now
+			}
+		}
+	}()
+	return changed
+}
+
+// readState returns the state of a file, or for a directory the names of the go files in it
+// (or nil if it doesn't exist).
+func readState(name string) *fileState {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil
+	}
+	state := &fileState{modTime: info.ModTime(), size: info.Size()}
+	if info.IsDir() {
+		entries, err := os.ReadDir(name)
+		if err != nil {
+			return nil
+		}
+		// only go files count, so that editors' temporary files don't
+		names := []string{}
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), ".go") {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+		state.hash = sha256.Sum256([]byte(strings.Join(names, "\n")))
+		return state
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil
+	}
+	state.hash = sha256.Sum256(content)
+	return state
+}